@@ -33,7 +33,9 @@ var Directives = []string{
 	"dnstap",
 	"local",
 	"dns64",
+	"ratelimit",
 	"acl",
+	"rpz",
 	"any",
 	"chaos",
 	"loadbalance",
@@ -3,11 +3,13 @@ package acl
 import (
 	"context"
 	"net"
+	"regexp"
 	"strings"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/metrics"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/request"
 
 	"github.com/infobloxopen/go-trees/iptree"
@@ -19,6 +21,8 @@ type ACL struct {
 	Next plugin.Handler
 
 	Rules []rule
+
+	lists []*list // all lists used by the rules, these are reloaded when they change.
 }
 
 // rule defines a list of Zones and some ACL policies which will be
@@ -33,11 +37,24 @@ type action int
 
 // policy defines the ACL policy for DNS queries.
 // A policy performs the specified action (block/allow) on all DNS queries
-// matched by source IP or QTYPE.
+// matched by source IP, QTYPE, query name, EDNS0 options and transport.
 type policy struct {
 	action action
 	qtypes map[uint16]struct{}
 	filter *iptree.Tree
+
+	netLists   []*list             // files with source addresses, matched in addition to filter.
+	names      []string            // query name suffixes, empty matches all names.
+	nameLists  []*list             // files with query name suffixes.
+	regexes    []*regexp.Regexp    // query name patterns, empty matches all names.
+	edns       []ednsOption        // EDNS0 options that must (not) be present.
+	transports map[string]struct{} // transports, empty matches all transports.
+}
+
+// ednsOption matches on the presence of an EDNS0 option in the query.
+type ednsOption struct {
+	code   uint16
+	absent bool // match when the option is not present.
 }
 
 const (
@@ -67,7 +84,7 @@ RulesCheckLoop:
 			continue
 		}
 
-		action := matchWithPolicies(rule.policies, w, r)
+		action := matchWithPolicies(ctx, rule.policies, w, r)
 		switch action {
 		case actionDrop:
 			{
@@ -109,7 +126,7 @@ RulesCheckLoop:
 
 // matchWithPolicies matches the DNS query with a list of ACL polices and returns suitable
 // action against the query.
-func matchWithPolicies(policies []policy, w dns.ResponseWriter, r *dns.Msg) action {
+func matchWithPolicies(ctx context.Context, policies []policy, w dns.ResponseWriter, r *dns.Msg) action {
 	state := request.Request{W: w, Req: r}
 
 	var ip net.IP
@@ -126,6 +143,7 @@ func matchWithPolicies(policies []policy, w dns.ResponseWriter, r *dns.Msg) acti
 		return actionBlock
 	}
 	qtype := state.QType()
+	trans := ""
 	for _, policy := range policies {
 		// dns.TypeNone matches all query types.
 		_, matchAll := policy.qtypes[dns.TypeNone]
@@ -134,17 +152,106 @@ func matchWithPolicies(policies []policy, w dns.ResponseWriter, r *dns.Msg) acti
 			continue
 		}
 
-		_, contained := policy.filter.GetByIP(ip)
-		if !contained {
+		if !policy.matchIP(ip) {
+			continue
+		}
+
+		if !policy.matchName(state.Name()) || !policy.matchEDNS(r) {
 			continue
 		}
 
+		if len(policy.transports) > 0 {
+			if trans == "" {
+				trans = queryTransport(ctx, state)
+			}
+			if _, ok := policy.transports[trans]; !ok {
+				continue
+			}
+		}
+
 		// matched.
 		return policy.action
 	}
 	return actionNone
 }
 
+// matchIP returns true if ip is contained in the networks of p or in one of its lists.
+func (p policy) matchIP(ip net.IP) bool {
+	if _, contained := p.filter.GetByIP(ip); contained {
+		return true
+	}
+	for _, l := range p.netLists {
+		if l.containsIP(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchName returns true if name matches one of the suffixes (inline or from a list) and one of
+// the regular expressions of p. An empty list of suffixes or expressions matches all names.
+func (p policy) matchName(name string) bool {
+	if (len(p.names) > 0 || len(p.nameLists) > 0) && !p.matchSuffix(name) {
+		return false
+	}
+	if len(p.regexes) == 0 {
+		return true
+	}
+	for _, re := range p.regexes {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSuffix returns true if name is equal to, or below, one of the names of p.
+func (p policy) matchSuffix(name string) bool {
+	if plugin.Zones(p.names).Matches(name) != "" {
+		return true
+	}
+	for _, l := range p.nameLists {
+		if l.containsName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchEDNS returns true if all EDNS0 options of p are (or, when absent is set, aren't) present in r.
+func (p policy) matchEDNS(r *dns.Msg) bool {
+	if len(p.edns) == 0 {
+		return true
+	}
+	var options []dns.EDNS0
+	if o := r.IsEdns0(); o != nil {
+		options = o.Option
+	}
+	for _, e := range p.edns {
+		present := false
+		for _, o := range options {
+			if o.Option() == e.code {
+				present = true
+				break
+			}
+		}
+		if present == e.absent {
+			return false
+		}
+	}
+	return true
+}
+
+// queryTransport returns the transport the query was received over: udp or tcp for plain DNS, or the
+// transport of the server, i.e. tls, https or quic.
+func queryTransport(ctx context.Context, state request.Request) string {
+	addr := metrics.WithServer(ctx)
+	if i := strings.Index(addr, "://"); i > 0 && addr[:i] != transport.DNS {
+		return addr[:i]
+	}
+	return state.Proto()
+}
+
 // Name implements the plugin.Handler interface.
 func (a ACL) Name() string {
 	return "acl"
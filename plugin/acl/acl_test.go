@@ -2,9 +2,11 @@ package acl
 
 import (
 	"context"
+	"net"
 	"testing"
 
 	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin/test"
 
 	"github.com/miekg/dns"
@@ -597,3 +599,158 @@ func TestACLServeDNS(t *testing.T) {
 		})
 	}
 }
+
+func TestACLServeDNSMatch(t *testing.T) {
+	type args struct {
+		domain   string
+		sourceIP string
+		qtype    uint16
+		tcp      bool
+		server   string
+		ecs      bool
+	}
+	tests := []struct {
+		name      string
+		config    string
+		args      args
+		wantRcode int
+		wantDrop  bool
+	}{
+		{
+			name: "Name suffix BLOCKED",
+			config: `acl . {
+				allow name internal net 10.0.0.0/8
+				block name internal
+			}`,
+			args:      args{domain: "db.internal.", sourceIP: "192.168.0.2", qtype: dns.TypeA},
+			wantRcode: dns.RcodeRefused,
+		},
+		{
+			name: "Name suffix ALLOWED",
+			config: `acl . {
+				allow name internal net 10.0.0.0/8
+				block name internal
+			}`,
+			args:      args{domain: "db.internal.", sourceIP: "10.1.1.1", qtype: dns.TypeA},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name: "Name suffix other name",
+			config: `acl . {
+				block name internal
+			}`,
+			args:      args{domain: "db.external.", sourceIP: "192.168.0.2", qtype: dns.TypeA},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name: "Regex BLOCKED",
+			config: `acl . {
+				block regex ^[a-z0-9]{20,}\.example\.org\.$
+			}`,
+			args:      args{domain: "abcdefghijklmnopqrstuvwxyz.example.org.", sourceIP: "192.168.0.2", qtype: dns.TypeA},
+			wantRcode: dns.RcodeRefused,
+		},
+		{
+			name: "Regex ALLOWED",
+			config: `acl . {
+				block regex ^[a-z0-9]{20,}\.example\.org\.$
+			}`,
+			args:      args{domain: "www.example.org.", sourceIP: "192.168.0.2", qtype: dns.TypeA},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name: "ANY over UDP DROPPED",
+			config: `acl . {
+				drop type ANY transport udp
+			}`,
+			args:     args{domain: "example.org.", sourceIP: "192.168.0.2", qtype: dns.TypeANY},
+			wantDrop: true,
+		},
+		{
+			name: "ANY over TCP ALLOWED",
+			config: `acl . {
+				drop type ANY transport udp
+			}`,
+			args:      args{domain: "example.org.", sourceIP: "192.168.0.2", qtype: dns.TypeANY, tcp: true},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name: "Transport DoH BLOCKED",
+			config: `acl . {
+				block transport https quic
+			}`,
+			args:      args{domain: "example.org.", sourceIP: "192.168.0.2", qtype: dns.TypeA, server: "https://:443"},
+			wantRcode: dns.RcodeRefused,
+		},
+		{
+			name: "Transport DNS ALLOWED",
+			config: `acl . {
+				block transport https quic
+			}`,
+			args:      args{domain: "example.org.", sourceIP: "192.168.0.2", qtype: dns.TypeA, server: "dns://:53"},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name: "ECS BLOCKED",
+			config: `acl . {
+				block edns ecs
+			}`,
+			args:      args{domain: "example.org.", sourceIP: "192.168.0.2", qtype: dns.TypeA, ecs: true},
+			wantRcode: dns.RcodeRefused,
+		},
+		{
+			name: "ECS ALLOWED",
+			config: `acl . {
+				block edns ecs
+			}`,
+			args:      args{domain: "example.org.", sourceIP: "192.168.0.2", qtype: dns.TypeA},
+			wantRcode: dns.RcodeSuccess,
+		},
+		{
+			name: "No ECS BLOCKED",
+			config: `acl . {
+				block edns !ecs
+			}`,
+			args:      args{domain: "example.org.", sourceIP: "192.168.0.2", qtype: dns.TypeA},
+			wantRcode: dns.RcodeRefused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctr := caddy.NewTestController("dns", tt.config)
+			a, err := parse(ctr)
+			if err != nil {
+				t.Fatalf("Error: Cannot parse acl from config: %v", err)
+			}
+			a.Next = test.NextHandler(dns.RcodeSuccess, nil)
+
+			ctx := context.Background()
+			if tt.args.server != "" {
+				ctx = context.WithValue(ctx, dnsserver.Key{}, &dnsserver.Server{Addr: tt.args.server})
+			}
+
+			w := &testResponseWriter{}
+			w.setRemoteIP(tt.args.sourceIP)
+			w.TCP = tt.args.tcp
+			m := new(dns.Msg)
+			m.SetQuestion(tt.args.domain, tt.args.qtype)
+			if tt.args.ecs {
+				m.SetEdns0(4096, false)
+				m.IsEdns0().Option = append(m.IsEdns0().Option, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("10.0.0.0")})
+			}
+			if _, err := a.ServeDNS(ctx, w, m); err != nil {
+				t.Fatalf("Error: acl.ServeDNS() error = %v", err)
+			}
+			if tt.wantDrop {
+				if w.Msg != nil {
+					t.Errorf("Error: acl.ServeDNS() responded to client when not expected")
+				}
+				return
+			}
+			if w.Rcode != tt.wantRcode {
+				t.Errorf("Error: acl.ServeDNS() Rcode = %v, want %v", w.Rcode, tt.wantRcode)
+			}
+		})
+	}
+}
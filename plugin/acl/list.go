@@ -0,0 +1,242 @@
+package acl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/infobloxopen/go-trees/iptree"
+	"github.com/miekg/dns"
+)
+
+// defaultReload is the interval with which list files are checked for changes.
+const defaultReload = 5 * time.Second
+
+const (
+	listNet  = "net-file"
+	listName = "name-file"
+)
+
+// list is a file with source addresses (CIDRs or single IPs) or query names, one per line. Lines
+// starting with a '#' are comments. The file is periodically checked for changes and reloaded.
+// Addresses are stored in a radix tree and names in a label trie, so a lookup only depends on the
+// length of the address or name, not on the number of entries.
+type list struct {
+	typ    string
+	path   string
+	reload time.Duration
+
+	sync.RWMutex
+	nets  *iptree.Tree
+	names *nameTree
+	mtime time.Time
+	size  int64
+}
+
+// newList returns a list of type typ for the file path and loads it.
+func newList(typ, path string) (*list, error) {
+	l := &list{typ: typ, path: path, reload: defaultReload}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := l.read(file); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return l, nil
+}
+
+// containsIP returns true if ip is contained in one of the networks of the list.
+func (l *list) containsIP(ip net.IP) bool {
+	l.RLock()
+	defer l.RUnlock()
+	_, ok := l.nets.GetByIP(ip)
+	return ok
+}
+
+// containsName returns true if name is, or is below, one of the names of the list.
+func (l *list) containsName(name string) bool {
+	l.RLock()
+	defer l.RUnlock()
+	return l.names.contains(name)
+}
+
+// update reloads the list if the file's size or modification time changed. On error the current
+// contents of the list are kept.
+func (l *list) update() {
+	file, err := os.Open(l.path)
+	if err != nil {
+		log.Warningf("Failed to open %s: %s", l.path, err)
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return
+	}
+	l.RLock()
+	unchanged := l.mtime.Equal(stat.ModTime()) && l.size == stat.Size()
+	l.RUnlock()
+	if unchanged {
+		return
+	}
+
+	if err := l.read(file); err != nil {
+		log.Errorf("Failed to reload %s: %s", l.path, err)
+		return
+	}
+	log.Infof("Reloaded %s", l.path)
+}
+
+// read parses file and replaces the contents of the list.
+func (l *list) read(file *os.File) error {
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	var (
+		nets  *iptree.Tree
+		names *nameTree
+	)
+	switch l.typ {
+	case listNet:
+		nets, err = parseNets(file)
+	case listName:
+		names, err = parseNames(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	l.Lock()
+	l.nets, l.names = nets, names
+	l.mtime, l.size = stat.ModTime(), stat.Size()
+	l.Unlock()
+	return nil
+}
+
+// periodicUpdate reloads the list every l.reload until stop is closed.
+func (l *list) periodicUpdate(stop <-chan struct{}) {
+	if l.reload == 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(l.reload)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				l.update()
+			}
+		}
+	}()
+}
+
+// entries calls f for every entry in r, together with the line number it is on. Comments and empty
+// lines are skipped.
+func entries(r io.Reader, f func(line int, entry string) error) error {
+	scanner := bufio.NewScanner(r)
+	for i := 1; scanner.Scan(); i++ {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 1 {
+			return fmt.Errorf("line %d: unexpected data after %q", i, fields[0])
+		}
+		if err := f(i, fields[0]); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func parseNets(r io.Reader) (*iptree.Tree, error) {
+	t := iptree.NewTree()
+	err := entries(r, func(line int, entry string) error {
+		_, source, err := net.ParseCIDR(normalize(entry))
+		if err != nil {
+			return fmt.Errorf("line %d: illegal CIDR notation %q", line, entry)
+		}
+		t.InplaceInsertNet(source, struct{}{})
+		return nil
+	})
+	return t, err
+}
+
+func parseNames(r io.Reader) (*nameTree, error) {
+	t := &nameTree{}
+	err := entries(r, func(line int, entry string) error {
+		if _, ok := dns.IsDomainName(entry); !ok {
+			return fmt.Errorf("line %d: illegal name %q", line, entry)
+		}
+		t.insert(plugin.Name(entry).Normalize())
+		return nil
+	})
+	return t, err
+}
+
+// nameTree is a trie of domain names, keyed on labels from the root down.
+type nameTree struct {
+	children map[string]*nameTree
+	terminal bool // a name ends here, everything below it matches.
+}
+
+// insert adds the (normalized) name to the tree.
+func (t *nameTree) insert(name string) {
+	n := t
+	labels := dns.SplitDomainName(name)
+	for i := len(labels) - 1; i >= 0; i-- {
+		if n.terminal {
+			return // an ancestor already matches all of name.
+		}
+		if n.children == nil {
+			n.children = map[string]*nameTree{}
+		}
+		child, ok := n.children[labels[i]]
+		if !ok {
+			child = &nameTree{}
+			n.children[labels[i]] = child
+		}
+		n = child
+	}
+	n.terminal = true
+	n.children = nil
+}
+
+// contains returns true if name, or one of its ancestors, is in the tree.
+func (t *nameTree) contains(name string) bool {
+	if t == nil {
+		return false
+	}
+	n := t
+	labels := dns.SplitDomainName(name)
+	for i := len(labels) - 1; i >= 0; i-- {
+		if n.terminal {
+			return true
+		}
+		child, ok := n.children[strings.ToLower(labels[i])]
+		if !ok {
+			return false
+		}
+		n = child
+	}
+	return n.terminal
+}
@@ -0,0 +1,213 @@
+package acl
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestNameTree(t *testing.T) {
+	tree, err := parseNames(strings.NewReader(`# blocked names
+example.org
+ads.example.net.
+Tracker.COM
+a\.b.example.com.
+`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		contains bool
+	}{
+		{"example.org.", true},
+		{"www.example.org.", true},
+		{"a.b.example.org.", true},
+		{"example.net.", false},
+		{"ads.example.net.", true},
+		{"x.ads.example.net.", true},
+		{"www.example.net.", false},
+		{"tracker.com.", true},
+		{"WWW.TRACKER.com.", true},
+		{"org.", false},
+		{".", false},
+		{"badexample.org.", false},
+		{"a\\.b.example.com.", true},
+		{"x.a\\.b.example.com.", true},
+		{"b.example.com.", false},
+		{"a.b.example.com.", false},
+	}
+	for i, tc := range tests {
+		if got := tree.contains(tc.name); got != tc.contains {
+			t.Errorf("Test %d: expected contains(%s) to be %t, got %t", i, tc.name, tc.contains, got)
+		}
+	}
+
+	root, _ := parseNames(strings.NewReader("example.org\n."))
+	if !root.contains("example.net.") {
+		t.Errorf("Expected the root to match all names")
+	}
+}
+
+func TestParseNets(t *testing.T) {
+	tree, err := parseNets(strings.NewReader("10.0.0.0/8\n192.168.1.1 # a single host\n2001:db8::/32\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	for _, ip := range []string{"10.1.2.3", "192.168.1.1", "2001:db8::1"} {
+		if _, ok := tree.GetByIP(net.ParseIP(ip)); !ok {
+			t.Errorf("Expected %s to be contained", ip)
+		}
+	}
+	for _, ip := range []string{"11.0.0.1", "192.168.1.2", "2001:db9::1"} {
+		if _, ok := tree.GetByIP(net.ParseIP(ip)); ok {
+			t.Errorf("Expected %s to not be contained", ip)
+		}
+	}
+
+	for _, in := range []string{"10.0.0.0/33", "not-an-address", "10.0.0.1 10.0.0.2"} {
+		if _, err := parseNets(strings.NewReader(in)); err == nil {
+			t.Errorf("Expected error for %q", in)
+		}
+	}
+}
+
+func TestListUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "block.txt")
+	if err := os.WriteFile(path, []byte("10.0.0.0/8\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := newList(listNet, path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if !l.containsIP(net.ParseIP("10.0.0.1")) || l.containsIP(net.ParseIP("192.168.0.1")) {
+		t.Fatalf("Unexpected contents of the initial list")
+	}
+
+	// Changed contents with an illegal entry, the current list is kept.
+	if err := os.WriteFile(path, []byte("192.168.0.0/16\nfoo\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	l.update()
+	if !l.containsIP(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Expected the list to be kept after a failed reload")
+	}
+
+	if err := os.WriteFile(path, []byte("192.168.0.0/16\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the modification time differs from the previous write.
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	l.update()
+	if l.containsIP(net.ParseIP("10.0.0.1")) || !l.containsIP(net.ParseIP("192.168.0.1")) {
+		t.Errorf("Expected the list to be reloaded")
+	}
+}
+
+func TestACLServeDNSList(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "nets.txt"), []byte("192.168.0.0/16\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "names.txt"), []byte("ads.example.org\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := `acl . {
+		block net-file ` + filepath.Join(dir, "nets.txt") + ` net 10.0.0.1
+		filter name-file ` + filepath.Join(dir, "names.txt") + `
+		reload 0
+	}`
+	a, err := parse(caddy.NewTestController("dns", config))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(a.lists) != 2 || a.lists[0].reload != 0 {
+		t.Fatalf("Expected 2 lists without reload, got %v", a.lists)
+	}
+
+	tests := []struct {
+		domain   string
+		sourceIP string
+		rcode    int
+		ede      bool
+	}{
+		{"www.example.org.", "192.168.1.1", dns.RcodeRefused, true},
+		{"www.example.org.", "10.0.0.1", dns.RcodeRefused, true},
+		{"www.example.org.", "10.0.0.2", dns.RcodeSuccess, false},
+		{"x.ads.example.org.", "10.0.0.2", dns.RcodeSuccess, true},
+	}
+	for i, tc := range tests {
+		w := &testResponseWriter{}
+		w.setRemoteIP(tc.sourceIP)
+		m := new(dns.Msg)
+		m.SetQuestion(tc.domain, dns.TypeA)
+		a.Next = test.NextHandler(dns.RcodeSuccess, nil)
+		a.ServeDNS(context.TODO(), w, m)
+		if w.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.rcode, w.Rcode)
+		}
+		if ede := w.Msg != nil && w.Msg.IsEdns0() != nil; ede != tc.ede {
+			t.Errorf("Test %d: expected extended error to be %t", i, tc.ede)
+		}
+	}
+}
+
+func TestSetupList(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "nets.txt"), []byte("192.168.0.0/16\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bad.txt"), []byte("192.168.0.0/33\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	nets := filepath.Join(dir, "nets.txt")
+
+	tests := []struct {
+		config  string
+		wantErr bool
+	}{
+		{`acl {
+			block net-file ` + nets + `
+		}`, false},
+		{`acl {
+			block net-file ` + nets + ` type A
+			reload 1m
+		}`, false},
+		{`acl {
+			block net-file ` + filepath.Join(dir, "missing.txt") + `
+		}`, true},
+		{`acl {
+			block net-file ` + filepath.Join(dir, "bad.txt") + `
+		}`, true},
+		{`acl {
+			block net-file
+		}`, true},
+		{`acl {
+			reload -1s
+		}`, true},
+		{`acl {
+			reload
+		}`, true},
+		{`acl {
+			reload 1s 2s
+		}`, true},
+	}
+	for i, tc := range tests {
+		ctr := caddy.NewTestController("dns", tc.config)
+		if err := setup(ctr); (err != nil) != tc.wantErr {
+			t.Errorf("Test %d: expected error to be %t, got %v", i, tc.wantErr, err)
+		}
+	}
+}
@@ -2,11 +2,16 @@ package acl
 
 import (
 	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/transport"
 
 	"github.com/infobloxopen/go-trees/iptree"
 	"github.com/miekg/dns"
@@ -31,6 +36,18 @@ func setup(c *caddy.Controller) error {
 		return plugin.Error(pluginName, err)
 	}
 
+	stop := make(chan struct{})
+	c.OnStartup(func() error {
+		for _, l := range a.lists {
+			l.periodicUpdate(stop)
+		}
+		return nil
+	})
+	c.OnShutdown(func() error {
+		close(stop)
+		return nil
+	})
+
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		a.Next = next
 		return a
@@ -46,11 +63,29 @@ func parse(c *caddy.Controller) (ACL, error) {
 		args := c.RemainingArgs()
 		r.zones = plugin.OriginsFromArgsOrServerBlock(args, c.ServerBlockKeys)
 
+		var lists []*list
+		reload := defaultReload
 		for c.NextBlock() {
 			p := policy{}
 
 			action := strings.ToLower(c.Val())
 			switch action {
+			case "reload":
+				if !c.NextArg() {
+					return a, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return a, c.Errf("invalid duration for reload: %q", c.Val())
+				}
+				if d < 0 {
+					return a, c.Errf("invalid negative duration for reload: %q", c.Val())
+				}
+				if c.NextArg() {
+					return a, c.ArgErr()
+				}
+				reload = d
+				continue
 			case "allow":
 				p.action = actionAllow
 			case "block":
@@ -60,7 +95,7 @@ func parse(c *caddy.Controller) (ACL, error) {
 			case "drop":
 				p.action = actionDrop
 			default:
-				return a, c.Errf("unexpected token %q; expect 'allow', 'block', 'filter' or 'drop'", c.Val())
+				return a, c.Errf("unexpected token %q; expect 'allow', 'block', 'filter', 'drop' or 'reload'", c.Val())
 			}
 
 			p.qtypes = make(map[uint16]struct{})
@@ -72,7 +107,7 @@ func parse(c *caddy.Controller) (ACL, error) {
 			remainingTokens := c.RemainingArgs()
 			for len(remainingTokens) > 0 {
 				if !isPreservedIdentifier(remainingTokens[0]) {
-					return a, c.Errf("unexpected token %q; expect 'type | net'", remainingTokens[0])
+					return a, c.Errf("unexpected token %q; expect 'type | net | net-file | name | name-file | regex | edns | transport'", remainingTokens[0])
 				}
 				section := strings.ToLower(remainingTokens[0])
 
@@ -115,8 +150,71 @@ func parse(c *caddy.Controller) (ACL, error) {
 						}
 						p.filter.InplaceInsertNet(source, struct{}{})
 					}
+				case listNet, listName:
+					if section == listNet {
+						hasNetSection = true
+					}
+					for _, token := range tokens {
+						path := token
+						if !filepath.IsAbs(path) {
+							path = filepath.Join(dnsserver.GetConfig(c).Root, path)
+						}
+						l, err := newList(section, path)
+						if err != nil {
+							return a, c.Errf("unable to load %s: %s", section, err)
+						}
+						if section == listNet {
+							p.netLists = append(p.netLists, l)
+						} else {
+							p.nameLists = append(p.nameLists, l)
+						}
+						lists = append(lists, l)
+					}
+				case "name":
+					for _, token := range tokens {
+						name := plugin.Name(token).Normalize()
+						if name == "" {
+							return a, c.Errf("illegal name %q", token)
+						}
+						p.names = append(p.names, name)
+					}
+				case "regex":
+					for _, token := range tokens {
+						re, err := regexp.Compile(token)
+						if err != nil {
+							return a, c.Errf("illegal regular expression %q: %s", token, err)
+						}
+						p.regexes = append(p.regexes, re)
+					}
+				case "edns":
+					for _, token := range tokens {
+						e := ednsOption{}
+						if strings.HasPrefix(token, "!") {
+							e.absent = true
+							token = token[1:]
+						}
+						code, ok := ednsOptions[strings.ToLower(token)]
+						if !ok {
+							n, err := strconv.ParseUint(token, 10, 16)
+							if err != nil {
+								return a, c.Errf("unexpected token %q; expect EDNS0 option name or code", token)
+							}
+							code = uint16(n)
+						}
+						e.code = code
+						p.edns = append(p.edns, e)
+					}
+				case "transport":
+					p.transports = make(map[string]struct{})
+					for _, token := range tokens {
+						trans := strings.ToLower(token)
+						if _, ok := transports[trans]; !ok {
+							return a, c.Errf("unexpected token %q; expect 'udp | tcp | tls | https | quic | grpc'", token)
+						}
+						p.transports[trans] = struct{}{}
+					}
 				default:
-					return a, c.Errf("unexpected token %q; expect 'type | net'", section)
+					return a, c.Errf("unexpected token %q; expect 'type | net | net-file | name | name-file | regex | edns | transport'", section)
 				}
 			}
 
@@ -132,14 +230,38 @@ func parse(c *caddy.Controller) (ACL, error) {
 
 			r.policies = append(r.policies, p)
 		}
+		for _, l := range lists {
+			l.reload = reload
+		}
+		a.lists = append(a.lists, lists...)
 		a.Rules = append(a.Rules, r)
 	}
 	return a, nil
 }
 
 func isPreservedIdentifier(token string) bool {
-	identifier := strings.ToLower(token)
-	return identifier == "type" || identifier == "net"
+	switch strings.ToLower(token) {
+	case "type", "net", listNet, "name", listName, "regex", "edns", "transport":
+		return true
+	}
+	return false
+}
+
+// ednsOptions maps the names usable in an edns section to option codes.
+var ednsOptions = map[string]uint16{
+	"nsid":      dns.EDNS0NSID,
+	"ecs":       dns.EDNS0SUBNET,
+	"subnet":    dns.EDNS0SUBNET,
+	"expire":    dns.EDNS0EXPIRE,
+	"cookie":    dns.EDNS0COOKIE,
+	"keepalive": dns.EDNS0TCPKEEPALIVE,
+	"padding":   dns.EDNS0PADDING,
+	"ede":       dns.EDNS0EDE,
+}
+
+// transports holds the transports usable in a transport section.
+var transports = map[string]struct{}{
+	"udp": {}, "tcp": {}, transport.TLS: {}, transport.HTTPS: {}, transport.QUIC: {}, transport.GRPC: {},
 }
 
 // normalize appends '/32' for any single IPv4 address and '/128' for IPv6.
@@ -220,6 +220,56 @@ func TestSetup(t *testing.T) {
 			}`,
 			true,
 		},
+		{
+			"Name and regex",
+			`acl {
+				allow name internal example.org regex ^www\. net 10.0.0.0/8
+			}`,
+			false,
+		},
+		{
+			"EDNS options",
+			`acl {
+				block edns ecs !cookie 65001
+			}`,
+			false,
+		},
+		{
+			"Transport",
+			`acl {
+				drop type ANY transport udp
+				block transport TLS https quic grpc tcp
+			}`,
+			false,
+		},
+		{
+			"Illegal regex",
+			`acl {
+				block regex [a-z
+			}`,
+			true,
+		},
+		{
+			"Illegal EDNS option",
+			`acl {
+				block edns foo
+			}`,
+			true,
+		},
+		{
+			"Illegal transport",
+			`acl {
+				block transport sctp
+			}`,
+			true,
+		},
+		{
+			"Empty name section",
+			`acl {
+				block name
+			}`,
+			true,
+		},
 		{
 			"Illegal argument 2 IPv6",
 			`acl {
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/plugin/pkg/reuseport"
+
+	"github.com/miekg/dns"
+)
+
+// adminPath is the path of the purge endpoint.
+const adminPath = "/cache/purge"
+
+const (
+	adminTimeout         = 10 * time.Second // read and write timeout of the admin server.
+	adminShutdownTimeout = 5 * time.Second  // maximum time to wait for running purges on shutdown.
+)
+
+// admin is an HTTP server that purges entries from the caches that are registered with it. Multiple
+// caches (i.e. server blocks) can use the same address, they then share a single admin server.
+type admin struct {
+	addr   string
+	srv    *http.Server
+	caches []*Cache
+}
+
+var (
+	adminMu sync.Mutex
+	admins  = map[string]*admin{}
+)
+
+// registerAdmin registers c with the admin server on c.adminAddr, starting it when needed.
+func (c *Cache) registerAdmin() error {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	a, ok := admins[c.adminAddr]
+	if !ok {
+		ln, err := reuseport.Listen("tcp", c.adminAddr)
+		if err != nil {
+			return err
+		}
+		a = &admin{addr: c.adminAddr}
+		mux := http.NewServeMux()
+		mux.HandleFunc(adminPath, a.purge)
+		a.srv = &http.Server{Handler: mux, ReadHeaderTimeout: adminTimeout, ReadTimeout: adminTimeout, WriteTimeout: adminTimeout}
+		srv := a.srv
+		go func() { srv.Serve(ln) }()
+		admins[c.adminAddr] = a
+	}
+	for _, ca := range a.caches {
+		if ca == c {
+			return nil
+		}
+	}
+	a.caches = append(a.caches, c)
+	return nil
+}
+
+// unregisterAdmin removes c from its admin server, the server is stopped when it was the last cache using it.
+func (c *Cache) unregisterAdmin() error {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	a, ok := admins[c.adminAddr]
+	if !ok {
+		return nil
+	}
+	for i, ca := range a.caches {
+		if ca == c {
+			a.caches = append(a.caches[:i], a.caches[i+1:]...)
+			break
+		}
+	}
+	if len(a.caches) > 0 {
+		return nil
+	}
+	delete(admins, c.adminAddr)
+	ctx, cancel := context.WithTimeout(context.Background(), adminShutdownTimeout)
+	defer cancel()
+	return a.srv.Shutdown(ctx)
+}
+
+// purge handles a purge request, the following (query) parameters are supported:
+//
+//   - name: the name to purge, when empty the entire cache is purged.
+//   - type: only purge entries of this type, i.e. "A".
+//   - subtree: when "true", purge name and everything below it.
+func (a *admin) purge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.FormValue("name")
+	if name != "" {
+		if _, ok := dns.IsDomainName(name); !ok {
+			http.Error(w, fmt.Sprintf("invalid name: %q", name), http.StatusBadRequest)
+			return
+		}
+		name = plugin.Name(name).Normalize()
+	}
+	qtype := uint16(0)
+	if t := r.FormValue("type"); t != "" {
+		var ok bool
+		if qtype, ok = dns.StringToType[strings.ToUpper(t)]; !ok {
+			http.Error(w, fmt.Sprintf("invalid type: %q", t), http.StatusBadRequest)
+			return
+		}
+	}
+	subtree := r.FormValue("subtree") == "true"
+	if name == "" && (qtype != 0 || subtree) {
+		http.Error(w, "type and subtree need a name", http.StatusBadRequest)
+		return
+	}
+
+	adminMu.Lock()
+	caches := append([]*Cache(nil), a.caches...)
+	adminMu.Unlock()
+
+	n := 0
+	for _, c := range caches {
+		n += c.Purge(name, qtype, subtree)
+	}
+	fmt.Fprintf(w, "purged %d entries\n", n)
+}
+
+// Purge removes entries from the success and denial cache. When name is empty all entries are
+// removed. Otherwise the entries for name are removed, only of type qtype if that isn't 0, or, when
+// subtree is true, the entries for name and all names below it. Cached NSEC(3) records of the zones
+// involved are dropped as well. Purge returns the number of removed entries.
+func (c *Cache) Purge(name string, qtype uint16, subtree bool) int {
+	match := func(i *item) bool {
+		if name == "" {
+			return true
+		}
+		if qtype != 0 && i.QType != qtype {
+			return false
+		}
+		if subtree {
+			return plugin.Name(name).Matches(i.Name)
+		}
+		return strings.EqualFold(name, i.Name)
+	}
+
+	purge := func(ca *cache.Cache, typ string) int {
+		n := 0
+		ca.Walk(func(items map[uint64]interface{}, key uint64) bool {
+			if i, ok := items[key].(*item); ok && match(i) {
+				delete(items, key)
+				n++
+			}
+			return true
+		})
+		// A purge isn't done by a server, so the server label is empty.
+		purged.WithLabelValues("", typ, c.zonesMetricLabel, c.viewMetricLabel).Add(float64(n))
+		return n
+	}
+
+	n := purge(c.pcache, Success)
+	n += purge(c.ncache, Denial)
+	if c.nsec != nil {
+		c.nsec.purge(name)
+	}
+	if n > 0 {
+		log.Infof("Purged %d entries for %q", n, name)
+	}
+	return n
+}
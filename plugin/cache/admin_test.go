@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newPurgeCache(t *testing.T) *Cache {
+	t.Helper()
+	c := New()
+	c.Next = ttlBackend(60)
+	c.adminAddr = "localhost:0"
+	ctx := context.WithValue(context.TODO(), dnsserver.Key{}, &dnsserver.Server{Addr: "dns://:53"})
+	for _, q := range []struct {
+		name  string
+		qtype uint16
+	}{
+		{"example.org.", dns.TypeA},
+		{"example.org.", dns.TypeAAAA},
+		{"a.example.org.", dns.TypeA},
+		{"b.a.example.org.", dns.TypeA},
+		{"example.net.", dns.TypeA},
+	} {
+		req := new(dns.Msg)
+		req.SetQuestion(q.name, q.qtype)
+		c.ServeDNS(ctx, dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	}
+	if c.pcache.Len() != 5 {
+		t.Fatalf("Expected 5 cached entries, got %d", c.pcache.Len())
+	}
+	return c
+}
+
+func TestPurge(t *testing.T) {
+	tests := []struct {
+		name     string
+		qtype    uint16
+		subtree  bool
+		expected int
+	}{
+		{"", 0, false, 5},
+		{"example.org.", 0, false, 2},
+		{"EXAMPLE.org.", dns.TypeA, false, 1},
+		{"example.org.", dns.TypeMX, false, 0},
+		{"a.example.org.", 0, true, 2},
+		{"example.org.", 0, true, 4},
+		{"org.", dns.TypeA, true, 3},
+		{"example.com.", 0, true, 0},
+	}
+
+	for i, tc := range tests {
+		c := newPurgeCache(t)
+		before := testutil.ToFloat64(purged.WithLabelValues("", Success, c.zonesMetricLabel, c.viewMetricLabel))
+		if n := c.Purge(tc.name, tc.qtype, tc.subtree); n != tc.expected {
+			t.Errorf("Test %d: expected %d purged entries, got %d", i, tc.expected, n)
+		}
+		if l := c.pcache.Len(); l != 5-tc.expected {
+			t.Errorf("Test %d: expected %d entries left, got %d", i, 5-tc.expected, l)
+		}
+		after := testutil.ToFloat64(purged.WithLabelValues("", Success, c.zonesMetricLabel, c.viewMetricLabel))
+		if int(after-before) != tc.expected {
+			t.Errorf("Test %d: expected purged metric to increase by %d, got %v", i, tc.expected, after-before)
+		}
+	}
+}
+
+func TestAdminPurge(t *testing.T) {
+	tests := []struct {
+		method       string
+		query        string
+		expectedCode int
+		expectedLeft int
+	}{
+		{http.MethodPost, "", http.StatusOK, 0},
+		{http.MethodPost, "name=example.org&type=aaaa", http.StatusOK, 4},
+		{http.MethodPost, "name=example.org&subtree=true", http.StatusOK, 1},
+		{http.MethodGet, "name=example.org", http.StatusMethodNotAllowed, 5},
+		{http.MethodPost, "name=example.org&type=BOGUS", http.StatusBadRequest, 5},
+		{http.MethodPost, "type=A", http.StatusBadRequest, 5},
+	}
+
+	for i, tc := range tests {
+		c := newPurgeCache(t)
+		a := &admin{caches: []*Cache{c}}
+
+		req := httptest.NewRequest(tc.method, adminPath+"?"+tc.query, nil)
+		rec := httptest.NewRecorder()
+		a.purge(rec, req)
+
+		if rec.Code != tc.expectedCode {
+			t.Errorf("Test %d: expected status %d, got %d: %s", i, tc.expectedCode, rec.Code, rec.Body.String())
+		}
+		if l := c.pcache.Len(); l != tc.expectedLeft {
+			t.Errorf("Test %d: expected %d entries left, got %d", i, tc.expectedLeft, l)
+		}
+		if tc.expectedCode == http.StatusOK && !strings.HasPrefix(rec.Body.String(), "purged ") {
+			t.Errorf("Test %d: unexpected body: %s", i, rec.Body.String())
+		}
+	}
+}
+
+func TestAdminRegister(t *testing.T) {
+	c1, c2 := New(), New()
+	c1.adminAddr, c2.adminAddr = "127.0.0.1:0", "127.0.0.1:0"
+
+	if err := c1.registerAdmin(); err != nil {
+		t.Fatalf("Failed to register: %s", err)
+	}
+	if err := c2.registerAdmin(); err != nil {
+		t.Fatalf("Failed to register: %s", err)
+	}
+	if l := len(admins[c1.adminAddr].caches); l != 2 {
+		t.Fatalf("Expected 2 caches sharing the admin server, got %d", l)
+	}
+
+	c1.unregisterAdmin()
+	if _, ok := admins[c1.adminAddr]; !ok {
+		t.Fatalf("Expected admin server to still be running")
+	}
+	c2.unregisterAdmin()
+	if _, ok := admins[c1.adminAddr]; ok {
+		t.Fatalf("Expected admin server to be stopped")
+	}
+}
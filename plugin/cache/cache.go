@@ -4,6 +4,7 @@ package cache
 import (
 	"hash/fnv"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
@@ -41,8 +42,9 @@ type Cache struct {
 	percentage int
 
 	// Stale serve
-	staleUpTo   time.Duration
-	verifyStale bool
+	staleUpTo    time.Duration
+	verifyStale  bool
+	staleTimeout time.Duration // if > 0, refresh first and serve stale when that takes longer (RFC 8767 client timeout)
 
 	// Positive/negative zone exceptions
 	pexcept []string
@@ -51,6 +53,18 @@ type Cache struct {
 	// Keep ttl option
 	keepttl bool
 
+	// Save to and load from disk, nil when not enabled.
+	persist *persist
+
+	// Address of the admin (purge) endpoint, empty when not enabled.
+	adminAddr string
+
+	// Extra data to add to the cache key.
+	partitions []partition
+
+	// Validated NSEC(3) records for aggressive negative caching, nil when not enabled.
+	nsec *nsecCache
+
 	// Testing.
 	now func() time.Time
 }
@@ -78,8 +92,8 @@ func New() *Cache {
 
 // key returns key under which we store the item, -1 will be returned if we don't store the message.
 // Currently we do not cache Truncated, errors zone transfers or dynamic update messages.
-// qname holds the already lowercased qname.
-func key(qname string, m *dns.Msg, t response.Type, do, cd bool) (bool, uint64) {
+// qname holds the already lowercased qname, part the partition key of the request.
+func key(qname string, m *dns.Msg, t response.Type, do, cd bool, part string) (bool, uint64) {
 	// We don't store truncated responses.
 	if m.Truncated {
 		return false, 0
@@ -89,13 +103,14 @@ func key(qname string, m *dns.Msg, t response.Type, do, cd bool) (bool, uint64)
 		return false, 0
 	}
 
-	return true, hash(qname, m.Question[0].Qtype, do, cd)
+	return true, hash(qname, m.Question[0].Qtype, do, cd, part)
 }
 
 var one = []byte("1")
 var zero = []byte("0")
+var zeroByte = []byte{0}
 
-func hash(qname string, qtype uint16, do, cd bool) uint64 {
+func hash(qname string, qtype uint16, do, cd bool, part string) uint64 {
 	h := fnv.New64()
 
 	if do {
@@ -113,6 +128,10 @@ func hash(qname string, qtype uint16, do, cd bool) uint64 {
 	h.Write([]byte{byte(qtype >> 8)})
 	h.Write([]byte{byte(qtype)})
 	h.Write([]byte(qname))
+	if part != "" {
+		h.Write(zeroByte)
+		h.Write([]byte(part))
+	}
 	return h.Sum64()
 }
 
@@ -134,10 +153,11 @@ type ResponseWriter struct {
 	state  request.Request
 	server string // Server handling the request.
 
-	do         bool // When true the original request had the DO bit set.
-	cd         bool // When true the original request had the CD bit set.
-	ad         bool // When true the original request had the AD bit set.
-	prefetch   bool // When true write nothing back to the client.
+	do         bool   // When true the original request had the DO bit set.
+	cd         bool   // When true the original request had the CD bit set.
+	part       string // The partition key of the original request.
+	ad         bool   // When true the original request had the AD bit set.
+	prefetch   bool   // When true write nothing back to the client.
 	remoteAddr net.Addr
 
 	wildcardFunc func() string // function to retrieve wildcard name that synthesized the result.
@@ -149,7 +169,7 @@ type ResponseWriter struct {
 // newPrefetchResponseWriter returns a Cache ResponseWriter to be used in
 // prefetch requests. It ensures RemoteAddr() can be called even after the
 // original connection has already been closed.
-func newPrefetchResponseWriter(server string, state request.Request, c *Cache) *ResponseWriter {
+func newPrefetchResponseWriter(server string, state request.Request, part string, c *Cache) *ResponseWriter {
 	// Resolve the address now, the connection might be already closed when the
 	// actual prefetch request is made.
 	addr := state.W.RemoteAddr()
@@ -167,6 +187,7 @@ func newPrefetchResponseWriter(server string, state request.Request, c *Cache) *
 		server:         server,
 		do:             state.Do(),
 		cd:             state.Req.CheckingDisabled,
+		part:           part,
 		prefetch:       true,
 		remoteAddr:     addr,
 	}
@@ -185,7 +206,7 @@ func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
 	mt, _ := response.Typify(res, w.now().UTC())
 
 	// key returns empty string for anything we don't want to cache.
-	hasKey, key := key(w.state.Name(), res, mt, w.do, w.cd)
+	hasKey, key := key(w.state.Name(), res, mt, w.do, w.cd, w.part)
 
 	msgTTL := dnsutil.MinimalTTL(res, mt)
 	var duration time.Duration
@@ -254,6 +275,10 @@ func (w *ResponseWriter) set(m *dns.Msg, key uint64, mt response.Type, duration
 			// zone is in exception list, do not cache
 			return
 		}
+		// Prefetches don't set the exceptions of the writer, use the ones of the cache.
+		if w.nsec != nil && mt != response.ServerError && plugin.Zones(w.Cache.nexcept).Matches(m.Question[0].Name) == "" {
+			w.nsec.add(m, w.part, w.now(), w.nttl)
+		}
 		i := newItem(m, w.now(), duration)
 		if w.wildcardFunc != nil {
 			i.wildcard = w.wildcardFunc()
@@ -306,6 +331,48 @@ func (w *verifyStaleResponseWriter) WriteMsg(res *dns.Msg) error {
 	return nil // else discard
 }
 
+// timeoutStaleResponseWriter is a response writer used when refreshing a stale entry with a client timeout.
+// Like verifyStaleResponseWriter it only stores successful refreshes, these are written to the client
+// as long as the timeout hasn't expired. After that they only update the cache.
+type timeoutStaleResponseWriter struct {
+	*ResponseWriter
+	mu        sync.Mutex
+	expired   bool // set to true when the client got the stale entry.
+	refreshed bool // set to true if a reply was written to the client.
+}
+
+func newTimeoutStaleResponseWriter(w *ResponseWriter) *timeoutStaleResponseWriter {
+	// Resolve the address now, the refresh may outlive the client's connection.
+	w.remoteAddr = w.RemoteAddr()
+	return &timeoutStaleResponseWriter{ResponseWriter: w}
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *timeoutStaleResponseWriter) WriteMsg(res *dns.Msg) error {
+	if res.Rcode != dns.RcodeSuccess && res.Rcode != dns.RcodeNameError {
+		return nil // discard, the stale entry will be served.
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.expired {
+		w.refreshed = true
+	}
+	return w.ResponseWriter.WriteMsg(res) // when expired this only stores to the cache.
+}
+
+// expire is called when the client timeout expires. It returns false if the client already got
+// the refreshed reply, otherwise any later reply will only be stored in the cache.
+func (w *timeoutStaleResponseWriter) expire() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.refreshed {
+		return false
+	}
+	w.expired = true
+	w.prefetch = true
+	return true
+}
+
 const (
 	maxTTL  = dnsutil.MaximumDefaulTTL
 	minTTL  = dnsutil.MinimalDefaultTTL
@@ -314,6 +381,8 @@ const (
 
 	defaultCap = 10000 // default capacity of the cache.
 
+	defaultStaleTimeout = 1800 * time.Millisecond // client response timeout suggested in RFC 8767, section 5.
+
 	// Success is the class for caching positive caching.
 	Success = "success"
 	// Denial is the class defined for negative caching.
@@ -287,7 +287,7 @@ func TestCacheInsertion(t *testing.T) {
 			state := request.Request{W: &test.ResponseWriter{}, Req: m}
 
 			mt, _ := response.Typify(m, utc)
-			valid, k := key(state.Name(), m, mt, state.Do(), state.Req.CheckingDisabled)
+			valid, k := key(state.Name(), m, mt, state.Do(), state.Req.CheckingDisabled, "")
 
 			if valid {
 				// Insert cache entry
@@ -295,7 +295,7 @@ func TestCacheInsertion(t *testing.T) {
 			}
 
 			// Attempt to retrieve cache entry
-			i := c.getIgnoreTTL(time.Now().UTC(), state, "dns://:53")
+			i := c.getIgnoreTTL(time.Now().UTC(), state, "dns://:53", "")
 			found := i != nil
 
 			if !tc.shouldCache && found {
@@ -501,6 +501,92 @@ func TestServeFromStaleCacheFetchVerify(t *testing.T) {
 	}
 }
 
+func TestServeFromStaleCacheClientTimeout(t *testing.T) {
+	c := New()
+	c.Next = ttlBackend(60)
+	c.staleUpTo = 1 * time.Hour
+	c.staleTimeout = 50 * time.Millisecond
+
+	req := new(dns.Msg)
+	req.SetQuestion("cached.org.", dns.TypeA)
+	req.SetEdns0(4096, false)
+	ctx := context.TODO()
+
+	c.ServeDNS(ctx, dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	if c.pcache.Len() != 1 {
+		t.Fatalf("Msg with > 0 TTL should have been cached")
+	}
+
+	written := make(chan struct{}, 1)
+	backend := func(delay time.Duration, rcode int) plugin.Handler {
+		return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			r.Extra = nil // plugins after the cache may change the request
+			time.Sleep(delay)
+			m := new(dns.Msg)
+			m.SetRcode(r, rcode)
+			if rcode == dns.RcodeSuccess {
+				m.Answer = []dns.RR{test.A("cached.org. 200 IN A 127.0.0.53")}
+			}
+			w.WriteMsg(m)
+			written <- struct{}{}
+			return rcode, nil
+		})
+	}
+
+	tests := []struct {
+		delay         time.Duration
+		upstreamRCode int
+		futureMinutes int
+		expectedTtl   uint32
+		expectedStale bool
+		expectedCall  bool
+	}{
+		// Upstream answers in time, the client gets the refreshed answer.
+		{0, dns.RcodeSuccess, 2, 200, false, true},
+		// Upstream fails, the client gets the stale answer.
+		{0, dns.RcodeServerFailure, 10, 0, true, true},
+		// Upstream is too slow, the client gets the stale answer...
+		{200 * time.Millisecond, dns.RcodeSuccess, 10, 0, true, true},
+		// ...but the answer that came in later is cached.
+		{0, dns.RcodeSuccess, 10, 200, false, false},
+	}
+
+	for i, tt := range tests {
+		c.Next = backend(tt.delay, tt.upstreamRCode)
+		c.now = func() time.Time { return time.Now().Add(time.Duration(tt.futureMinutes) * time.Minute) }
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		c.ServeDNS(ctx, rec, req)
+		if ttl := rec.Msg.Answer[0].Header().Ttl; ttl != tt.expectedTtl {
+			t.Errorf("Test %d: expecting TTL %d; got %d", i, tt.expectedTtl, ttl)
+		}
+
+		stale := false
+		if o := rec.Msg.IsEdns0(); o != nil {
+			for _, e := range o.Option {
+				if ede, ok := e.(*dns.EDNS0_EDE); ok && ede.InfoCode == dns.ExtendedErrorCodeStaleAnswer {
+					stale = true
+				}
+			}
+		}
+		if stale != tt.expectedStale {
+			t.Errorf("Test %d: expecting stale answer EDE to be %t; got %t", i, tt.expectedStale, stale)
+		}
+
+		// Let a slow refresh finish before the next test changes c.now.
+		select {
+		case <-written:
+			if !tt.expectedCall {
+				t.Errorf("Test %d: expecting no upstream call", i)
+			}
+		case <-time.After(2*tt.delay + 10*time.Millisecond):
+			if tt.expectedCall {
+				t.Errorf("Test %d: expecting an upstream call", i)
+			}
+		}
+	}
+}
+
 func TestNegativeStaleMaskingPositiveCache(t *testing.T) {
 	c := New()
 	c.staleUpTo = time.Minute * 10
@@ -703,7 +789,7 @@ func TestCacheWildcardMetadata(t *testing.T) {
 	if c.pcache.Len() != 1 {
 		t.Errorf("Msg should have been cached")
 	}
-	_, k := key(qname, w.Msg, response.NoError, state.Do(), state.Req.CheckingDisabled)
+	_, k := key(qname, w.Msg, response.NoError, state.Do(), state.Req.CheckingDisabled, "")
 	i, _ := c.pcache.Get(k)
 	if i.(*item).wildcard != wildcard {
 		t.Errorf("expected wildcard response to enter cache with cache item's wildcard = %q, got %q", wildcard, i.(*item).wildcard)
@@ -860,7 +946,7 @@ func TestCacheSeparation(t *testing.T) {
 			state := request.Request{W: &test.ResponseWriter{}, Req: m}
 
 			mt, _ := response.Typify(m, utc)
-			valid, k := key(state.Name(), m, mt, state.Do(), state.Req.CheckingDisabled)
+			valid, k := key(state.Name(), m, mt, state.Do(), state.Req.CheckingDisabled, "")
 
 			if valid {
 				// Insert cache entry
@@ -872,7 +958,7 @@ func TestCacheSeparation(t *testing.T) {
 			m = cacheMsg(m, tc.query)
 			state = request.Request{W: &test.ResponseWriter{}, Req: m}
 
-			item := c.getIgnoreTTL(time.Now().UTC(), state, "dns://:53")
+			item := c.getIgnoreTTL(time.Now().UTC(), state, "dns://:53", "")
 			found := item != nil
 
 			if !tc.expectCached && found {
@@ -28,15 +28,23 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 
 	now := c.now().UTC()
 	server := metrics.WithServer(ctx)
+	part := c.partitionKey(ctx, state)
 
 	// On cache refresh, we will just use the DO bit from the incoming query for the refresh since we key our cache
 	// with the query DO bit. That means two separate cache items for the query DO bit true or false. In the situation
 	// in which upstream doesn't support DNSSEC, the two cache items will effectively be the same. Regardless, any
 	// DNSSEC RRs in the response are written to cache with the response.
 
-	i := c.getIgnoreTTL(now, state, server)
+	i := c.getIgnoreTTL(now, state, server, part)
+	if i == nil && c.nsec != nil && !cd && plugin.Zones(c.nexcept).Matches(state.Name()) == "" {
+		if resp := c.synthesizeDenial(state, part, now, do, ad); resp != nil {
+			synthesized.WithLabelValues(server, c.zonesMetricLabel, c.viewMetricLabel).Inc()
+			w.WriteMsg(resp)
+			return dns.RcodeSuccess, nil
+		}
+	}
 	if i == nil {
-		crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server, do: do, ad: ad, cd: cd,
+		crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server, do: do, ad: ad, cd: cd, part: part,
 			nexcept: c.nexcept, pexcept: c.pexcept, wildcardFunc: wildcardFunc(ctx)}
 		return c.doRefresh(ctx, state, crr)
 	}
@@ -44,23 +52,29 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 	if ttl < 0 {
 		// serve stale behavior
 		if c.verifyStale {
-			crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server, do: do, cd: cd}
+			crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server, do: do, cd: cd, part: part}
 			cw := newVerifyStaleResponseWriter(crr)
 			ret, err := c.doRefresh(ctx, state, cw)
 			if cw.refreshed {
 				return ret, err
 			}
 		}
+		if c.staleTimeout > 0 {
+			crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server, do: do, cd: cd, part: part}
+			if ret, ok, err := c.doRefreshTimeout(ctx, state, crr); ok {
+				return ret, err
+			}
+		}
 
 		// Adjust the time to get a 0 TTL in the reply built from a stale item.
 		now = now.Add(time.Duration(ttl) * time.Second)
-		if !c.verifyStale {
-			cw := newPrefetchResponseWriter(server, state, c)
+		if !c.verifyStale && c.staleTimeout == 0 {
+			cw := newPrefetchResponseWriter(server, state, part, c)
 			go c.doPrefetch(ctx, state, cw, i, now)
 		}
 		servedStale.WithLabelValues(server, c.zonesMetricLabel, c.viewMetricLabel).Inc()
 	} else if c.shouldPrefetch(i, now) {
-		cw := newPrefetchResponseWriter(server, state, c)
+		cw := newPrefetchResponseWriter(server, state, part, c)
 		go c.doPrefetch(ctx, state, cw, i, now)
 	}
 
@@ -77,10 +91,36 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		now = i.stored
 	}
 	resp := i.toMsg(r, now, do, ad)
+	if ttl < 0 && r.IsEdns0() != nil {
+		// Tell the client this is a stale answer, RFC 8914, section 4.4.
+		resp.SetEdns0(4096, do)
+		resp.IsEdns0().Option = append(resp.IsEdns0().Option, &dns.EDNS0_EDE{InfoCode: dns.ExtendedErrorCodeStaleAnswer})
+	}
 	w.WriteMsg(resp)
 	return dns.RcodeSuccess, nil
 }
 
+// synthesizeDenial returns an NXDOMAIN reply for state built from the cached NSEC(3) records, or nil
+// if these don't prove that the name doesn't exist (RFC 8198).
+func (c *Cache) synthesizeDenial(state request.Request, part string, now time.Time, do, ad bool) *dns.Msg {
+	ra, soa, proof := c.nsec.denial(state.Name(), part, now)
+	if soa == nil {
+		return nil
+	}
+
+	m := new(dns.Msg)
+	m.SetRcode(state.Req, dns.RcodeNameError)
+	m.Authoritative = true // See the comment on item.toMsg.
+	m.RecursionAvailable = ra
+	m.AuthenticatedData = do || ad
+	if do {
+		m.Ns = append(soa, proof...)
+	} else {
+		m.Ns = soa[:1] // Only the SOA, without its signatures.
+	}
+	return m
+}
+
 func wildcardFunc(ctx context.Context) func() string {
 	return func() string {
 		// Get wildcard source record name from metadata
@@ -98,7 +138,7 @@ func (c *Cache) doPrefetch(ctx context.Context, state request.Request, cw *Respo
 	// When prefetching we loose the item i, and with it the frequency
 	// that we've gathered sofar. See we copy the frequencies info back
 	// into the new item that was stored in the cache.
-	if i1 := c.exists(state); i1 != nil {
+	if i1 := c.exists(state, cw.part); i1 != nil {
 		i1.Reset(now, i.Hits())
 	}
 }
@@ -107,6 +147,39 @@ func (c *Cache) doRefresh(ctx context.Context, state request.Request, cw dns.Res
 	return plugin.NextOrFailure(c.Name(), c.Next, ctx, cw, state.Req)
 }
 
+// doRefreshTimeout refreshes a stale entry, but gives up waiting after c.staleTimeout (RFC 8767, section 5).
+// The refresh itself continues in the background and still updates the cache. It returns true when
+// the refreshed reply was written to the client, when false the stale entry should be served.
+func (c *Cache) doRefreshTimeout(ctx context.Context, state request.Request, crr *ResponseWriter) (int, bool, error) {
+	cw := newTimeoutStaleResponseWriter(crr)
+
+	type result struct {
+		ret int
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		ret, err := c.doRefresh(ctx, state, cw)
+		done <- result{ret, err}
+	}()
+
+	timer := time.NewTimer(c.staleTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		if cw.refreshed {
+			return r.ret, true, r.err
+		}
+		return 0, false, nil
+	case <-timer.C:
+		if cw.expire() {
+			return 0, false, nil
+		}
+		// The reply was written to the client just now.
+		return dns.RcodeSuccess, true, nil
+	}
+}
+
 func (c *Cache) shouldPrefetch(i *item, now time.Time) bool {
 	if c.prefetch <= 0 {
 		return false
@@ -120,8 +193,8 @@ func (c *Cache) shouldPrefetch(i *item, now time.Time) bool {
 func (c *Cache) Name() string { return "cache" }
 
 // getIgnoreTTL unconditionally returns an item if it exists in the cache.
-func (c *Cache) getIgnoreTTL(now time.Time, state request.Request, server string) *item {
-	k := hash(state.Name(), state.QType(), state.Do(), state.Req.CheckingDisabled)
+func (c *Cache) getIgnoreTTL(now time.Time, state request.Request, server, part string) *item {
+	k := hash(state.Name(), state.QType(), state.Do(), state.Req.CheckingDisabled, part)
 	cacheRequests.WithLabelValues(server, c.zonesMetricLabel, c.viewMetricLabel).Inc()
 
 	if i, ok := c.ncache.Get(k); ok {
@@ -144,8 +217,8 @@ func (c *Cache) getIgnoreTTL(now time.Time, state request.Request, server string
 	return nil
 }
 
-func (c *Cache) exists(state request.Request) *item {
-	k := hash(state.Name(), state.QType(), state.Do(), state.Req.CheckingDisabled)
+func (c *Cache) exists(state request.Request, part string) *item {
+	k := hash(state.Name(), state.QType(), state.Do(), state.Req.CheckingDisabled, part)
 	if i, ok := c.ncache.Get(k); ok {
 		return i.(*item)
 	}
@@ -64,4 +64,18 @@ var (
 		Name:      "evictions_total",
 		Help:      "The count of cache evictions.",
 	}, []string{"server", "type", "zones", "view"})
+	// purged is the counter of entries removed via the admin endpoint.
+	purged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "purged_total",
+		Help:      "The count of cache entries removed by a purge.",
+	}, []string{"server", "type", "zones", "view"})
+	// synthesized is the counter of NXDOMAIN responses synthesized from cached NSEC(3) records.
+	synthesized = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "nsec_synthesized_total",
+		Help:      "The count of NXDOMAIN responses synthesized from cached NSEC or NSEC3 records.",
+	}, []string{"server", "zones", "view"})
 )
@@ -0,0 +1,455 @@
+package cache
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// nsecCache holds the NSEC and NSEC3 records from validated denial of existence responses. These
+// are used to synthesize NXDOMAIN responses for other names that are covered by them, without
+// asking upstream (aggressive negative caching, RFC 8198).
+type nsecCache struct {
+	sync.RWMutex
+	zones map[string]*nsecZone // keyed by the partition key and the zone name.
+	size  int                  // number of NSEC(3) records in all zones.
+	max   int
+}
+
+// nsecZone holds the denial records for a single zone.
+type nsecZone struct {
+	name  string
+	soa   *nsecRecord
+	ra    bool
+	nsec  []*nsecRecord // sorted in canonical order of the owner names.
+	nsec3 []*nsecRecord // sorted on the owner hash, all with the same hash parameters.
+}
+
+// nsecRecord is a record together with its signatures.
+type nsecRecord struct {
+	rr     dns.RR
+	sigs   []dns.RR
+	expire time.Time
+	hash   string // the owner hash (upper case) of an NSEC3 record.
+}
+
+// maxNSEC3Iterations is the highest number of NSEC3 iterations we synthesize from. Hashing is
+// done for every query that isn't in the cache, so this limits the work (RFC 9276, section 3.2).
+const maxNSEC3Iterations = 50
+
+func newNSECCache(max int) *nsecCache {
+	return &nsecCache{zones: make(map[string]*nsecZone), max: max}
+}
+
+// add stores the NSEC and NSEC3 records from the authority section of m, which must be a validated
+// (AD bit set) denial of existence response, for the partition part. Records without signatures are
+// ignored. The records are kept no longer than the negative TTL of the zone (RFC 9077), capped by maxTTL.
+func (n *nsecCache) add(m *dns.Msg, part string, now time.Time, maxTTL time.Duration) {
+	if !m.AuthenticatedData {
+		return
+	}
+
+	var soa *dns.SOA
+	sigs := make(map[string][]dns.RR)
+	for _, r := range m.Ns {
+		switch x := r.(type) {
+		case *dns.SOA:
+			soa = x
+		case *dns.RRSIG:
+			k := strings.ToLower(x.Hdr.Name) + dns.TypeToString[x.TypeCovered]
+			sigs[k] = append(sigs[k], x)
+		}
+	}
+	if soa == nil {
+		return
+	}
+	zone := strings.ToLower(soa.Hdr.Name)
+
+	ttl := min(soa.Hdr.Ttl, soa.Minttl, uint32(maxTTL.Seconds()))
+	signed := func(r dns.RR) []dns.RR {
+		s := sigs[strings.ToLower(r.Header().Name)+dns.TypeToString[r.Header().Rrtype]]
+		for _, sig := range s {
+			if !strings.EqualFold(sig.(*dns.RRSIG).SignerName, zone) {
+				return nil
+			}
+		}
+		return s
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	soaSigs := signed(soa)
+	if len(soaSigs) == 0 {
+		return
+	}
+	z, ok := n.zones[part+zone]
+	if !ok {
+		z = &nsecZone{name: zone}
+		n.zones[part+zone] = z
+	}
+	z.soa = &nsecRecord{rr: soa, sigs: soaSigs, expire: now.Add(time.Duration(ttl) * time.Second)}
+	z.ra = m.RecursionAvailable
+
+	for _, r := range m.Ns {
+		t := r.Header().Rrtype
+		if t != dns.TypeNSEC && t != dns.TypeNSEC3 {
+			continue
+		}
+		if !dns.IsSubDomain(zone, r.Header().Name) || strings.Contains(r.Header().Name, "\\") {
+			continue
+		}
+		s := signed(r)
+		if len(s) == 0 {
+			continue
+		}
+		rec := &nsecRecord{rr: r, sigs: s, expire: now.Add(time.Duration(min(r.Header().Ttl, ttl)) * time.Second)}
+		switch x := r.(type) {
+		case *dns.NSEC:
+			if strings.Contains(x.NextDomain, "\\") {
+				continue
+			}
+			n.size += z.addNSEC(rec)
+		case *dns.NSEC3:
+			// The owner is the hash directly below the zone.
+			if x.Hash != dns.SHA1 || x.Iterations > maxNSEC3Iterations || dns.CountLabel(x.Hdr.Name) != dns.CountLabel(zone)+1 {
+				continue
+			}
+			n.size += z.addNSEC3(rec)
+		}
+	}
+	if n.size > n.max {
+		n.evict(now)
+	}
+}
+
+// addNSEC adds rec to z, replacing an earlier record with the same owner name. It returns the number
+// of records that were added.
+func (z *nsecZone) addNSEC(rec *nsecRecord) int {
+	owner := rec.rr.Header().Name
+	i := sort.Search(len(z.nsec), func(i int) bool { return canonicalCompare(z.nsec[i].rr.Header().Name, owner) >= 0 })
+	if i < len(z.nsec) && canonicalCompare(z.nsec[i].rr.Header().Name, owner) == 0 {
+		z.nsec[i] = rec
+		return 0
+	}
+	z.nsec = append(z.nsec, nil)
+	copy(z.nsec[i+1:], z.nsec[i:])
+	z.nsec[i] = rec
+	return 1
+}
+
+// addNSEC3 adds rec to z, replacing an earlier record with the same owner name. When the hash parameters
+// of rec differ from the ones of the records in z, these are replaced by rec. It returns the number of
+// records that were added, which is negative when more were removed.
+func (z *nsecZone) addNSEC3(rec *nsecRecord) int {
+	x := rec.rr.(*dns.NSEC3)
+	rec.hash = strings.ToUpper(x.Hdr.Name[:strings.IndexByte(x.Hdr.Name, '.')])
+
+	if len(z.nsec3) > 0 {
+		if p := z.nsec3[0].rr.(*dns.NSEC3); p.Iterations != x.Iterations || !strings.EqualFold(p.Salt, x.Salt) {
+			removed := len(z.nsec3)
+			z.nsec3 = []*nsecRecord{rec}
+			return 1 - removed
+		}
+	}
+	i := sort.Search(len(z.nsec3), func(i int) bool { return z.nsec3[i].hash >= rec.hash })
+	if i < len(z.nsec3) && z.nsec3[i].hash == rec.hash {
+		z.nsec3[i] = rec
+		return 0
+	}
+	z.nsec3 = append(z.nsec3, nil)
+	copy(z.nsec3[i+1:], z.nsec3[i:])
+	z.nsec3[i] = rec
+	return 1
+}
+
+// evict removes the expired records, if that isn't enough random zones are removed until we're below max.
+func (n *nsecCache) evict(now time.Time) {
+	expired := func(recs []*nsecRecord) []*nsecRecord {
+		j := 0
+		for _, r := range recs {
+			if r.expire.After(now) {
+				recs[j] = r
+				j++
+			}
+		}
+		n.size -= len(recs) - j
+		return recs[:j]
+	}
+	for name, z := range n.zones {
+		z.nsec = expired(z.nsec)
+		z.nsec3 = expired(z.nsec3)
+		if len(z.nsec) == 0 && len(z.nsec3) == 0 {
+			delete(n.zones, name)
+		}
+	}
+	for name, z := range n.zones {
+		if n.size <= n.max {
+			return
+		}
+		n.size -= len(z.nsec) + len(z.nsec3)
+		delete(n.zones, name)
+	}
+}
+
+// purge removes all records for the zones name is in or below name, or all zones when name is empty.
+func (n *nsecCache) purge(name string) {
+	n.Lock()
+	defer n.Unlock()
+	for k, z := range n.zones {
+		if name == "" || dns.IsSubDomain(name, z.name) || dns.IsSubDomain(z.name, name) {
+			n.size -= len(z.nsec) + len(z.nsec3)
+			delete(n.zones, k)
+		}
+	}
+}
+
+// denial returns the SOA and the records of the partition part that prove that qname doesn't exist, or
+// nil if the cached records can't prove that. The returned records are copies with their TTL set to the
+// remaining TTL.
+func (n *nsecCache) denial(qname, part string, now time.Time) (ra bool, soa []dns.RR, proof []dns.RR) {
+	if strings.Contains(qname, "\\") {
+		return false, nil, nil
+	}
+
+	n.RLock()
+	defer n.RUnlock()
+
+	// Find the closest zone we have records for.
+	var z *nsecZone
+	zone := ""
+	for _, a := range append([]string{qname}, ancestors(qname)...) {
+		if z = n.zones[part+strings.ToLower(a)]; z != nil {
+			zone = a
+			break
+		}
+	}
+	if z == nil || z.soa == nil || !z.soa.expire.After(now) {
+		return false, nil, nil
+	}
+
+	var recs []*nsecRecord
+	if len(z.nsec) > 0 {
+		recs = z.nsecDenial(qname, zone, now)
+	}
+	if recs == nil && len(z.nsec3) > 0 {
+		recs = z.nsec3Denial(qname, zone, now)
+	}
+	if recs == nil {
+		return false, nil, nil
+	}
+
+	expire := z.soa.expire
+	for _, r := range recs {
+		if r.expire.Before(expire) {
+			expire = r.expire
+		}
+	}
+	ttl := uint32(expire.Sub(now).Seconds())
+
+	soa = z.soa.copy(ttl)
+	seen := make(map[*nsecRecord]bool)
+	for _, r := range recs {
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		proof = append(proof, r.copy(ttl)...)
+	}
+	return z.ra, soa, proof
+}
+
+// copy returns a copy of the record and its signatures with the TTL set to ttl.
+func (r *nsecRecord) copy(ttl uint32) []dns.RR {
+	rrs := make([]dns.RR, 0, 1+len(r.sigs))
+	for _, x := range append([]dns.RR{r.rr}, r.sigs...) {
+		c := dns.Copy(x)
+		c.Header().Ttl = ttl
+		rrs = append(rrs, c)
+	}
+	return rrs
+}
+
+// nsecDenial returns the NSEC records proving qname and the wildcard at its closest encloser don't
+// exist (RFC 4035, section 5.4).
+func (z *nsecZone) nsecDenial(qname, zone string, now time.Time) []*nsecRecord {
+	covers := func(name string) *nsecRecord {
+		// The record with the largest owner name that sorts before name.
+		i := sort.Search(len(z.nsec), func(i int) bool { return canonicalCompare(z.nsec[i].rr.Header().Name, name) >= 0 })
+		if i == 0 {
+			return nil
+		}
+		r := z.nsec[i-1]
+		if !r.expire.After(now) {
+			return nil
+		}
+		nsec := r.rr.(*dns.NSEC)
+		// When next is the apex this is the last NSEC in the zone and it covers everything after its owner.
+		if !strings.EqualFold(nsec.NextDomain, zone) && canonicalCompare(name, nsec.NextDomain) >= 0 {
+			return nil
+		}
+		// If next is below name, name is an empty non-terminal and does exist.
+		if dns.IsSubDomain(name, nsec.NextDomain) {
+			return nil
+		}
+		// Records below a delegation or DNAME aren't covered by the parent's NSEC.
+		if dns.IsSubDomain(nsec.Hdr.Name, name) && delegates(nsec.TypeBitMap) {
+			return nil
+		}
+		return r
+	}
+
+	r := covers(qname)
+	if r == nil {
+		return nil
+	}
+	nsec := r.rr.(*dns.NSEC)
+
+	// The closest encloser is the longest ancestor of qname shared with the owner or next name.
+	ce := commonAncestor(qname, nsec.Hdr.Name)
+	if c := commonAncestor(qname, nsec.NextDomain); dns.CountLabel(c) > dns.CountLabel(ce) {
+		ce = c
+	}
+	if !dns.IsSubDomain(zone, ce) {
+		return nil
+	}
+	w := covers(wildcard(ce))
+	if w == nil {
+		return nil
+	}
+	return []*nsecRecord{r, w}
+}
+
+// nsec3Denial returns the NSEC3 records for the closest encloser proof of qname and the record
+// proving the wildcard at the closest encloser doesn't exist (RFC 5155, section 8.4). Every name
+// is hashed once.
+func (z *nsecZone) nsec3Denial(qname, zone string, now time.Time) []*nsecRecord {
+	p := z.nsec3[0].rr.(*dns.NSEC3)
+	hash := func(name string) string { return dns.HashName(name, p.Hash, p.Iterations, p.Salt) }
+
+	// search returns the index of the first record with an owner hash >= h and true if it is equal to h.
+	search := func(h string) (int, bool) {
+		i := sort.Search(len(z.nsec3), func(i int) bool { return z.nsec3[i].hash >= h })
+		return i, i < len(z.nsec3) && z.nsec3[i].hash == h
+	}
+	match := func(h string) *nsecRecord {
+		if i, ok := search(h); ok && z.nsec3[i].expire.After(now) {
+			return z.nsec3[i]
+		}
+		return nil
+	}
+	cover := func(h string) *nsecRecord {
+		i, ok := search(h)
+		if ok {
+			return nil
+		}
+		// The record before h, or the last one, whose next hash wraps around to the first.
+		r := z.nsec3[(i+len(z.nsec3)-1)%len(z.nsec3)]
+		if !r.expire.After(now) {
+			return nil
+		}
+		next := strings.ToUpper(r.rr.(*dns.NSEC3).NextDomain)
+		if r.hash < next {
+			if h <= r.hash || h >= next {
+				return nil
+			}
+		} else if h <= r.hash && h >= next {
+			return nil
+		}
+		return r
+	}
+
+	// Start with the parent of qname: qname itself must not exist.
+	nc := hash(qname) // hash of the next closer name
+	for _, ce := range ancestors(qname) {
+		if !dns.IsSubDomain(zone, ce) {
+			return nil
+		}
+		h := hash(ce)
+		m := match(h)
+		if m == nil {
+			nc = h
+			continue
+		}
+		if delegates(m.rr.(*dns.NSEC3).TypeBitMap) {
+			return nil
+		}
+		next := cover(nc)
+		// With opt-out there may be an insecure delegation in the covered range.
+		if next == nil || next.rr.(*dns.NSEC3).Flags&1 == 1 {
+			return nil
+		}
+		w := cover(hash(wildcard(ce)))
+		if w == nil {
+			return nil
+		}
+		return []*nsecRecord{m, next, w}
+	}
+	return nil
+}
+
+// delegates returns true if the type bitmap belongs to a delegation point or a DNAME.
+func delegates(bitmap []uint16) bool {
+	ns, soa := false, false
+	for _, t := range bitmap {
+		switch t {
+		case dns.TypeNS:
+			ns = true
+		case dns.TypeSOA:
+			soa = true
+		case dns.TypeDNAME:
+			return true
+		}
+	}
+	return ns && !soa
+}
+
+// wildcard returns the wildcard name directly below name.
+func wildcard(name string) string {
+	if name == "." {
+		return "*."
+	}
+	return "*." + name
+}
+
+// ancestors returns the names above name, ending with the root.
+func ancestors(name string) []string {
+	labels := dns.Split(name)
+	a := make([]string, 0, len(labels))
+	for i := 1; i < len(labels); i++ {
+		a = append(a, name[labels[i]:])
+	}
+	if name != "." {
+		a = append(a, ".")
+	}
+	return a
+}
+
+// commonAncestor returns the longest common ancestor of a and b.
+func commonAncestor(a, b string) string {
+	n := dns.CompareDomainName(a, b)
+	labels := dns.Split(a)
+	if n >= len(labels) {
+		return a
+	}
+	if n == 0 {
+		return "."
+	}
+	return a[labels[len(labels)-n]:]
+}
+
+// canonicalCompare compares a and b in canonical DNS name order (RFC 4034, section 6.1). Escaped
+// names are not supported.
+func canonicalCompare(a, b string) int {
+	la := dns.SplitDomainName(strings.ToLower(a))
+	lb := dns.SplitDomainName(strings.ToLower(b))
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := strings.Compare(la[i], lb[j]); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
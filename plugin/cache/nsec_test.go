@@ -0,0 +1,307 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func sig(rr dns.RR) dns.RR {
+	return test.RRSIG(fmt.Sprintf("%s %d IN RRSIG %s 8 2 3600 20300101000000 20200101000000 12345 example.org. c2ln",
+		rr.Header().Name, rr.Header().Ttl, dns.TypeToString[rr.Header().Rrtype]))
+}
+
+// nsecDenialMsg returns a validated NXDOMAIN response for qname, with the given denial records in the authority section.
+func nsecDenialMsg(qname string, ad bool, rrs ...dns.RR) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(qname, dns.TypeA)
+	m.Response, m.RecursionAvailable, m.AuthenticatedData = true, true, ad
+	m.Rcode = dns.RcodeNameError
+	soa := test.SOA("example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 2024010101 7200 3600 1209600 300")
+	m.Ns = []dns.RR{soa, sig(soa)}
+	for _, rr := range rrs {
+		m.Ns = append(m.Ns, rr, sig(rr))
+	}
+	return m
+}
+
+func denialBackend(m *dns.Msg) plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := m.Copy()
+		m.Id = r.Id
+		m.Question = r.Question
+		m.SetEdns0(4096, true)
+		w.WriteMsg(m)
+		return m.Rcode, nil
+	})
+}
+
+func TestAggressiveNSEC(t *testing.T) {
+	m := nsecDenialMsg("b.example.org.", true,
+		test.NSEC("example.org. 3600 IN NSEC a.example.org. SOA NS RRSIG NSEC"),
+		test.NSEC("a.example.org. 3600 IN NSEC d.example.org. A RRSIG NSEC"),
+		test.NSEC("d.example.org. 3600 IN NSEC example.org. A RRSIG NSEC"),
+	)
+
+	tests := []struct {
+		qname       string
+		synthesized bool
+		expectedNs  int
+	}{
+		// SOA, covering NSEC and wildcard NSEC, all signed.
+		{"c.example.org.", true, 6},
+		{"e.example.org.", true, 6},   // after the last NSEC
+		{"x.a.example.org.", true, 4}, // below an existing name, one NSEC covers both
+		{"a.example.org.", false, 0},  // exists
+		{"d.example.org.", false, 0},  // exists
+		{"example.org.", false, 0},    // apex
+		{"c.example.net.", false, 0},  // other zone
+		{"c.EXAMPLE.org.", true, 6},
+	}
+
+	c := New()
+	c.nsec = newNSECCache(defaultCap)
+	c.Next = denialBackend(m)
+
+	req := new(dns.Msg)
+	req.SetQuestion("b.example.org.", dns.TypeA)
+	req.SetEdns0(4096, true)
+	c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+
+	c.Next = plugin.HandlerFunc(func(context.Context, dns.ResponseWriter, *dns.Msg) (int, error) {
+		return 255, nil // Below, a 255 means we tried querying upstream.
+	})
+	for i, tc := range tests {
+		req := new(dns.Msg)
+		req.SetQuestion(tc.qname, dns.TypeA)
+		req.SetEdns0(4096, true)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		ret, _ := c.ServeDNS(context.TODO(), rec, req)
+		if synthesized := ret != 255; synthesized != tc.synthesized {
+			t.Errorf("Test %d: expected synthesized to be %t for %s", i, tc.synthesized, tc.qname)
+			continue
+		}
+		if !tc.synthesized {
+			continue
+		}
+		if rec.Msg.Rcode != dns.RcodeNameError || !rec.Msg.AuthenticatedData {
+			t.Errorf("Test %d: expected validated NXDOMAIN, got %s", i, rec.Msg)
+		}
+		if len(rec.Msg.Ns) != tc.expectedNs {
+			t.Errorf("Test %d: expected %d records in the authority section, got %d", i, tc.expectedNs, len(rec.Msg.Ns))
+		}
+	}
+}
+
+func TestAggressiveNSECNoDO(t *testing.T) {
+	now := time.Now()
+	c := New()
+	c.now = func() time.Time { return now }
+	c.nsec = newNSECCache(defaultCap)
+	c.nsec.add(nsecDenialMsg("b.example.org.", true,
+		test.NSEC("example.org. 3600 IN NSEC d.example.org. SOA NS RRSIG NSEC"),
+	), "", now, maxNTTL)
+	c.Next = plugin.HandlerFunc(func(context.Context, dns.ResponseWriter, *dns.Msg) (int, error) {
+		return 255, nil
+	})
+
+	req := new(dns.Msg)
+	req.SetQuestion("c.example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if ret, _ := c.ServeDNS(context.TODO(), rec, req); ret == 255 {
+		t.Fatalf("Expected a synthesized reply")
+	}
+	if len(rec.Msg.Ns) != 1 || rec.Msg.Ns[0].Header().Rrtype != dns.TypeSOA {
+		t.Errorf("Expected only the SOA in the authority section, got %v", rec.Msg.Ns)
+	}
+	if rec.Msg.AuthenticatedData {
+		t.Errorf("Expected AD bit to be unset")
+	}
+	if ttl := rec.Msg.Ns[0].Header().Ttl; ttl != 300 {
+		t.Errorf("Expected TTL of SOA minimum (300), got %d", ttl)
+	}
+
+	// With CD set we don't synthesize.
+	req.CheckingDisabled = true
+	if ret, _ := c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req); ret != 255 {
+		t.Errorf("Expected the query to go upstream with CD set")
+	}
+}
+
+func TestAggressiveNSECNotValidated(t *testing.T) {
+	n := newNSECCache(defaultCap)
+	n.add(nsecDenialMsg("b.example.org.", false,
+		test.NSEC("example.org. 3600 IN NSEC d.example.org. SOA NS RRSIG NSEC"),
+	), "", time.Now(), maxNTTL)
+	if n.size != 0 {
+		t.Errorf("Expected records from a not validated response to be ignored")
+	}
+
+	// Unsigned NSEC records are ignored as well.
+	m := nsecDenialMsg("b.example.org.", true)
+	m.Ns = append(m.Ns, test.NSEC("example.org. 3600 IN NSEC d.example.org. SOA NS RRSIG NSEC"))
+	n.add(m, "", time.Now(), maxNTTL)
+	if n.size != 0 {
+		t.Errorf("Expected unsigned records to be ignored")
+	}
+}
+
+func TestAggressiveNSECExpire(t *testing.T) {
+	n := newNSECCache(defaultCap)
+	now := time.Now()
+	n.add(nsecDenialMsg("b.example.org.", true,
+		test.NSEC("example.org. 3600 IN NSEC d.example.org. SOA NS RRSIG NSEC"),
+	), "", now, maxNTTL)
+
+	if _, soa, _ := n.denial("c.example.org.", "", now.Add(299*time.Second)); soa == nil {
+		t.Errorf("Expected denial within the negative TTL")
+	}
+	if _, soa, _ := n.denial("c.example.org.", "", now.Add(301*time.Second)); soa != nil {
+		t.Errorf("Expected no denial after the negative TTL")
+	}
+}
+
+func TestAggressiveNSEC3(t *testing.T) {
+	apex := dns.HashName("example.org.", dns.SHA1, 0, "")
+	nsec3 := func(flags uint8) dns.RR {
+		// A single NSEC3 record covers all hashes but its own.
+		rr, _ := dns.NewRR(fmt.Sprintf("%s.example.org. 3600 IN NSEC3 1 %d 0 - %s SOA NS RRSIG DNSKEY NSEC3PARAM", strings.ToLower(apex), flags, apex))
+		return rr
+	}
+
+	tests := []struct {
+		flags       uint8
+		qname       string
+		synthesized bool
+	}{
+		{0, "c.example.org.", true},
+		{0, "x.c.example.org.", true},
+		{0, "example.org.", false},
+		{1, "c.example.org.", false}, // opt-out
+	}
+	for i, tc := range tests {
+		n := newNSECCache(defaultCap)
+		n.add(nsecDenialMsg("b.example.org.", true, nsec3(tc.flags)), "", time.Now(), maxNTTL)
+
+		_, soa, proof := n.denial(tc.qname, "", time.Now())
+		if synthesized := soa != nil; synthesized != tc.synthesized {
+			t.Errorf("Test %d: expected synthesized to be %t for %s", i, tc.synthesized, tc.qname)
+			continue
+		}
+		if tc.synthesized && len(proof) != 2 {
+			t.Errorf("Test %d: expected the NSEC3 record and its signature, got %v", i, proof)
+		}
+	}
+}
+
+func TestAggressiveNSEC3Chain(t *testing.T) {
+	nsec3 := func(iter int, owner, next string) dns.RR {
+		o, n := dns.HashName(owner, dns.SHA1, uint16(iter), "AB"), dns.HashName(next, dns.SHA1, uint16(iter), "AB")
+		rr, _ := dns.NewRR(fmt.Sprintf("%s.example.org. 3600 IN NSEC3 1 0 %d AB %s A RRSIG", strings.ToLower(o), iter, n))
+		return rr
+	}
+	// Two records that cover all hashes but the ones of the apex and a.example.org., in either order.
+	apex, a := "example.org.", "a.example.org."
+	if dns.HashName(apex, dns.SHA1, 1, "AB") > dns.HashName(a, dns.SHA1, 1, "AB") {
+		apex, a = a, apex
+	}
+
+	tests := []struct {
+		qname       string
+		synthesized bool
+	}{
+		{"c.example.org.", true},
+		{"x.a.example.org.", true},
+		{"a.example.org.", false},
+		{"example.org.", false},
+	}
+	n := newNSECCache(defaultCap)
+	n.add(nsecDenialMsg("b.example.org.", true, nsec3(1, a, apex), nsec3(1, apex, a)), "", time.Now(), maxNTTL)
+	if n.size != 2 {
+		t.Fatalf("Expected 2 records, got %d", n.size)
+	}
+	for i, tc := range tests {
+		_, soa, _ := n.denial(tc.qname, "", time.Now())
+		if synthesized := soa != nil; synthesized != tc.synthesized {
+			t.Errorf("Test %d: expected synthesized to be %t for %s", i, tc.synthesized, tc.qname)
+		}
+	}
+
+	// Records with other hash parameters replace the chain, too many iterations are ignored.
+	n.add(nsecDenialMsg("b.example.org.", true, nsec3(2, apex, apex)), "", time.Now(), maxNTTL)
+	if n.size != 1 {
+		t.Errorf("Expected 1 record, got %d", n.size)
+	}
+	n = newNSECCache(defaultCap)
+	n.add(nsecDenialMsg("b.example.org.", true, nsec3(maxNSEC3Iterations+1, apex, apex)), "", time.Now(), maxNTTL)
+	if n.size != 0 {
+		t.Errorf("Expected records with %d iterations to be ignored", maxNSEC3Iterations+1)
+	}
+}
+
+func TestAggressiveNSECPartition(t *testing.T) {
+	n := newNSECCache(defaultCap)
+	n.add(nsecDenialMsg("b.example.org.", true,
+		test.NSEC("example.org. 3600 IN NSEC d.example.org. SOA NS RRSIG NSEC"),
+	), "a\x00", time.Now(), maxNTTL)
+
+	if _, soa, _ := n.denial("c.example.org.", "a\x00", time.Now()); soa == nil {
+		t.Errorf("Expected denial in the same partition")
+	}
+	if _, soa, _ := n.denial("c.example.org.", "b\x00", time.Now()); soa != nil {
+		t.Errorf("Expected no denial in another partition")
+	}
+	n.purge("example.org.")
+	if n.size != 0 || len(n.zones) != 0 {
+		t.Errorf("Expected purge to remove the zone in all partitions")
+	}
+}
+
+func TestAggressiveNSECExcept(t *testing.T) {
+	c := New()
+	c.nsec = newNSECCache(defaultCap)
+	c.nexcept = []string{"example.org."}
+	m := nsecDenialMsg("b.example.org.", true,
+		test.NSEC("example.org. 3600 IN NSEC d.example.org. SOA NS RRSIG NSEC"),
+	)
+	c.Next = denialBackend(m)
+
+	req := new(dns.Msg)
+	req.SetQuestion("b.example.org.", dns.TypeA)
+	req.SetEdns0(4096, true)
+	c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	if c.nsec.size != 0 {
+		t.Errorf("Expected no records to be cached for an excepted zone")
+	}
+
+	// Records added before the zone was excepted aren't used either.
+	c.nsec.add(m, "", time.Now(), maxNTTL)
+	c.Next = plugin.HandlerFunc(func(context.Context, dns.ResponseWriter, *dns.Msg) (int, error) {
+		return 255, nil
+	})
+	req.SetQuestion("c.example.org.", dns.TypeA)
+	if ret, _ := c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req); ret != 255 {
+		t.Errorf("Expected the query to go upstream for an excepted zone")
+	}
+}
+
+func TestCanonicalCompare(t *testing.T) {
+	// Ordered as in RFC 4034, section 6.1, without the escaped names as these aren't supported.
+	names := []string{"example.", "a.example.", "yljkjljk.a.example.", "Z.a.example.", "zABC.a.EXAMPLE.", "z.example.", "*.z.example."}
+	for i := 0; i < len(names)-1; i++ {
+		if canonicalCompare(names[i], names[i+1]) >= 0 {
+			t.Errorf("Expected %s to sort before %s", names[i], names[i+1])
+		}
+	}
+	if canonicalCompare("A.example.", "a.EXAMPLE.") != 0 {
+		t.Errorf("Expected names to be equal")
+	}
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	partitionView     = "view"
+	partitionMetadata = "metadata"
+	partitionECS      = "ecs"
+
+	defaultECSv4 = 24
+	defaultECSv6 = 56
+)
+
+// partition adds data that identifies the client to the cache key, so that clients that get different
+// answers, i.e. from another view or an ECS aware upstream, don't see each other's cached replies.
+type partition struct {
+	typ   string
+	label string // metadata label when typ is partitionMetadata.
+	v4    int    // ECS prefix lengths when typ is partitionECS.
+	v6    int
+}
+
+// partitionKey returns the data to add to the cache key for this request. It returns the empty
+// string when no partitions are configured.
+func (c *Cache) partitionKey(ctx context.Context, state request.Request) string {
+	if len(c.partitions) == 0 {
+		return ""
+	}
+
+	sb := strings.Builder{}
+	for _, p := range c.partitions {
+		switch p.typ {
+		case partitionView:
+			sb.WriteString(metrics.WithView(ctx))
+		case partitionMetadata:
+			if f := metadata.ValueFunc(ctx, p.label); f != nil {
+				sb.WriteString(f())
+			}
+		case partitionECS:
+			sb.WriteString(p.ecs(state))
+		}
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// ecs returns the ECS source address of the request truncated to the configured prefix length, or
+// the empty string when the request doesn't have an ECS option.
+func (p partition) ecs(state request.Request) string {
+	o := state.Req.IsEdns0()
+	if o == nil {
+		return ""
+	}
+	for _, s := range o.Option {
+		e, ok := s.(*dns.EDNS0_SUBNET)
+		if !ok {
+			continue
+		}
+		bits, size := p.v4, net.IPv4len*8
+		if e.Family == 2 {
+			bits, size = p.v6, net.IPv6len*8
+		}
+		// Never use more bits than the client sent us.
+		if int(e.SourceNetmask) < bits {
+			bits = int(e.SourceNetmask)
+		}
+		ip := e.Address.Mask(net.CIDRMask(bits, size))
+		if ip == nil {
+			return ""
+		}
+		return ip.String() + "/" + strconv.Itoa(bits)
+	}
+	return ""
+}
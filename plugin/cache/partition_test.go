@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func ecsMsg(ip string, bits uint8) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if ip == "" {
+		return m
+	}
+	e := &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: bits, Address: net.ParseIP(ip)}
+	if e.Address.To4() == nil {
+		e.Family = 2
+	}
+	m.SetEdns0(4096, false)
+	o := m.IsEdns0()
+	o.Option = append(o.Option, e)
+	return m
+}
+
+func TestPartitionECS(t *testing.T) {
+	p := partition{typ: partitionECS, v4: 24, v6: 56}
+	tests := []struct {
+		ip       string
+		bits     uint8
+		expected string
+	}{
+		{"", 0, ""},
+		{"10.1.2.3", 32, "10.1.2.0/24"},
+		{"10.1.2.3", 16, "10.1.0.0/16"},
+		{"2001:db8:1:2ff::1", 128, "2001:db8:1:200::/56"},
+		{"2001:db8:1:2:3::1", 48, "2001:db8:1::/48"},
+	}
+	for i, tc := range tests {
+		state := request.Request{W: &test.ResponseWriter{}, Req: ecsMsg(tc.ip, tc.bits)}
+		if got := p.ecs(state); got != tc.expected {
+			t.Errorf("Test %d: expected %q, got %q", i, tc.expected, got)
+		}
+	}
+}
+
+// partitionBackend answers with the client's ECS address, so we can tell which answer was cached.
+func partitionBackend() plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		state := request.Request{W: w, Req: r}
+		ip := "127.0.0.1"
+		if o := r.IsEdns0(); o != nil {
+			for _, s := range o.Option {
+				if e, ok := s.(*dns.EDNS0_SUBNET); ok {
+					ip = e.Address.String()
+				}
+			}
+		}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A(fmt.Sprintf("%s 60 IN A %s", state.QName(), ip))}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func TestPartitionCache(t *testing.T) {
+	c := New()
+	c.partitions = []partition{{typ: partitionECS, v4: 24, v6: 56}}
+	c.Next = partitionBackend()
+
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"10.1.2.3", "10.1.2.3"},
+		{"10.1.2.4", "10.1.2.3"}, // same /24, from the cache
+		{"10.1.3.4", "10.1.3.4"},
+		{"", "127.0.0.1"},
+	}
+	for i, tc := range tests {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		c.ServeDNS(context.TODO(), rec, ecsMsg(tc.ip, 32))
+		if got := rec.Msg.Answer[0].(*dns.A).A.String(); got != tc.expected {
+			t.Errorf("Test %d: expected answer %s, got %s", i, tc.expected, got)
+		}
+	}
+	if c.pcache.Len() != 3 {
+		t.Errorf("Expected 3 cached entries, got %d", c.pcache.Len())
+	}
+}
+
+func TestPartitionKey(t *testing.T) {
+	c := New()
+	c.partitions = []partition{{typ: partitionView}, {typ: partitionMetadata, label: "test/label"}}
+	state := request.Request{W: &test.ResponseWriter{}, Req: ecsMsg("", 0)}
+
+	ctx := metadata.ContextWithMetadata(context.Background())
+	if got := c.partitionKey(ctx, state); got != "\x00\x00" {
+		t.Errorf("Expected empty partition key, got %q", got)
+	}
+
+	ctx = context.WithValue(ctx, dnsserver.ViewKey{}, "internal")
+	metadata.SetValueFunc(ctx, "test/label", func() string { return "value" })
+	if got := c.partitionKey(ctx, state); got != "internal\x00value\x00" {
+		t.Errorf("Expected partition key with view and label, got %q", got)
+	}
+}
@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+
+	"github.com/miekg/dns"
+)
+
+// persistVersion is written at the start of a snapshot. Bump it when the on-disk format or the
+// computation of the cache key changes, snapshots with another version are ignored.
+const persistVersion = 1
+
+// persistHeader is the first value in a snapshot.
+type persistHeader struct {
+	Version int
+	Written time.Time
+}
+
+// persistedItem is an item as it is written to disk. The message is stored in wire format,
+// Stored and OrigTTL are kept as is, so the remaining TTL keeps counting down while we're not running.
+type persistedItem struct {
+	Key      uint64
+	Denial   bool
+	Msg      []byte
+	Wildcard string
+	OrigTTL  uint32
+	Stored   time.Time
+}
+
+// persist holds the settings for saving the cache to disk.
+type persist struct {
+	path     string
+	interval time.Duration // if > 0, also save every interval.
+
+	stop chan struct{}
+}
+
+// Save writes the contents of the success and denial cache to the persist file. The file is written
+// to a temporary file first which is then renamed, so a crash while saving can't corrupt an earlier snapshot.
+func (c *Cache) Save() error {
+	if c.persist == nil {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.persist.path), filepath.Base(c.persist.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	n, err := c.save(tmp)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.persist.path); err != nil {
+		return err
+	}
+	log.Debugf("Saved %d cache entries to %s", n, c.persist.path)
+	return nil
+}
+
+func (c *Cache) save(w io.Writer) (int, error) {
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(persistHeader{Version: persistVersion, Written: c.now().UTC()}); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	var err error
+	walk := func(ca *cache.Cache, denial bool) {
+		ca.Walk(func(items map[uint64]interface{}, key uint64) bool {
+			// Walk only stops the current shard, don't encode anything after an error.
+			if err != nil {
+				return false
+			}
+			i, ok := items[key].(*item)
+			if !ok {
+				return true
+			}
+			buf, e := i.pack()
+			if e != nil {
+				// Shouldn't happen as we've packed this message before, skip it.
+				return true
+			}
+			p := persistedItem{Key: key, Denial: denial, Msg: buf, Wildcard: i.wildcard, OrigTTL: i.origTTL, Stored: i.stored}
+			if e := enc.Encode(p); e != nil {
+				err = e
+				return false
+			}
+			n++
+			return true
+		})
+	}
+	walk(c.pcache, false)
+	if err != nil {
+		return n, err
+	}
+	walk(c.ncache, true)
+	return n, err
+}
+
+// Load reads the persist file and adds its entries to the caches. Entries that have expired, and
+// aren't within the serve_stale window, are dropped. A missing file is not an error.
+func (c *Cache) Load() error {
+	if c.persist == nil {
+		return nil
+	}
+
+	f, err := os.Open(c.persist.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	loaded, dropped, err := c.load(f)
+	if err != nil {
+		return fmt.Errorf("failed to load cache from %s: %s", c.persist.path, err)
+	}
+	log.Infof("Loaded %d cache entries from %s, dropped %d expired entries", loaded, c.persist.path, dropped)
+	return nil
+}
+
+func (c *Cache) load(r io.Reader) (loaded, dropped int, err error) {
+	dec := gob.NewDecoder(r)
+	h := persistHeader{}
+	if err := dec.Decode(&h); err != nil {
+		return 0, 0, err
+	}
+	if h.Version != persistVersion {
+		return 0, 0, fmt.Errorf("unsupported version: %d", h.Version)
+	}
+
+	now := c.now()
+	for {
+		p := persistedItem{}
+		if err := dec.Decode(&p); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return loaded, dropped, err
+		}
+
+		m := new(dns.Msg)
+		if err := m.Unpack(p.Msg); err != nil {
+			dropped++
+			continue
+		}
+		i := newItem(m, now, 0)
+		i.wildcard = p.Wildcard
+		i.origTTL = p.OrigTTL
+		i.stored = p.Stored
+
+		if ttl := i.ttl(now); ttl <= 0 && (c.staleUpTo <= 0 || -ttl > int(c.staleUpTo.Seconds())) {
+			dropped++
+			continue
+		}
+
+		if p.Denial {
+			c.ncache.Add(p.Key, i)
+		} else {
+			c.pcache.Add(p.Key, i)
+		}
+		loaded++
+	}
+	return loaded, dropped, nil
+}
+
+// pack returns i as a message in wire format.
+func (i *item) pack() ([]byte, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(i.Name, i.QType)
+	m.Response = true
+	m.Rcode = i.Rcode
+	m.AuthenticatedData = i.AuthenticatedData
+	m.RecursionAvailable = i.RecursionAvailable
+	m.Answer = i.Answer
+	m.Ns = i.Ns
+	m.Extra = i.Extra
+	return m.Pack()
+}
+
+// startPersist saves the cache every interval until stopPersist is called.
+func (c *Cache) startPersist() {
+	if c.persist == nil || c.persist.interval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	c.persist.stop = stop
+	go func() {
+		tick := time.NewTicker(c.persist.interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				if err := c.Save(); err != nil {
+					log.Errorf("Failed to save cache to %s: %s", c.persist.path, err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopAndSave stops saving the cache every interval and saves it one last time.
+func (c *Cache) stopAndSave() error {
+	c.stopPersist()
+	if err := c.Save(); err != nil {
+		log.Errorf("Failed to save cache to %s: %s", c.persist.path, err)
+	}
+	return nil
+}
+
+func (c *Cache) stopPersist() {
+	if c.persist == nil || c.persist.stop == nil {
+		return
+	}
+	close(c.persist.stop)
+	c.persist.stop = nil
+}
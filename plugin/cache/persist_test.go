@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestPersistSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache")
+	now := time.Now()
+
+	c := New()
+	c.persist = &persist{path: path}
+	c.now = func() time.Time { return now }
+	c.Next = ttlBackend(60)
+
+	ctx := context.TODO()
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	c.ServeDNS(ctx, dnstest.NewRecorder(&test.ResponseWriter{}), req)
+
+	c.Next = servFailBackend(30)
+	req.SetQuestion("fail.example.org.", dns.TypeA)
+	c.ServeDNS(ctx, dnstest.NewRecorder(&test.ResponseWriter{}), req)
+
+	if c.pcache.Len() != 1 || c.ncache.Len() != 1 {
+		t.Fatalf("Expected 1 positive and 1 negative entry, got %d and %d", c.pcache.Len(), c.ncache.Len())
+	}
+	if err := c.Save(); err != nil {
+		t.Fatalf("Failed to save cache: %s", err)
+	}
+
+	tests := []struct {
+		after       time.Duration
+		staleUpTo   time.Duration
+		expectedP   int
+		expectedN   int
+		expectedTTL uint32
+	}{
+		{3 * time.Second, 0, 1, 1, 57},
+		{10 * time.Second, 0, 1, 0, 50},                // servfail is cached for 5s only
+		{2 * time.Minute, 0, 0, 0, 0},                  // everything expired
+		{2 * time.Minute, time.Hour, 1, 1, 0},          // but still within the serve_stale window
+		{2 * time.Hour, time.Hour, 0, 0, 0},            // and expired beyond it
+		{50 * time.Second, 30 * time.Second, 1, 0, 10}, // servfail expired 45s ago, beyond the window
+	}
+
+	for i, tc := range tests {
+		c2 := New()
+		c2.persist = &persist{path: path}
+		c2.staleUpTo = tc.staleUpTo
+		c2.now = func() time.Time { return now.Add(tc.after) }
+		c2.Next = plugin.HandlerFunc(func(context.Context, dns.ResponseWriter, *dns.Msg) (int, error) {
+			return 255, nil // Below, a 255 means we tried querying upstream.
+		})
+
+		if err := c2.Load(); err != nil {
+			t.Fatalf("Test %d: failed to load cache: %s", i, err)
+		}
+		if c2.pcache.Len() != tc.expectedP || c2.ncache.Len() != tc.expectedN {
+			t.Errorf("Test %d: expected %d positive and %d negative entries, got %d and %d", i, tc.expectedP, tc.expectedN, c2.pcache.Len(), c2.ncache.Len())
+			continue
+		}
+		if tc.expectedP == 0 {
+			continue
+		}
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		req.SetQuestion("example.org.", dns.TypeA)
+		if ret, _ := c2.ServeDNS(ctx, rec, req); ret != dns.RcodeSuccess {
+			t.Errorf("Test %d: expected answer from the loaded cache, got %d", i, ret)
+			continue
+		}
+		if ttl := rec.Msg.Answer[0].Header().Ttl; ttl != tc.expectedTTL {
+			t.Errorf("Test %d: expected TTL %d, got %d", i, tc.expectedTTL, ttl)
+		}
+	}
+}
+
+func TestPersistLoadMissing(t *testing.T) {
+	c := New()
+	c.persist = &persist{path: filepath.Join(t.TempDir(), "does-not-exist")}
+	if err := c.Load(); err != nil {
+		t.Errorf("Expected no error for a missing file, got: %s", err)
+	}
+}
+
+// failWriter fails all writes after the first ok writes and counts the failed writes.
+type failWriter struct {
+	ok     int
+	failed int
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.ok > 0 {
+		w.ok--
+		return len(p), nil
+	}
+	w.failed++
+	return 0, errors.New("write failed")
+}
+
+func TestPersistSaveError(t *testing.T) {
+	c := New()
+	c.Next = ttlBackend(60)
+	ctx := context.TODO()
+	for i := range 100 {
+		req := new(dns.Msg)
+		req.SetQuestion(fmt.Sprintf("%d.example.org.", i), dns.TypeA)
+		c.ServeDNS(ctx, dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	}
+
+	w := &failWriter{ok: 5}
+	if _, err := c.save(w); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if w.failed != 1 {
+		t.Errorf("Expected encoding to stop after the first failed write, got %d failed writes", w.failed)
+	}
+}
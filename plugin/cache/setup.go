@@ -3,6 +3,8 @@ package cache
 import (
 	"errors"
 	"fmt"
+	"net"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +31,32 @@ func setup(c *caddy.Controller) error {
 		return nil
 	})
 
+	if ca.persist != nil {
+		c.OnStartup(func() error {
+			if err := ca.Load(); err != nil {
+				// A broken snapshot shouldn't prevent us from starting, we just start with an empty cache.
+				log.Warning(err)
+			}
+			ca.startPersist()
+			return nil
+		})
+		// On a restart the new instance starts before the old one is shut down, so the old instance
+		// saves on restart, for the new one to load.
+		c.OnRestart(ca.stopAndSave)
+		c.OnFinalShutdown(ca.stopAndSave)
+		c.OnRestartFailed(func() error {
+			ca.startPersist()
+			return nil
+		})
+	}
+
+	if ca.adminAddr != "" {
+		c.OnStartup(ca.registerAdmin)
+		c.OnRestart(ca.unregisterAdmin)
+		c.OnFinalShutdown(ca.unregisterAdmin)
+		c.OnRestartFailed(ca.registerAdmin)
+	}
+
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		ca.Next = next
 		return ca
@@ -172,7 +200,7 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 
 			case "serve_stale":
 				args := c.RemainingArgs()
-				if len(args) > 2 {
+				if len(args) > 3 {
 					return nil, c.ArgErr()
 				}
 				ca.staleUpTo = 1 * time.Hour
@@ -187,12 +215,32 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 					ca.staleUpTo = d
 				}
 				ca.verifyStale = false
+				ca.staleTimeout = 0
+				mode := "immediate"
 				if len(args) > 1 {
-					mode := strings.ToLower(args[1])
-					if mode != "immediate" && mode != "verify" {
+					mode = strings.ToLower(args[1])
+					switch mode {
+					case "immediate":
+					case "verify":
+						ca.verifyStale = true
+					case "timeout":
+						ca.staleTimeout = defaultStaleTimeout
+					default:
 						return nil, fmt.Errorf("invalid value for serve_stale refresh mode: %s", mode)
 					}
-					ca.verifyStale = mode == "verify"
+				}
+				if len(args) > 2 {
+					if mode != "timeout" {
+						return nil, c.ArgErr()
+					}
+					d, err := time.ParseDuration(args[2])
+					if err != nil {
+						return nil, err
+					}
+					if d <= 0 {
+						return nil, errors.New("invalid non-positive client timeout for serve_stale")
+					}
+					ca.staleTimeout = d
 				}
 			case "servfail":
 				args := c.RemainingArgs()
@@ -246,6 +294,98 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 					return nil, c.ArgErr()
 				}
 				ca.keepttl = true
+			case "persist":
+				// persist FILE [INTERVAL]
+				args := c.RemainingArgs()
+				if len(args) < 1 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				ca.persist = &persist{path: args[0]}
+				if !filepath.IsAbs(ca.persist.path) && dnsserver.GetConfig(c).Root != "" {
+					ca.persist.path = filepath.Join(dnsserver.GetConfig(c).Root, ca.persist.path)
+				}
+				if len(args) > 1 {
+					d, err := time.ParseDuration(args[1])
+					if err != nil {
+						return nil, err
+					}
+					if d <= 0 {
+						return nil, errors.New("persist interval must be positive")
+					}
+					ca.persist.interval = d
+				}
+			case "partition":
+				// partition view|metadata LABEL|ecs [V4LEN [V6LEN]]
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				p := partition{typ: args[0]}
+				switch p.typ {
+				case partitionView:
+					if len(args) != 1 {
+						return nil, c.ArgErr()
+					}
+				case partitionMetadata:
+					if len(args) != 2 {
+						return nil, c.ArgErr()
+					}
+					p.label = args[1]
+				case partitionECS:
+					if len(args) > 3 {
+						return nil, c.ArgErr()
+					}
+					p.v4, p.v6 = defaultECSv4, defaultECSv6
+					if len(args) > 1 {
+						v4, err := strconv.Atoi(args[1])
+						if err != nil {
+							return nil, err
+						}
+						if v4 < 0 || v4 > 32 {
+							return nil, fmt.Errorf("ECS IPv4 prefix length should fall in range [0, 32]: %d", v4)
+						}
+						p.v4 = v4
+					}
+					if len(args) > 2 {
+						v6, err := strconv.Atoi(args[2])
+						if err != nil {
+							return nil, err
+						}
+						if v6 < 0 || v6 > 128 {
+							return nil, fmt.Errorf("ECS IPv6 prefix length should fall in range [0, 128]: %d", v6)
+						}
+						p.v6 = v6
+					}
+				default:
+					return nil, fmt.Errorf("partition type must be %q, %q or %q", partitionView, partitionMetadata, partitionECS)
+				}
+				ca.partitions = append(ca.partitions, p)
+			case "aggressive_nsec":
+				// aggressive_nsec [CAPACITY]
+				args := c.RemainingArgs()
+				if len(args) > 1 {
+					return nil, c.ArgErr()
+				}
+				capacity := defaultCap
+				if len(args) == 1 {
+					var err error
+					if capacity, err = strconv.Atoi(args[0]); err != nil {
+						return nil, err
+					}
+					if capacity <= 0 {
+						return nil, fmt.Errorf("aggressive_nsec capacity should be positive: %d", capacity)
+					}
+				}
+				ca.nsec = newNSECCache(capacity)
+			case "admin":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				if _, _, err := net.SplitHostPort(args[0]); err != nil {
+					return nil, err
+				}
+				ca.adminAddr = args[0]
 			default:
 				return nil, c.ArgErr()
 			}
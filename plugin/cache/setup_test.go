@@ -2,6 +2,7 @@ package cache
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -156,6 +157,41 @@ func TestServeStale(t *testing.T) {
 	}
 }
 
+func TestServeStaleTimeout(t *testing.T) {
+	tests := []struct {
+		input        string
+		shouldErr    bool
+		staleTimeout time.Duration
+	}{
+		{"serve_stale", false, 0},
+		{"serve_stale 1h verify", false, 0},
+		{"serve_stale 1h timeout", false, defaultStaleTimeout},
+		{"serve_stale 1h TIMEOUT 500ms", false, 500 * time.Millisecond},
+		// fails
+		{"serve_stale 1h verify 500ms", true, 0},
+		{"serve_stale 1h timeout 0s", true, 0},
+		{"serve_stale 1h timeout aa", true, 0},
+		{"serve_stale 1h timeout 1s 1s", true, 0},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", fmt.Sprintf("cache {\n%s\n}", test.input))
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if ca.staleTimeout != test.staleTimeout {
+			t.Errorf("Test %v: Expected stale timeout %v but found: %v", i, test.staleTimeout, ca.staleTimeout)
+		}
+	}
+}
+
 func TestServfail(t *testing.T) {
 	tests := []struct {
 		input     string
@@ -260,3 +296,114 @@ func TestKeepttl(t *testing.T) {
 		}
 	}
 }
+
+func TestPersist(t *testing.T) {
+	tests := []struct {
+		input            string
+		shouldErr        bool
+		expectedPath     string
+		expectedInterval time.Duration
+	}{
+		// positive
+		{"persist /var/lib/coredns/cache", false, "/var/lib/coredns/cache", 0},
+		{"persist /var/lib/coredns/cache 5m", false, "/var/lib/coredns/cache", 5 * time.Minute},
+		// negative
+		{"persist", true, "", 0},
+		{"persist /var/lib/coredns/cache 0s", true, "", 0},
+		{"persist /var/lib/coredns/cache aa", true, "", 0},
+		{"persist /var/lib/coredns/cache 5m arg3", true, "", 0},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", fmt.Sprintf("cache {\n%s\n}", test.input))
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if ca.persist.path != test.expectedPath {
+			t.Errorf("Test %v: Expected path %s but found: %s", i, test.expectedPath, ca.persist.path)
+		}
+		if ca.persist.interval != test.expectedInterval {
+			t.Errorf("Test %v: Expected interval %v but found: %v", i, test.expectedInterval, ca.persist.interval)
+		}
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	tests := []struct {
+		input        string
+		shouldErr    bool
+		expectedAddr string
+	}{
+		// positive
+		{"admin :8081", false, ":8081"},
+		{"admin 127.0.0.1:8081", false, "127.0.0.1:8081"},
+		// negative
+		{"admin", true, ""},
+		{"admin 127.0.0.1", true, ""},
+		{"admin :8081 :8082", true, ""},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", fmt.Sprintf("cache {\n%s\n}", test.input))
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if ca.adminAddr != test.expectedAddr {
+			t.Errorf("Test %v: Expected address %s but found: %s", i, test.expectedAddr, ca.adminAddr)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	tests := []struct {
+		input              string
+		shouldErr          bool
+		expectedPartitions []partition
+	}{
+		// positive
+		{"partition view", false, []partition{{typ: partitionView}}},
+		{"partition metadata geoip/country/code", false, []partition{{typ: partitionMetadata, label: "geoip/country/code"}}},
+		{"partition ecs", false, []partition{{typ: partitionECS, v4: 24, v6: 56}}},
+		{"partition ecs 16 48", false, []partition{{typ: partitionECS, v4: 16, v6: 48}}},
+		{"partition view\npartition ecs 20", false, []partition{{typ: partitionView}, {typ: partitionECS, v4: 20, v6: 56}}},
+		// negative
+		{"partition", true, nil},
+		{"partition bogus", true, nil},
+		{"partition view arg2", true, nil},
+		{"partition metadata", true, nil},
+		{"partition ecs 33", true, nil},
+		{"partition ecs 24 129", true, nil},
+		{"partition ecs aa", true, nil},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", fmt.Sprintf("cache {\n%s\n}", test.input))
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if !reflect.DeepEqual(ca.partitions, test.expectedPartitions) {
+			t.Errorf("Test %v: Expected partitions %v but found: %v", i, test.expectedPartitions, ca.partitions)
+		}
+	}
+}
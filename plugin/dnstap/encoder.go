@@ -26,6 +26,17 @@ func newEncoder(w io.Writer, timeout time.Duration) (*encoder, error) {
 	return &encoder{fs}, nil
 }
 
+// newFileEncoder returns an encoder for a file, these are written unidirectionally.
+func newFileEncoder(w io.Writer) (*encoder, error) {
+	fs, err := fs.NewEncoder(w, &fs.EncoderOptions{
+		ContentType: []byte("protobuf:dnstap.Dnstap"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &encoder{fs}, nil
+}
+
 func (e *encoder) writeMsg(msg *tap.Dnstap) error {
 	buf, err := proto.Marshal(msg)
 	if err != nil {
@@ -0,0 +1,214 @@
+package dnstap
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRotateSize = 100 * 1024 * 1024
+	defaultMaxFiles   = 10
+)
+
+// rotator writes to a local file and moves it aside when it grows too large or too old. The current
+// file is always written to path, the rotated files are named after path with the time of rotation
+// added, i.e. dnstap.dnstap becomes dnstap-20060102T150405.dnstap, with a sequence number when it is
+// rotated more than once in a second: dnstap-20060102T150405.1.dnstap.
+type rotator struct {
+	path     string
+	size     int64         // rotate when the file is larger than this, 0 disables.
+	interval time.Duration // rotate when the file is older than this, 0 disables.
+	keep     int           // number of rotated files to keep, 0 keeps all.
+
+	f       *os.File
+	written int64
+	opened  time.Time
+	now     func() time.Time
+}
+
+func newRotator(path string) *rotator {
+	return &rotator{path: path, size: defaultRotateSize, keep: defaultMaxFiles, now: time.Now}
+}
+
+// Write implements io.Writer.
+func (r *rotator) Write(p []byte) (int, error) {
+	n, err := r.f.Write(p)
+	r.written += int64(n)
+	return n, err
+}
+
+// open opens a new file at path. An existing file, left behind by an earlier run, is rotated first
+// because a Frame Streams file can't be appended to.
+func (r *rotator) open() error {
+	r.close()
+	if fi, err := os.Stat(r.path); err == nil && fi.Size() > 0 {
+		if err := r.archive(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	r.f = f
+	r.written = 0
+	r.opened = r.now()
+	return nil
+}
+
+// due returns true if the current file should be rotated.
+func (r *rotator) due() bool {
+	if r.f == nil {
+		return false
+	}
+	if r.size > 0 && r.written >= r.size {
+		return true
+	}
+	return r.interval > 0 && r.now().Sub(r.opened) >= r.interval
+}
+
+// rotate closes the current file, moves it aside and opens a new one.
+func (r *rotator) rotate() error {
+	r.close()
+	return r.open()
+}
+
+func (r *rotator) close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// rotatedLayout is the layout of the time of rotation in the name of a rotated file.
+const rotatedLayout = "20060102T150405"
+
+// archive moves the file at path aside and removes the oldest rotated files when there are more
+// than r.keep.
+func (r *rotator) archive() error {
+	rotated, err := r.rotated()
+	if err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(r.path)
+	now := r.now().UTC().Truncate(time.Second)
+	name := strings.TrimSuffix(r.path, ext) + "-" + now.Format(rotatedLayout)
+	// More than one rotation per second: add a sequence number higher than the ones already used.
+	seq := -1
+	for _, f := range rotated {
+		if f.time.Equal(now) && f.seq > seq {
+			seq = f.seq
+		}
+	}
+	if seq >= 0 {
+		name += "." + strconv.Itoa(seq+1)
+	}
+	name += ext
+	if err := os.Rename(r.path, name); err != nil {
+		return err
+	}
+
+	if r.keep == 0 {
+		return nil
+	}
+	if rotated, err = r.rotated(); err != nil {
+		return err
+	}
+	for len(rotated) > r.keep {
+		if err := os.Remove(rotated[0].name); err != nil {
+			log.Warningf("Failed to remove rotated dnstap file %q: %s", rotated[0].name, err)
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// rotatedFile is a file moved aside by archive.
+type rotatedFile struct {
+	name string
+	time time.Time
+	seq  int
+}
+
+// rotated returns the rotated files of r, oldest first. Other files in the directory, even when their
+// names start with the same base name, are left out.
+func (r *rotator) rotated() ([]rotatedFile, error) {
+	dir, file := filepath.Split(r.path)
+	ext := filepath.Ext(file)
+	prefix := strings.TrimSuffix(file, ext) + "-"
+
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+	var rotated []rotatedFile
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() || !strings.HasPrefix(n, prefix) || !strings.HasSuffix(n, ext) || len(n) < len(prefix)+len(ext) {
+			continue
+		}
+		stamp, seq := n[len(prefix):len(n)-len(ext)], "0"
+		if i := strings.IndexByte(stamp, '.'); i >= 0 {
+			stamp, seq = stamp[:i], stamp[i+1:]
+		}
+		t, err := time.Parse(rotatedLayout, stamp)
+		if err != nil {
+			continue
+		}
+		i, err := strconv.Atoi(seq)
+		if err != nil || i < 0 || strconv.Itoa(i) != seq {
+			continue
+		}
+		rotated = append(rotated, rotatedFile{name: filepath.Join(dir, n), time: t, seq: i})
+	}
+	sort.Slice(rotated, func(i, j int) bool {
+		if !rotated[i].time.Equal(rotated[j].time) {
+			return rotated[i].time.Before(rotated[j].time)
+		}
+		return rotated[i].seq < rotated[j].seq
+	})
+	return rotated, nil
+}
+
+// openFile opens the dnstap file and starts a new frame stream in it.
+func (d *dio) openFile() error {
+	d.enc = nil
+	if err := d.file.open(); err != nil {
+		return err
+	}
+	enc, err := newFileEncoder(d.file)
+	if err != nil {
+		d.file.close()
+		return err
+	}
+	d.enc = enc
+	return nil
+}
+
+// rotate ends the frame stream in the current file and continues in a new file, when rotation is due.
+func (d *dio) rotate() {
+	if d.file == nil || d.enc == nil || !d.file.due() {
+		return
+	}
+	d.enc.close() // flushes and writes the stop frame.
+	d.enc = nil
+	if err := d.file.rotate(); err != nil {
+		log.Errorf("Failed to rotate dnstap file: %s", err)
+		return
+	}
+	enc, err := newFileEncoder(d.file)
+	if err != nil {
+		log.Errorf("Failed to rotate dnstap file: %s", err)
+		d.file.close()
+		return
+	}
+	d.enc = enc
+}
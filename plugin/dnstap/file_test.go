@@ -0,0 +1,202 @@
+package dnstap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fs "github.com/farsightsec/golang-framestream"
+)
+
+// decodeFile returns the number of frames in the dnstap file at path.
+func decodeFile(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec, err := fs.NewDecoder(f, &fs.DecoderOptions{ContentType: []byte("protobuf:dnstap.Dnstap")})
+	if err != nil {
+		t.Fatalf("Decoder for %s: %s", path, err)
+	}
+	n := 0
+	for {
+		if _, err := dec.Decode(); err != nil {
+			return n
+		}
+		n++
+	}
+}
+
+func TestFileRotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dnstap.dnstap")
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := newIO("file", path, 1, 1)
+	d.file.now = func() time.Time { return now }
+	d.file.size = 1 // rotate after every flushed message.
+	d.file.keep = 2
+
+	if err := d.dial(); err != nil {
+		t.Fatal(err)
+	}
+	for range 4 {
+		if err := d.write(&tmsg); err != nil {
+			t.Fatal(err)
+		}
+		d.enc.flush()
+		d.rotate()
+		now = now.Add(time.Second)
+	}
+	d.enc.close()
+	d.file.close()
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "dnstap-*.dnstap"))
+	if len(rotated) != 2 {
+		t.Fatalf("Expected 2 rotated files, got %v", rotated)
+	}
+	// The oldest two have been removed.
+	for i, name := range []string{"dnstap-20260101T000002.dnstap", "dnstap-20260101T000003.dnstap"} {
+		if filepath.Base(rotated[i]) != name {
+			t.Errorf("Expected rotated file %s, got %s", name, rotated[i])
+		}
+		if n := decodeFile(t, rotated[i]); n != 1 {
+			t.Errorf("Expected 1 message in %s, got %d", rotated[i], n)
+		}
+	}
+	if n := decodeFile(t, path); n != 0 {
+		t.Errorf("Expected 0 messages in %s, got %d", path, n)
+	}
+}
+
+func TestFileRotateSameSecond(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dnstap.dnstap")
+	// Not rotated files, these must be left alone.
+	other := []string{"dnstap-old.dnstap", "dnstap-20250101T000000.x.dnstap", "dnstap-20250101T000000.dnstap.gz"}
+	for _, name := range other {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := newRotator(path)
+	r.now = func() time.Time { return now }
+	r.keep = 3
+	for range 12 {
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.archive(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rotated, err := r.rotated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Numerically sorted on the sequence number, .10 and .11 are the newest.
+	expected := []string{"dnstap-20260101T000000.9.dnstap", "dnstap-20260101T000000.10.dnstap", "dnstap-20260101T000000.11.dnstap"}
+	if len(rotated) != len(expected) {
+		t.Fatalf("Expected %d rotated files, got %v", len(expected), rotated)
+	}
+	for i, name := range expected {
+		if filepath.Base(rotated[i].name) != name {
+			t.Errorf("Expected rotated file %s, got %s", name, rotated[i].name)
+		}
+	}
+	for _, name := range other {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be left alone: %s", name, err)
+		}
+	}
+}
+
+func TestFileRotateInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dnstap.dnstap")
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := newIO("file", path, 1, 1)
+	d.file.now = func() time.Time { return now }
+	d.file.interval = time.Hour
+
+	if err := d.dial(); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		d.write(&tmsg)
+	}
+	d.rotate()
+	if rotated, _ := filepath.Glob(filepath.Join(dir, "dnstap-*.dnstap")); len(rotated) != 0 {
+		t.Fatalf("Expected no rotated files, got %v", rotated)
+	}
+
+	now = now.Add(time.Hour)
+	d.rotate()
+	d.write(&tmsg)
+	d.enc.close()
+	d.file.close()
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "dnstap-*.dnstap"))
+	if len(rotated) != 1 {
+		t.Fatalf("Expected 1 rotated file, got %v", rotated)
+	}
+	if n := decodeFile(t, rotated[0]); n != 3 {
+		t.Errorf("Expected 3 messages in %s, got %d", rotated[0], n)
+	}
+	if n := decodeFile(t, path); n != 1 {
+		t.Errorf("Expected 1 message in %s, got %d", path, n)
+	}
+}
+
+func TestFileExisting(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dnstap.dnstap")
+	if err := os.WriteFile(path, []byte("earlier run"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := newIO("file", path, 1, 1)
+	if err := d.dial(); err != nil {
+		t.Fatal(err)
+	}
+	d.write(&tmsg)
+	d.enc.close()
+	d.file.close()
+
+	if rotated, _ := filepath.Glob(filepath.Join(dir, "dnstap-*.dnstap")); len(rotated) != 1 {
+		t.Fatalf("Expected the existing file to be rotated, got %v", rotated)
+	}
+	if n := decodeFile(t, path); n != 1 {
+		t.Errorf("Expected 1 message in %s, got %d", path, n)
+	}
+}
+
+func TestFileServe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnstap.dnstap")
+
+	d := newIO("file", path, 1, 1)
+	d.flushTimeout = 10 * time.Millisecond
+	if err := d.connect(); err != nil {
+		t.Fatal(err)
+	}
+	for range 5 {
+		d.Dnstap(&tmsg)
+	}
+
+	for range 100 {
+		if decodeFile(t, path) == 5 {
+			d.close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	d.close()
+	t.Errorf("Expected 5 messages in %s", path)
+}
@@ -0,0 +1,79 @@
+package dnstap
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"net"
+	"strings"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+
+	tap "github.com/dnstap/golang-dnstap"
+	"github.com/infobloxopen/go-trees/iptree"
+	"github.com/miekg/dns"
+)
+
+// filter selects the messages that are sent to the dnstap endpoint. The zero value selects all messages.
+type filter struct {
+	types   map[tap.Message_Type]struct{} // message types, empty selects all types.
+	names   []string                      // query name suffixes, empty selects all names.
+	rcodes  map[int]struct{}              // response codes, queries are tapped before the response is known and are not filtered.
+	clients *iptree.Tree                  // client networks, nil selects all clients.
+	sample  uint32                        // select 1 in sample queries, 0 and 1 select all queries.
+}
+
+// messageTypes holds the message types usable in a types property.
+var messageTypes = map[string]tap.Message_Type{
+	"CLIENT_QUERY":       tap.Message_CLIENT_QUERY,
+	"CLIENT_RESPONSE":    tap.Message_CLIENT_RESPONSE,
+	"FORWARDER_QUERY":    tap.Message_FORWARDER_QUERY,
+	"FORWARDER_RESPONSE": tap.Message_FORWARDER_RESPONSE,
+}
+
+// match returns true if the message of type t for the query in state, and the response resp, if
+// not nil, is selected by f.
+func (f filter) match(t tap.Message_Type, state request.Request, resp *dns.Msg) bool {
+	if len(f.types) > 0 {
+		if _, ok := f.types[t]; !ok {
+			return false
+		}
+	}
+	if len(f.names) > 0 && plugin.Zones(f.names).Matches(state.Name()) == "" {
+		return false
+	}
+	if len(f.rcodes) > 0 && resp != nil {
+		if _, ok := f.rcodes[resp.Rcode]; !ok {
+			return false
+		}
+	}
+	if f.clients != nil {
+		addr := state.IP()
+		if i := strings.IndexByte(addr, '%'); i >= 0 {
+			addr = addr[:i]
+		}
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return false
+		}
+		if _, ok := f.clients.GetByIP(ip); !ok {
+			return false
+		}
+	}
+	if f.sample > 1 {
+		return sampleHash(state)%f.sample == 0
+	}
+	return true
+}
+
+// sampleHash hashes the query ID, name and client address. All messages for a query hash to the same
+// value, so these are either all sampled or not at all.
+func sampleHash(state request.Request) uint32 {
+	h := fnv.New32a()
+	var id [2]byte
+	binary.BigEndian.PutUint16(id[:], state.Req.Id)
+	h.Write(id[:])
+	h.Write([]byte(state.Name()))
+	h.Write([]byte(state.IP()))
+	return h.Sum32()
+}
@@ -0,0 +1,131 @@
+package dnstap
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	tap "github.com/dnstap/golang-dnstap"
+	"github.com/miekg/dns"
+)
+
+// counter counts the tapped messages by type.
+type counter map[tap.Message_Type]int
+
+func (c counter) Dnstap(d *tap.Dnstap) { c[d.GetMessage().GetType()]++ }
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		config   string
+		qname    string
+		client   string
+		rcode    int
+		query    bool // expect CLIENT_QUERY to be tapped
+		response bool // expect CLIENT_RESPONSE to be tapped
+	}{
+		{"", "example.org.", "10.0.0.1", dns.RcodeSuccess, true, true},
+		{"types CLIENT_RESPONSE", "example.org.", "10.0.0.1", dns.RcodeSuccess, false, true},
+		{"types client_query forwarder_query", "example.org.", "10.0.0.1", dns.RcodeSuccess, true, false},
+		{"names example.org", "www.example.org.", "10.0.0.1", dns.RcodeSuccess, true, true},
+		{"names example.org", "www.example.net.", "10.0.0.1", dns.RcodeSuccess, false, false},
+		{"rcodes SERVFAIL NXDOMAIN", "example.org.", "10.0.0.1", dns.RcodeSuccess, true, false},
+		{"rcodes SERVFAIL NXDOMAIN", "example.org.", "10.0.0.1", dns.RcodeNameError, true, true},
+		{"types CLIENT_RESPONSE\nrcodes SERVFAIL", "example.org.", "10.0.0.1", dns.RcodeSuccess, false, false},
+		{"types CLIENT_RESPONSE\nrcodes SERVFAIL", "example.org.", "10.0.0.1", dns.RcodeServerFailure, false, true},
+		{"clients 10.0.0.0/8 2001:db8::1", "example.org.", "10.0.0.1", dns.RcodeSuccess, true, true},
+		{"clients 10.0.0.0/8 2001:db8::1", "example.org.", "192.168.0.1", dns.RcodeSuccess, false, false},
+		{"clients 10.0.0.0/8 2001:db8::1", "example.org.", "2001:db8::1", dns.RcodeSuccess, true, true},
+		{"sample 1", "example.org.", "10.0.0.1", dns.RcodeSuccess, true, true},
+	}
+
+	for i, tc := range tests {
+		taps, err := parseConfig(caddy.NewTestController("dns", "dnstap dnstap.sock {\n"+tc.config+"\n}"))
+		if err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		c := counter{}
+		h := taps[0]
+		h.io = c
+		h.Next = test.HandlerFunc(func(_ context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			m := new(dns.Msg)
+			m.SetRcode(r, tc.rcode)
+			return 0, w.WriteMsg(m)
+		})
+
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		h.ServeDNS(context.TODO(), &test.ResponseWriter{RemoteIP: tc.client}, m)
+
+		if got := c[tap.Message_CLIENT_QUERY] == 1; got != tc.query {
+			t.Errorf("Test %d: expected query to be tapped to be %t", i, tc.query)
+		}
+		if got := c[tap.Message_CLIENT_RESPONSE] == 1; got != tc.response {
+			t.Errorf("Test %d: expected response to be tapped to be %t", i, tc.response)
+		}
+	}
+}
+
+func TestFilterSample(t *testing.T) {
+	f := filter{sample: 4}
+	sampled := 0
+	for id := 0; id < 4000; id++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		m.Id = uint16(id)
+		state := request.Request{W: &test.ResponseWriter{}, Req: m}
+
+		query := f.match(tap.Message_CLIENT_QUERY, state, nil)
+		if response := f.match(tap.Message_CLIENT_RESPONSE, state, m); query != response {
+			t.Fatalf("Expected query and response to be sampled together")
+		}
+		if query {
+			sampled++
+		}
+	}
+	// A quarter, give or take.
+	if sampled < 800 || sampled > 1200 {
+		t.Errorf("Expected about 1000 sampled queries, got %d", sampled)
+	}
+}
+
+func TestFilterConfig(t *testing.T) {
+	tests := []struct {
+		config string
+		fail   bool
+	}{
+		{"types CLIENT_QUERY FORWARDER_RESPONSE", false},
+		{"types", true},
+		{"types AUTH_QUERY", true},
+		{"names example.org example.net.", false},
+		{"names", true},
+		{"rcodes NOERROR servfail", false},
+		{"rcodes", true},
+		{"rcodes FOO", true},
+		{"clients 10.0.0.0/8 ::1", false},
+		{"clients", true},
+		{"clients 10.0.0.0/33", true},
+		{"sample 100", false},
+		{"sample", true},
+		{"sample 0", true},
+		{"sample -1", true},
+	}
+	for i, tc := range tests {
+		taps, err := parseConfig(caddy.NewTestController("dns", "dnstap dnstap.sock {\n"+tc.config+"\n}"))
+		if (err != nil) != tc.fail {
+			t.Errorf("Test %d: expected error to be %t, got %v", i, tc.fail, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if tc.config == "clients 10.0.0.0/8 ::1" {
+			if _, ok := taps[0].filter.clients.GetByIP(net.ParseIP("::1")); !ok {
+				t.Errorf("Test %d: expected ::1 to be a client", i)
+			}
+		}
+	}
+}
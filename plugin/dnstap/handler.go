@@ -26,6 +26,15 @@ type Dnstap struct {
 	ExtraFormat         string
 	MultipleTcpWriteBuf int // *Mb
 	MultipleQueue       int // *10000
+
+	filter filter
+}
+
+// ShouldTap returns true if the message of type t for the query in state, and the response resp if
+// not nil, passes the configured filters. Call it before building the message, so that filtered out
+// messages don't cost anything.
+func (h *Dnstap) ShouldTap(t tap.Message_Type, state request.Request, resp *dns.Msg) bool {
+	return h.filter.match(t, state, resp)
 }
 
 // TapMessage sends the message m to the dnstap interface, without populating "Extra" field.
@@ -53,6 +62,11 @@ func (h *Dnstap) tapWithExtra(m *tap.Message, extra []byte) {
 }
 
 func (h *Dnstap) tapQuery(ctx context.Context, w dns.ResponseWriter, query *dns.Msg, queryTime time.Time) {
+	state := request.Request{W: w, Req: query}
+	if !h.ShouldTap(tap.Message_CLIENT_QUERY, state, nil) {
+		return
+	}
+
 	q := new(tap.Message)
 	msg.SetQueryTime(q, queryTime)
 	msg.SetQueryAddress(q, w.RemoteAddr())
@@ -62,7 +76,6 @@ func (h *Dnstap) tapQuery(ctx context.Context, w dns.ResponseWriter, query *dns.
 		q.QueryMessage = buf
 	}
 	msg.SetType(q, tap.Message_CLIENT_QUERY)
-	state := request.Request{W: w, Req: query}
 	h.TapMessageWithMetadata(ctx, q, state)
 }
 
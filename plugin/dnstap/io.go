@@ -36,11 +36,12 @@ type dio struct {
 	tcpTimeout      time.Duration
 	skipVerify      bool
 	tcpWriteBufSize int
+	file            *rotator // only set when writing to a local file.
 }
 
 // newIO returns a new and initialized pointer to a dio.
 func newIO(proto, endpoint string, multipleQueue int, multipleTcpWriteBuf int) *dio {
-	return &dio{
+	d := &dio{
 		endpoint:        endpoint,
 		proto:           proto,
 		queue:           make(chan *tap.Dnstap, multipleQueue*queueSize),
@@ -50,9 +51,17 @@ func newIO(proto, endpoint string, multipleQueue int, multipleTcpWriteBuf int) *
 		skipVerify:      skipVerify,
 		tcpWriteBufSize: multipleTcpWriteBuf * tcpWriteBufSize,
 	}
+	if proto == "file" {
+		d.file = newRotator(endpoint)
+	}
+	return d
 }
 
 func (d *dio) dial() error {
+	if d.file != nil {
+		return d.openFile()
+	}
+
 	var conn net.Conn
 	var err error
 
@@ -126,11 +135,15 @@ func (d *dio) serve() {
 			}
 			d.enc.flush()
 			d.enc.close()
+			if d.file != nil {
+				d.file.close()
+			}
 			return
 		case payload := <-d.queue:
 			if err := d.write(payload); err != nil {
 				d.dial()
 			}
+			d.rotate()
 		case <-timeout.C:
 			if dropped := atomic.SwapUint32(&d.dropped, 0); dropped > 0 {
 				log.Warningf("Dropped dnstap messages: %d", dropped)
@@ -139,6 +152,7 @@ func (d *dio) serve() {
 				d.dial()
 			} else {
 				d.enc.flush()
+				d.rotate()
 			}
 		}
 	}
@@ -1,16 +1,23 @@
 package dnstap
 
 import (
+	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
 	"github.com/coredns/coredns/plugin/pkg/replacer"
+
+	tap "github.com/dnstap/golang-dnstap"
+	"github.com/infobloxopen/go-trees/iptree"
+	"github.com/miekg/dns"
 )
 
 var log = clog.NewWithPlugin("dnstap")
@@ -61,6 +68,14 @@ func parseConfig(c *caddy.Controller) ([]*Dnstap, error) {
 			}
 			dio = newIO("tcp", endpointURL.Host, d.MultipleQueue, d.MultipleTcpWriteBuf)
 			d.io = dio
+		} else if strings.HasPrefix(endpoint, "file://") {
+			// local file
+			endpoint = strings.TrimPrefix(endpoint, "file://")
+			if endpoint == "" {
+				return nil, c.ArgErr()
+			}
+			dio = newIO("file", endpoint, d.MultipleQueue, d.MultipleTcpWriteBuf)
+			d.io = dio
 		} else {
 			endpoint = strings.TrimPrefix(endpoint, "unix://")
 			dio = newIO("unix", endpoint, d.MultipleQueue, d.MultipleTcpWriteBuf)
@@ -100,6 +115,108 @@ func parseConfig(c *caddy.Controller) ([]*Dnstap, error) {
 					}
 					d.ExtraFormat = c.Val()
 				}
+			case "rotate-size":
+				if dio.file == nil {
+					return nil, c.Errf("%s is only valid for a file endpoint", c.Val())
+				}
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				n, err := parseSize(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid size %q", c.Val())
+				}
+				dio.file.size = n
+			case "rotate-interval":
+				if dio.file == nil {
+					return nil, c.Errf("%s is only valid for a file endpoint", c.Val())
+				}
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				dur, err := time.ParseDuration(c.Val())
+				if err != nil || dur < 0 {
+					return nil, c.Errf("invalid interval %q", c.Val())
+				}
+				dio.file.interval = dur
+			case "max-files":
+				if dio.file == nil {
+					return nil, c.Errf("%s is only valid for a file endpoint", c.Val())
+				}
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(c.Val())
+				if err != nil || n < 0 {
+					return nil, c.Errf("invalid number of files %q", c.Val())
+				}
+				dio.file.keep = n
+			case "types":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				d.filter.types = make(map[tap.Message_Type]struct{})
+				for _, a := range args {
+					t, ok := messageTypes[strings.ToUpper(a)]
+					if !ok {
+						return nil, c.Errf("unknown message type %q", a)
+					}
+					d.filter.types[t] = struct{}{}
+				}
+			case "names":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					if _, ok := dns.IsDomainName(a); !ok {
+						return nil, c.Errf("invalid name %q", a)
+					}
+					d.filter.names = append(d.filter.names, plugin.Name(a).Normalize())
+				}
+			case "rcodes":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				d.filter.rcodes = make(map[int]struct{})
+				for _, a := range args {
+					rcode, ok := dns.StringToRcode[strings.ToUpper(a)]
+					if !ok {
+						return nil, c.Errf("unknown rcode %q", a)
+					}
+					d.filter.rcodes[rcode] = struct{}{}
+				}
+			case "clients":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				d.filter.clients = iptree.NewTree()
+				for _, a := range args {
+					if !strings.Contains(a, "/") {
+						if strings.Contains(a, ":") {
+							a += "/128"
+						} else {
+							a += "/32"
+						}
+					}
+					_, n, err := net.ParseCIDR(a)
+					if err != nil {
+						return nil, c.Errf("invalid client network %q", a)
+					}
+					d.filter.clients.InplaceInsertNet(n, struct{}{})
+				}
+			case "sample":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.ParseUint(c.Val(), 10, 32)
+				if err != nil || n == 0 {
+					return nil, c.Errf("invalid sample rate %q", c.Val())
+				}
+				d.filter.sample = uint32(n)
 			}
 		}
 		dnstaps = append(dnstaps, &d)
@@ -107,6 +224,30 @@ func parseConfig(c *caddy.Controller) ([]*Dnstap, error) {
 	return dnstaps, nil
 }
 
+// parseSize parses a size in bytes, with an optional K, M or G suffix.
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1024
+	case strings.HasSuffix(s, "M"):
+		mult = 1024 * 1024
+	case strings.HasSuffix(s, "G"):
+		mult = 1024 * 1024 * 1024
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("negative size: %d", n)
+	}
+	return n * mult, nil
+}
+
 func setup(c *caddy.Controller) error {
 	dnstaps, err := parseConfig(c)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
@@ -143,3 +144,53 @@ func TestMultiDnstap(t *testing.T) {
 		t.Error("expected third plugin to be last, but Next is not nil")
 	}
 }
+
+func TestConfigFile(t *testing.T) {
+	tests := []struct {
+		in       string
+		fail     bool
+		path     string
+		size     int64
+		interval time.Duration
+		keep     int
+	}{
+		{"dnstap file:///var/log/dnstap.dnstap", false, "/var/log/dnstap.dnstap", defaultRotateSize, 0, defaultMaxFiles},
+		{"dnstap file://dnstap.dnstap full {\nrotate-size 10M\nrotate-interval 1h\nmax-files 24\n}", false, "dnstap.dnstap", 10 * 1024 * 1024, time.Hour, 24},
+		{"dnstap file://dnstap.dnstap {\nrotate-size 0\nmax-files 0\n}", false, "dnstap.dnstap", 0, 0, 0},
+		{"dnstap file://dnstap.dnstap {\nrotate-size 512\n}", false, "dnstap.dnstap", 512, 0, defaultMaxFiles},
+		{"dnstap file://", true, "", 0, 0, 0},
+		{"dnstap file://dnstap.dnstap {\nrotate-size\n}", true, "", 0, 0, 0},
+		{"dnstap file://dnstap.dnstap {\nrotate-size 10T\n}", true, "", 0, 0, 0},
+		{"dnstap file://dnstap.dnstap {\nrotate-size -1\n}", true, "", 0, 0, 0},
+		{"dnstap file://dnstap.dnstap {\nrotate-interval 1\n}", true, "", 0, 0, 0},
+		{"dnstap file://dnstap.dnstap {\nmax-files -1\n}", true, "", 0, 0, 0},
+		{"dnstap dnstap.sock {\nrotate-size 10M\n}", true, "", 0, 0, 0},
+		{"dnstap tcp://127.0.0.1:6000 {\nmax-files 10\n}", true, "", 0, 0, 0},
+	}
+	for i, tc := range tests {
+		taps, err := parseConfig(caddy.NewTestController("dns", tc.in))
+		if (err != nil) != tc.fail {
+			t.Errorf("Test %d: expected error to be %t, got %v", i, tc.fail, err)
+			continue
+		}
+		if tc.fail {
+			continue
+		}
+		d := taps[0].io.(*dio)
+		if d.proto != "file" || d.file == nil {
+			t.Fatalf("Test %d: expected a file endpoint, got %s", i, d.proto)
+		}
+		if d.file.path != tc.path {
+			t.Errorf("Test %d: expected path %s, got %s", i, tc.path, d.file.path)
+		}
+		if d.file.size != tc.size {
+			t.Errorf("Test %d: expected size %d, got %d", i, tc.size, d.file.size)
+		}
+		if d.file.interval != tc.interval {
+			t.Errorf("Test %d: expected interval %s, got %s", i, tc.interval, d.file.interval)
+		}
+		if d.file.keep != tc.keep {
+			t.Errorf("Test %d: expected max-files %d, got %d", i, tc.keep, d.file.keep)
+		}
+	}
+}
@@ -27,6 +27,11 @@ func (w *ResponseWriter) WriteMsg(resp *dns.Msg) error {
 		return err
 	}
 
+	state := request.Request{W: w.ResponseWriter, Req: w.query}
+	if !w.ShouldTap(tap.Message_CLIENT_RESPONSE, state, resp) {
+		return nil
+	}
+
 	r := new(tap.Message)
 	msg.SetQueryTime(r, w.queryTime)
 	msg.SetResponseTime(r, time.Now())
@@ -38,7 +43,6 @@ func (w *ResponseWriter) WriteMsg(resp *dns.Msg) error {
 	}
 
 	msg.SetType(r, tap.Message_CLIENT_RESPONSE)
-	state := request.Request{W: w.ResponseWriter, Req: w.query}
 	w.TapMessageWithMetadata(w.ctx, r, state)
 	return nil
 }
@@ -4,12 +4,14 @@ package errors
 import (
 	"context"
 	"regexp"
+	"strconv"
 	"sync/atomic"
 	"time"
 	"unsafe"
 
 	"github.com/coredns/coredns/plugin"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/replacer"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -37,6 +39,7 @@ func (p *pattern) setTimer(t *time.Timer) {
 type errorHandler struct {
 	patterns []*pattern
 	stopFlag uint32
+	json     bool // log errors as JSON objects.
 	Next     plugin.Handler
 }
 
@@ -46,10 +49,19 @@ func newErrorHandler() *errorHandler {
 
 func (h *errorHandler) logPattern(i int) {
 	cnt := atomic.SwapUint32(&h.patterns[i].count, 0)
-	if cnt > 0 {
-		h.patterns[i].logCallback("%d errors like '%s' occurred in last %s",
-			cnt, h.patterns[i].pattern.String(), h.patterns[i].period)
+	if cnt == 0 {
+		return
 	}
+	if h.json {
+		b := []byte{'{'}
+		b = replacer.AppendJSONInt(b, "count", int64(cnt))
+		b = replacer.AppendJSONField(b, "pattern", h.patterns[i].pattern.String())
+		b = replacer.AppendJSONField(b, "period", h.patterns[i].period.String())
+		h.patterns[i].logCallback("%s", append(b, '}'))
+		return
+	}
+	h.patterns[i].logCallback("%d errors like '%s' occurred in last %s",
+		cnt, h.patterns[i].pattern.String(), h.patterns[i].period)
 }
 
 func (h *errorHandler) inc(i int) bool {
@@ -94,11 +106,30 @@ func (h *errorHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dn
 			}
 		}
 		state := request.Request{W: w, Req: r}
-		log.Errorf("%d %s %s: %s", rcode, state.Name(), state.Type(), strErr)
+		if h.json {
+			log.Error(errorJSON(rcode, state, strErr))
+		} else {
+			log.Errorf("%d %s %s: %s", rcode, state.Name(), state.Type(), strErr)
+		}
 	}
 
 	return rcode, err
 }
 
+// errorJSON returns the error as a JSON object.
+func errorJSON(rcode int, state request.Request, err string) string {
+	rc := dns.RcodeToString[rcode]
+	if rc == "" {
+		rc = strconv.Itoa(rcode)
+	}
+	b := []byte{'{'}
+	b = replacer.AppendJSONField(b, "remote", state.IP())
+	b = replacer.AppendJSONField(b, "name", state.Name())
+	b = replacer.AppendJSONField(b, "type", state.Type())
+	b = replacer.AppendJSONField(b, "rcode", rc)
+	b = replacer.AppendJSONField(b, "error", err)
+	return string(append(b, '}'))
+}
+
 // Name implements the plugin.Handler interface.
 func (h *errorHandler) Name() string { return "errors" }
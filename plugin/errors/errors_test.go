@@ -235,3 +235,31 @@ func genErrorHandler(rcode int, err error) plugin.Handler {
 		return rcode, err
 	})
 }
+
+func TestErrorsJSON(t *testing.T) {
+	buf := bytes.Buffer{}
+	golog.SetOutput(&buf)
+	em := errorHandler{json: true, Next: genErrorHandler(dns.RcodeServerFailure, errors.New(`read "udp": timeout`))}
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	em.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+
+	want := `[ERROR] plugin/errors: {"remote":"10.240.0.1","name":"example.org.","type":"A","rcode":"SERVFAIL","error":"read \"udp\": timeout"}`
+	if log := buf.String(); !strings.Contains(log, want) {
+		t.Errorf("Expected log %q, but got %q", want, log)
+	}
+
+	buf.Reset()
+	em.patterns = []*pattern{{
+		count:       4,
+		period:      2 * time.Second,
+		pattern:     regexp.MustCompile("^error.*!$"),
+		logCallback: log.Errorf,
+	}}
+	em.logPattern(0)
+	want = `[ERROR] plugin/errors: {"count":4,"pattern":"^error.*!$","period":"2s"}`
+	if log := buf.String(); !strings.Contains(log, want) {
+		t.Errorf("Expected log %q, but got %q", want, log)
+	}
+}
@@ -55,6 +55,19 @@ func errorsParse(c *caddy.Controller) (*errorHandler, error) {
 			switch c.Val() {
 			case "stacktrace":
 				dnsserver.GetConfig(c).Stacktrace = true
+			case "format":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				switch args[0] {
+				case "text":
+					handler.json = false
+				case "json":
+					handler.json = true
+				default:
+					return nil, c.Errf("unknown format: %s", args[0])
+				}
 			case "consolidate":
 				pattern, err := parseConsolidate(c)
 				if err != nil {
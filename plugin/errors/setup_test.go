@@ -49,6 +49,18 @@ func TestErrorsParse(t *testing.T) {
             stacktrace
 		  }`, false, 0, true},
 		{`errors {
+		    format json
+		  }`, false, 0, false},
+		{`errors {
+		    format text
+		  }`, false, 0, false},
+		{`errors {
+		    format
+		  }`, true, 0, false},
+		{`errors {
+		    format yaml
+		  }`, true, 0, false},
+		{`errors {
             stacktrace
 		    consolidate 1m ^exact$
 		  }`, false, 1, true},
@@ -10,6 +10,7 @@ import (
 	"github.com/coredns/coredns/plugin/pkg/fall"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
 	"github.com/coredns/coredns/plugin/transfer"
+	"github.com/coredns/coredns/plugin/tsig"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -79,6 +80,22 @@ func (f File) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (i
 		return dns.RcodeSuccess, nil
 	}
 
+	if r.Opcode == dns.OpcodeUpdate {
+		rcode, changed := z.update(tsig.KeyName(ctx), r)
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		w.WriteMsg(m)
+
+		if changed {
+			go func() {
+				if err := f.transfer.Notify(zone); err != nil {
+					log.Warningf("Failed sending notifies: %s", err)
+				}
+			}()
+		}
+		return dns.RcodeSuccess, nil
+	}
+
 	z.RLock()
 	exp := z.Expired
 	z.RUnlock()
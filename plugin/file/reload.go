@@ -19,35 +19,7 @@ func (z *Zone) Reload(t *transfer.Transfer) error {
 		for {
 			select {
 			case <-tick.C:
-				zFile := z.File()
-				reader, err := os.Open(filepath.Clean(zFile))
-				if err != nil {
-					log.Errorf("Failed to open zone %q in %q: %v", z.origin, zFile, err)
-					continue
-				}
-
-				serial := z.SOASerialIfDefined()
-				zone, err := Parse(reader, z.origin, zFile, serial)
-				reader.Close()
-				if err != nil {
-					if _, ok := err.(*serialErr); !ok {
-						log.Errorf("Parsing zone %q: %v", z.origin, err)
-					}
-					continue
-				}
-
-				// copy elements we need
-				z.Lock()
-				z.Apex = zone.Apex
-				z.Tree = zone.Tree
-				z.Unlock()
-
-				log.Infof("Successfully reloaded zone %q in %q with %d SOA serial", z.origin, zFile, z.SOA.Serial)
-				if t != nil {
-					if err := t.Notify(z.origin); err != nil {
-						log.Warningf("Failed sending notifies: %s", err)
-					}
-				}
+				z.reload(t)
 
 			case <-z.reloadShutdown:
 				tick.Stop()
@@ -58,6 +30,47 @@ func (z *Zone) Reload(t *transfer.Transfer) error {
 	return nil
 }
 
+// reload reads the zone file again and replaces the zone when the file has a greater SOA serial, compared
+// with RFC 1982 serial arithmetic. It holds updateMu, so it doesn't interleave with a dynamic update.
+func (z *Zone) reload(t *transfer.Transfer) {
+	z.updateMu.Lock()
+	defer z.updateMu.Unlock()
+
+	zFile := z.File()
+	reader, err := os.Open(filepath.Clean(zFile))
+	if err != nil {
+		log.Errorf("Failed to open zone %q in %q: %v", z.origin, zFile, err)
+		return
+	}
+
+	serial := z.SOASerialIfDefined()
+	zone, err := Parse(reader, z.origin, zFile, serial)
+	reader.Close()
+	if err != nil {
+		if _, ok := err.(*serialErr); !ok {
+			log.Errorf("Parsing zone %q: %v", z.origin, err)
+		}
+		return
+	}
+	if serial >= 0 && !less(uint32(serial), zone.SOA.Serial) {
+		log.Warningf("Not reloading zone %q in %q: SOA serial %d is not greater than %d", z.origin, zFile, zone.SOA.Serial, serial)
+		return
+	}
+
+	// copy elements we need
+	z.Lock()
+	z.Apex = zone.Apex
+	z.Tree = zone.Tree
+	z.Unlock()
+
+	log.Infof("Successfully reloaded zone %q in %q with %d SOA serial", z.origin, zFile, zone.SOA.Serial)
+	if t != nil {
+		if err := t.Notify(z.origin); err != nil {
+			log.Warningf("Failed sending notifies: %s", err)
+		}
+	}
+}
+
 // SOASerialIfDefined returns the SOA's serial if the zone has a SOA record in the Apex, or -1 otherwise.
 func (z *Zone) SOASerialIfDefined() int64 {
 	z.RLock()
@@ -70,6 +70,27 @@ func TestZoneReload(t *testing.T) {
 	}
 }
 
+func TestZoneReloadLowerSerial(t *testing.T) {
+	fileName, rm, err := test.TempFile(".", reloadZone2Test)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+	z, err := Parse(strings.NewReader(reloadZone2Test), "miek.nl", fileName, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse zone: %s", err)
+	}
+
+	// A file with a lower serial isn't loaded.
+	if err := os.WriteFile(fileName, []byte(reloadZoneTest), 0644); err != nil {
+		t.Fatalf("Failed to write new zone data: %s", err)
+	}
+	z.reload(nil)
+	if x := z.SOASerialIfDefined(); x != 1460175182 {
+		t.Errorf("Expected SOA serial %d, got %d", 1460175182, x)
+	}
+}
+
 func TestZoneReloadSOAChange(t *testing.T) {
 	_, err := Parse(strings.NewReader(reloadZoneTest), "miek.nl.", "stdin", 1460175181)
 	if err == nil {
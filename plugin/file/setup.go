@@ -12,6 +12,8 @@ import (
 	"github.com/coredns/coredns/plugin/pkg/fall"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
 	"github.com/coredns/coredns/plugin/transfer"
+
+	"github.com/miekg/dns"
 )
 
 func init() { plugin.Register("file", setup) }
@@ -117,6 +119,7 @@ func fileParse(c *caddy.Controller) (Zones, fall.F, error) {
 			return Zones{}, fall, err
 		}
 
+		var updates []updatePolicy
 		for c.NextBlock() {
 			switch c.Val() {
 			case "fallthrough":
@@ -134,6 +137,26 @@ func fileParse(c *caddy.Controller) (Zones, fall.F, error) {
 			case "upstream":
 				// remove soon
 				c.RemainingArgs()
+			case "update":
+				args := c.RemainingArgs()
+				if len(args) < 1 {
+					return Zones{}, fall, c.ArgErr()
+				}
+				if len(origins) > 1 {
+					return Zones{}, fall, c.Errf("update needs a zone file with a single zone, %q has %d", fileName, len(origins))
+				}
+				p := updatePolicy{key: args[0]}
+				if p.key != "*" {
+					p.key = plugin.Name(p.key).Normalize()
+				}
+				for _, n := range args[1:] {
+					n = plugin.Name(n).Normalize()
+					if !dns.IsSubDomain(origins[0], n) {
+						return Zones{}, fall, c.Errf("update name %q is not in zone %q", n, origins[0])
+					}
+					p.names = append(p.names, n)
+				}
+				updates = append(updates, p)
 
 			default:
 				return Zones{}, fall, c.Errf("unknown property '%s'", c.Val())
@@ -143,6 +166,7 @@ func fileParse(c *caddy.Controller) (Zones, fall.F, error) {
 		for i := range origins {
 			z[origins[i]].ReloadInterval = reload
 			z[origins[i]].Upstream = upstream.New()
+			z[origins[i]].updatePolicy = updates
 		}
 	}
 
@@ -1,6 +1,7 @@
 package file
 
 import (
+	"reflect"
 	"testing"
 	"time"
 
@@ -150,3 +151,60 @@ func TestParseReload(t *testing.T) {
 		}
 	}
 }
+
+func TestParseUpdate(t *testing.T) {
+	name, rm, err := test.TempFile(".", dbMiekNL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm()
+
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  []updatePolicy
+	}{
+		{`file ` + name + ` miek.nl.`, false, nil},
+		{
+			`file ` + name + ` miek.nl. {
+				update Key.Miek.NL
+				update * dyn.miek.nl. www.miek.nl.
+			}`,
+			false,
+			[]updatePolicy{{key: "key.miek.nl."}, {key: "*", names: []string{"dyn.miek.nl.", "www.miek.nl."}}},
+		},
+		// errors.
+		{
+			`file ` + name + ` miek.nl. {
+				update
+			}`,
+			true, nil,
+		},
+		{
+			`file ` + name + ` miek.nl. {
+				update key. example.org.
+			}`,
+			true, nil,
+		},
+		{
+			`file ` + name + ` miek.nl. example.org. {
+				update key.
+			}`,
+			true, nil,
+		},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		z, _, err := fileParse(c)
+		if (err != nil) != tc.shouldErr {
+			t.Fatalf("Test %d: expected error %t, got %v", i, tc.shouldErr, err)
+		}
+		if tc.shouldErr {
+			continue
+		}
+		if x := z.Z["miek.nl."].updatePolicy; !reflect.DeepEqual(x, tc.expected) {
+			t.Errorf("Test %d: expected update policy %v, got %v", i, tc.expected, x)
+		}
+	}
+}
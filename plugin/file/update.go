@@ -0,0 +1,446 @@
+package file
+
+import (
+	"bufio"
+	"cmp"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/file/tree"
+
+	"github.com/miekg/dns"
+)
+
+// updatePolicy allows dynamic updates, signed with a TSIG key, of names in a zone.
+type updatePolicy struct {
+	key   string   // name of the TSIG key, or "*" for any key
+	names []string // names that may be updated, including the names below them; the whole zone when empty
+}
+
+// allowed returns true if the policy allows key to update name.
+func (p updatePolicy) allowed(key, name string) bool {
+	if p.key != "*" && p.key != key {
+		return false
+	}
+	if len(p.names) == 0 {
+		return true
+	}
+	for _, n := range p.names {
+		if dns.IsSubDomain(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateAllowed returns true if one of the update policies of z allows key to update name.
+func (z *Zone) updateAllowed(key, name string) bool {
+	for _, p := range z.updatePolicy {
+		if p.allowed(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// update applies the dynamic update (RFC 2136) in r, signed with the TSIG key key, to z. It returns the rcode
+// for the response and true when the zone has been changed. The updated zone is written to the zone file,
+// before it is served.
+func (z *Zone) update(key string, r *dns.Msg) (int, bool) {
+	if len(z.updatePolicy) == 0 || key == "" {
+		return dns.RcodeRefused, false
+	}
+	if len(r.Question) != 1 || r.Question[0].Qtype != dns.TypeSOA || r.Question[0].Qclass != dns.ClassINET {
+		return dns.RcodeFormatError, false
+	}
+	if strings.ToLower(r.Question[0].Name) != z.origin {
+		return dns.RcodeNotAuth, false
+	}
+
+	z.updateMu.Lock()
+	defer z.updateMu.Unlock()
+
+	z.RLock()
+	ap, tr, file := z.Apex, z.Tree, z.file
+	z.RUnlock()
+	if ap.SOA == nil {
+		return dns.RcodeServerFailure, false
+	}
+	if len(ap.SIGSOA) > 0 {
+		log.Warningf("Refusing update of zone %q: the zone is DNSSEC signed", z.origin)
+		return dns.RcodeRefused, false
+	}
+	// The zone file is rewritten from the records, the records of an included file would end up in it.
+	include, err := usesInclude(file)
+	if err != nil {
+		log.Errorf("Failed to read zone file %q: %s", file, err)
+		return dns.RcodeServerFailure, false
+	}
+	if include {
+		log.Warningf("Refusing update of zone %q: the zone file %q uses $INCLUDE", z.origin, file)
+		return dns.RcodeRefused, false
+	}
+
+	recs := newZoneRecords(ap, tr)
+	if rcode := recs.prerequisites(z.origin, r.Answer); rcode != dns.RcodeSuccess {
+		return rcode, false
+	}
+	if rcode := z.prescan(key, r.Ns); rcode != dns.RcodeSuccess {
+		return rcode, false
+	}
+	if !recs.apply(z.origin, r.Ns) {
+		return dns.RcodeSuccess, false
+	}
+
+	// Increase the serial, unless the update itself did.
+	soa := recs.rrset(z.origin, dns.TypeSOA)[0].(*dns.SOA)
+	if soa.Serial == ap.SOA.Serial {
+		soa.Serial++
+	}
+
+	z1 := NewZone(z.origin, file)
+	for _, rrs := range recs {
+		for _, rr := range rrs {
+			if err := z1.Insert(rr); err != nil {
+				log.Errorf("Failed to update zone %q: %s", z.origin, err)
+				return dns.RcodeServerFailure, false
+			}
+		}
+	}
+	if err := writeZone(file, z1); err != nil {
+		log.Errorf("Failed to write updated zone %q to %q: %s", z.origin, file, err)
+		return dns.RcodeServerFailure, false
+	}
+
+	z.Lock()
+	z.Apex = z1.Apex
+	z.Tree = z1.Tree
+	z.Unlock()
+
+	log.Infof("Updated zone %q with key %q to %d SOA serial", z.origin, key, soa.Serial)
+	return dns.RcodeSuccess, true
+}
+
+// prescan checks the records in the update section, see RFC 2136 section 3.4.1, and if key may update them.
+func (z *Zone) prescan(key string, updates []dns.RR) int {
+	for _, rr := range updates {
+		h := rr.Header()
+		name := strings.ToLower(h.Name)
+		if !dns.IsSubDomain(z.origin, name) {
+			return dns.RcodeNotZone
+		}
+		switch h.Class {
+		case dns.ClassINET:
+			if isMetaType(h.Rrtype) {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassANY:
+			if h.Ttl != 0 || h.Rdlength != 0 || (isMetaType(h.Rrtype) && h.Rrtype != dns.TypeANY) {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassNONE:
+			if h.Ttl != 0 || isMetaType(h.Rrtype) {
+				return dns.RcodeFormatError
+			}
+		default:
+			return dns.RcodeFormatError
+		}
+		if isDNSSECType(h.Rrtype) || !z.updateAllowed(key, name) {
+			return dns.RcodeRefused
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// zoneRecords holds copies of the records of a zone by lower cased owner name, to apply an update to.
+type zoneRecords map[string][]dns.RR
+
+func newZoneRecords(ap Apex, tr *tree.Tree) zoneRecords {
+	recs := zoneRecords{}
+	add := func(rrs []dns.RR) {
+		for _, rr := range rrs {
+			name := strings.ToLower(rr.Header().Name)
+			recs[name] = append(recs[name], dns.Copy(rr))
+		}
+	}
+	add([]dns.RR{ap.SOA})
+	add(ap.NS)
+	add(ap.SIGSOA)
+	add(ap.SIGNS)
+	tr.Walk(func(e *tree.Elem, _ map[uint16][]dns.RR) error {
+		add(e.All())
+		return nil
+	})
+	return recs
+}
+
+// rrset returns the records with type qtype at name.
+func (recs zoneRecords) rrset(name string, qtype uint16) []dns.RR {
+	var rrs []dns.RR
+	for _, rr := range recs[name] {
+		if rr.Header().Rrtype == qtype {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs
+}
+
+// remove removes the records at name for which match returns true. It returns true if any were removed.
+func (recs zoneRecords) remove(name string, match func(dns.RR) bool) bool {
+	rrs := recs[name]
+	kept := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		if !match(rr) {
+			kept = append(kept, rr)
+		}
+	}
+	if len(kept) == len(rrs) {
+		return false
+	}
+	if len(kept) == 0 {
+		delete(recs, name)
+	} else {
+		recs[name] = kept
+	}
+	return true
+}
+
+// prerequisites checks the prerequisites of an update, see RFC 2136 section 3.2, and returns the rcode.
+func (recs zoneRecords) prerequisites(origin string, prereqs []dns.RR) int {
+	type rrsetKey struct {
+		name  string
+		qtype uint16
+	}
+	values := map[rrsetKey][]dns.RR{}
+
+	for _, rr := range prereqs {
+		h := rr.Header()
+		name := strings.ToLower(h.Name)
+		if h.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+		if !dns.IsSubDomain(origin, name) {
+			return dns.RcodeNotZone
+		}
+		switch h.Class {
+		case dns.ClassANY:
+			if h.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if h.Rrtype == dns.TypeANY {
+				if len(recs[name]) == 0 {
+					return dns.RcodeNameError
+				}
+			} else if len(recs.rrset(name, h.Rrtype)) == 0 {
+				return dns.RcodeNXRrset
+			}
+		case dns.ClassNONE:
+			if h.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if h.Rrtype == dns.TypeANY {
+				if len(recs[name]) > 0 {
+					return dns.RcodeYXDomain
+				}
+			} else if len(recs.rrset(name, h.Rrtype)) > 0 {
+				return dns.RcodeYXRrset
+			}
+		case dns.ClassINET:
+			k := rrsetKey{name, h.Rrtype}
+			values[k] = append(values[k], rr)
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	// The RRsets with values must be equal to the ones in the zone, ignoring the TTL.
+	for k, rrs := range values {
+		zone := recs.rrset(k.name, k.qtype)
+		if !containsAll(zone, rrs) || !containsAll(rrs, zone) {
+			return dns.RcodeNXRrset
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// containsAll returns true if every record in b has a duplicate in a.
+func containsAll(a, b []dns.RR) bool {
+	for _, rb := range b {
+		if !slices.ContainsFunc(a, func(ra dns.RR) bool { return dns.IsDuplicate(ra, rb) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply applies the updates, see RFC 2136 section 3.4.2, and returns true if recs has been changed.
+func (recs zoneRecords) apply(origin string, updates []dns.RR) bool {
+	changed := false
+	for _, rr := range updates {
+		h := rr.Header()
+		name := strings.ToLower(h.Name)
+		switch h.Class {
+		case dns.ClassINET:
+			if recs.add(origin, rr) {
+				changed = true
+			}
+		case dns.ClassANY:
+			// The SOA and NS records of the apex are never deleted.
+			apex := func(rr dns.RR) bool {
+				t := rr.Header().Rrtype
+				return name == origin && (t == dns.TypeSOA || t == dns.TypeNS)
+			}
+			if recs.remove(name, func(r dns.RR) bool {
+				return (h.Rrtype == dns.TypeANY || r.Header().Rrtype == h.Rrtype) && !apex(r)
+			}) {
+				changed = true
+			}
+		case dns.ClassNONE:
+			if h.Rrtype == dns.TypeSOA {
+				continue
+			}
+			if h.Rrtype == dns.TypeNS && name == origin && len(recs.rrset(origin, dns.TypeNS)) <= 1 {
+				continue
+			}
+			del := dns.Copy(rr)
+			del.Header().Class = dns.ClassINET
+			if recs.remove(name, func(r dns.RR) bool { return dns.IsDuplicate(r, del) }) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// add adds rr to recs, or replaces the record it duplicates. It returns true if recs has been changed.
+func (recs zoneRecords) add(origin string, rr dns.RR) bool {
+	rr = dns.Copy(rr)
+	rr.Header().Name = strings.ToLower(rr.Header().Name)
+	name := rr.Header().Name
+	rrs := recs[name]
+
+	switch rr.Header().Rrtype {
+	case dns.TypeSOA:
+		if name != origin {
+			return false
+		}
+		soa := recs.rrset(origin, dns.TypeSOA)[0].(*dns.SOA)
+		// Only a SOA with a higher serial replaces the current one, see RFC 1982 for the comparison.
+		if int32(rr.(*dns.SOA).Serial-soa.Serial) <= 0 {
+			return false
+		}
+		recs.remove(origin, func(r dns.RR) bool { return r.Header().Rrtype == dns.TypeSOA })
+		recs[origin] = append(recs[origin], rr)
+		return true
+	case dns.TypeCNAME:
+		// A CNAME can't be added to a name with other data, and replaces the current CNAME.
+		for _, r := range rrs {
+			if t := r.Header().Rrtype; t != dns.TypeCNAME && !isDNSSECType(t) {
+				return false
+			}
+			if dns.IsDuplicate(r, rr) && r.Header().Ttl == rr.Header().Ttl {
+				return false
+			}
+		}
+		recs.remove(name, func(r dns.RR) bool { return r.Header().Rrtype == dns.TypeCNAME })
+		recs[name] = append(recs[name], rr)
+		return true
+	default:
+		if !isDNSSECType(rr.Header().Rrtype) && len(recs.rrset(name, dns.TypeCNAME)) > 0 {
+			return false
+		}
+	}
+
+	for i, r := range rrs {
+		if dns.IsDuplicate(r, rr) {
+			if r.Header().Ttl == rr.Header().Ttl {
+				return false
+			}
+			rrs[i] = rr
+			return true
+		}
+	}
+	recs[name] = append(rrs, rr)
+	return true
+}
+
+// writeZone writes the records of z to path. The file is replaced with a rename, so the zone is never
+// read from a partial file.
+func writeZone(path string, z *Zone) error {
+	apex, err := z.ApexIfDefined()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if fi, err := os.Stat(path); err == nil {
+		if err := f.Chmod(fi.Mode()); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	w := bufio.NewWriter(f)
+	write := func(rrs []dns.RR) {
+		for _, rr := range rrs {
+			w.WriteString(rr.String())
+			w.WriteByte('\n')
+		}
+	}
+	write(apex)
+	z.Walk(func(e *tree.Elem, _ map[uint16][]dns.RR) error {
+		rrs := e.All()
+		slices.SortStableFunc(rrs, func(a, b dns.RR) int { return cmp.Compare(a.Header().Rrtype, b.Header().Rrtype) })
+		write(rrs)
+		return nil
+	})
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// usesInclude returns true when the zone file at path has an $INCLUDE directive.
+func usesInclude(path string) (bool, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		if len(s.Text()) >= 8 && strings.EqualFold(s.Text()[:8], "$INCLUDE") {
+			return true, nil
+		}
+	}
+	return false, s.Err()
+}
+
+// isMetaType returns true for the types that can only be used in queries.
+func isMetaType(t uint16) bool {
+	switch t {
+	case dns.TypeANY, dns.TypeAXFR, dns.TypeIXFR, dns.TypeMAILA, dns.TypeMAILB:
+		return true
+	}
+	return false
+}
+
+// isDNSSECType returns true for the types that are maintained when signing a zone.
+func isDNSSECType(t uint16) bool {
+	switch t {
+	case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3, dns.TypeNSEC3PARAM:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,268 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/plugin/tsig"
+
+	"github.com/miekg/dns"
+)
+
+const dbUpdateTest = `$ORIGIN example.org.
+@	3600 IN	SOA ns.example.org. hostmaster.example.org. 2024010100 7200 3600 1209600 3600
+	3600 IN NS ns.example.org.
+ns	3600 IN A 192.0.2.1
+www	3600 IN A 192.0.2.10
+alias	3600 IN CNAME www.example.org.
+`
+
+func updateZone(t *testing.T) *Zone {
+	t.Helper()
+	return updateZoneFrom(t, dbUpdateTest)
+}
+
+// updateZoneFrom returns a zone that allows updates, loaded from a zone file with zone.
+func updateZoneFrom(t *testing.T, zone string) *Zone {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "db.example.org")
+	if err := os.WriteFile(name, []byte(zone), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	z, err := Parse(reader, "example.org.", name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z.updatePolicy = []updatePolicy{{key: "key."}, {key: "limited.", names: []string{"dyn.example.org."}}}
+	return z
+}
+
+// count returns the number of records with type qtype at name in z.
+func count(z *Zone, name string, qtype uint16) int {
+	z.RLock()
+	defer z.RUnlock()
+	if name == z.origin && qtype == dns.TypeNS {
+		return len(z.NS)
+	}
+	e, ok := z.Search(name)
+	if !ok {
+		return 0
+	}
+	return len(e.Type(qtype))
+}
+
+func TestUpdate(t *testing.T) {
+	z := updateZone(t)
+
+	// The cases are applied to the same zone, in order.
+	tests := []struct {
+		key     string
+		zone    string
+		prereq  func(m *dns.Msg)
+		update  func(m *dns.Msg)
+		rcode   int
+		serial  uint32
+		name    string
+		qtype   uint16
+		records int
+	}{
+		// Not signed.
+		{"", "example.org.", nil, func(m *dns.Msg) { m.Insert([]dns.RR{test.A("new.example.org. 300 IN A 192.0.2.20")}) },
+			dns.RcodeRefused, 2024010100, "new.example.org.", dns.TypeA, 0},
+		{"other.", "example.org.", nil, func(m *dns.Msg) { m.Insert([]dns.RR{test.A("new.example.org. 300 IN A 192.0.2.20")}) },
+			dns.RcodeRefused, 2024010100, "new.example.org.", dns.TypeA, 0},
+		{"key.", "www.example.org.", nil, func(m *dns.Msg) { m.Insert([]dns.RR{test.A("new.example.org. 300 IN A 192.0.2.20")}) },
+			dns.RcodeNotAuth, 2024010100, "new.example.org.", dns.TypeA, 0},
+		{"key.", "example.org.", nil, func(m *dns.Msg) { m.Insert([]dns.RR{test.A("new.example.net. 300 IN A 192.0.2.20")}) },
+			dns.RcodeNotZone, 2024010100, "new.example.net.", dns.TypeA, 0},
+		{"key.", "example.org.", nil, func(m *dns.Msg) { m.Insert([]dns.RR{test.A("New.example.org. 300 IN A 192.0.2.20")}) },
+			dns.RcodeSuccess, 2024010101, "new.example.org.", dns.TypeA, 1},
+		// Adding the same record again doesn't change the zone.
+		{"key.", "example.org.", nil, func(m *dns.Msg) { m.Insert([]dns.RR{test.A("new.example.org. 300 IN A 192.0.2.20")}) },
+			dns.RcodeSuccess, 2024010101, "new.example.org.", dns.TypeA, 1},
+		{"key.", "example.org.", func(m *dns.Msg) { m.NameNotUsed([]dns.RR{test.A("new.example.org. 0 IN A 0.0.0.0")}) },
+			func(m *dns.Msg) { m.Insert([]dns.RR{test.A("new.example.org. 300 IN A 192.0.2.21")}) },
+			dns.RcodeYXDomain, 2024010101, "new.example.org.", dns.TypeA, 1},
+		{"key.", "example.org.", func(m *dns.Msg) { m.NameUsed([]dns.RR{test.A("none.example.org. 0 IN A 0.0.0.0")}) },
+			func(m *dns.Msg) { m.Insert([]dns.RR{test.A("none.example.org. 300 IN A 192.0.2.21")}) },
+			dns.RcodeNameError, 2024010101, "none.example.org.", dns.TypeA, 0},
+		{"key.", "example.org.", func(m *dns.Msg) { m.RRsetUsed([]dns.RR{test.A("www.example.org. 0 IN A 0.0.0.0")}) },
+			func(m *dns.Msg) { m.Insert([]dns.RR{test.AAAA("www.example.org. 300 IN AAAA 2001:db8::10")}) },
+			dns.RcodeSuccess, 2024010102, "www.example.org.", dns.TypeAAAA, 1},
+		{"key.", "example.org.", func(m *dns.Msg) { m.RRsetNotUsed([]dns.RR{test.A("www.example.org. 0 IN A 0.0.0.0")}) },
+			func(m *dns.Msg) { m.Insert([]dns.RR{test.A("www.example.org. 300 IN A 192.0.2.11")}) },
+			dns.RcodeYXRrset, 2024010102, "www.example.org.", dns.TypeA, 1},
+		{"key.", "example.org.", func(m *dns.Msg) { m.Used([]dns.RR{test.A("www.example.org. 0 IN A 192.0.2.99")}) },
+			func(m *dns.Msg) { m.Insert([]dns.RR{test.A("www.example.org. 300 IN A 192.0.2.11")}) },
+			dns.RcodeNXRrset, 2024010102, "www.example.org.", dns.TypeA, 1},
+		{"key.", "example.org.", func(m *dns.Msg) { m.Used([]dns.RR{test.A("www.example.org. 0 IN A 192.0.2.10")}) },
+			func(m *dns.Msg) { m.Insert([]dns.RR{test.A("www.example.org. 300 IN A 192.0.2.11")}) },
+			dns.RcodeSuccess, 2024010103, "www.example.org.", dns.TypeA, 2},
+		// CNAMEs and other data don't mix.
+		{"key.", "example.org.", nil, func(m *dns.Msg) { m.Insert([]dns.RR{test.CNAME("www.example.org. 300 IN CNAME ns.example.org.")}) },
+			dns.RcodeSuccess, 2024010103, "www.example.org.", dns.TypeCNAME, 0},
+		{"key.", "example.org.", nil, func(m *dns.Msg) { m.Insert([]dns.RR{test.A("alias.example.org. 300 IN A 192.0.2.12")}) },
+			dns.RcodeSuccess, 2024010103, "alias.example.org.", dns.TypeA, 0},
+		{"key.", "example.org.", nil, func(m *dns.Msg) { m.Insert([]dns.RR{test.CNAME("alias.example.org. 300 IN CNAME ns.example.org.")}) },
+			dns.RcodeSuccess, 2024010104, "alias.example.org.", dns.TypeCNAME, 1},
+		// The names that a key may update.
+		{"limited.", "example.org.", nil, func(m *dns.Msg) { m.Insert([]dns.RR{test.A("www.example.org. 300 IN A 192.0.2.12")}) },
+			dns.RcodeRefused, 2024010104, "www.example.org.", dns.TypeA, 2},
+		{"limited.", "example.org.", nil, func(m *dns.Msg) { m.Insert([]dns.RR{test.A("host.dyn.example.org. 300 IN A 192.0.2.30")}) },
+			dns.RcodeSuccess, 2024010105, "host.dyn.example.org.", dns.TypeA, 1},
+		// Deletes.
+		{"key.", "example.org.", nil, func(m *dns.Msg) { m.Remove([]dns.RR{test.A("www.example.org. 300 IN A 192.0.2.10")}) },
+			dns.RcodeSuccess, 2024010106, "www.example.org.", dns.TypeA, 1},
+		{"key.", "example.org.", nil, func(m *dns.Msg) { m.RemoveRRset([]dns.RR{test.A("www.example.org. 0 IN A 0.0.0.0")}) },
+			dns.RcodeSuccess, 2024010107, "www.example.org.", dns.TypeAAAA, 1},
+		{"key.", "example.org.", nil, func(m *dns.Msg) { m.RemoveName([]dns.RR{test.A("www.example.org. 0 IN A 0.0.0.0")}) },
+			dns.RcodeSuccess, 2024010108, "www.example.org.", dns.TypeAAAA, 0},
+		// The last NS record of the zone and its SOA record are kept.
+		{"key.", "example.org.", nil, func(m *dns.Msg) { m.Remove([]dns.RR{test.NS("example.org. 3600 IN NS ns.example.org.")}) },
+			dns.RcodeSuccess, 2024010108, "example.org.", dns.TypeNS, 1},
+		{"key.", "example.org.", nil, func(m *dns.Msg) { m.RemoveName([]dns.RR{test.NS("example.org. 0 IN NS ns.example.org.")}) },
+			dns.RcodeSuccess, 2024010108, "example.org.", dns.TypeNS, 1},
+		// A SOA record with a higher serial sets the serial.
+		{"key.", "example.org.", nil, func(m *dns.Msg) {
+			m.Insert([]dns.RR{test.SOA("example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 2025010100 7200 3600 1209600 3600")})
+		}, dns.RcodeSuccess, 2025010100, "example.org.", dns.TypeNS, 1},
+		{"key.", "example.org.", nil, func(m *dns.Msg) {
+			m.Insert([]dns.RR{test.SOA("example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 2024010100 7200 3600 1209600 3600")})
+		}, dns.RcodeSuccess, 2025010100, "example.org.", dns.TypeNS, 1},
+		{"key.", "example.org.", nil, func(m *dns.Msg) {
+			m.Insert([]dns.RR{test.RRSIG("example.org. 3600 IN RRSIG A 8 2 3600 20250101000000 20240101000000 1 example.org. AAAA")})
+		}, dns.RcodeRefused, 2025010100, "example.org.", dns.TypeRRSIG, 0},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetUpdate(tc.zone)
+		if tc.prereq != nil {
+			tc.prereq(m)
+		}
+		tc.update(m)
+		// Pack and unpack, to get the records as they are received.
+		buf, err := m.Pack()
+		if err != nil {
+			t.Fatalf("Test %d: %s", i, err)
+		}
+		m = new(dns.Msg)
+		if err := m.Unpack(buf); err != nil {
+			t.Fatalf("Test %d: %s", i, err)
+		}
+
+		rcode, _ := z.update(tc.key, m)
+		if rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rcode])
+		}
+		if serial := z.SOASerialIfDefined(); serial != int64(tc.serial) {
+			t.Errorf("Test %d: expected serial %d, got %d", i, tc.serial, serial)
+		}
+		if n := count(z, tc.name, tc.qtype); n != tc.records {
+			t.Errorf("Test %d: expected %d %s records for %s, got %d", i, tc.records, dns.TypeToString[tc.qtype], tc.name, n)
+		}
+	}
+
+	// The updated zone is written to the zone file.
+	reader, err := os.Open(z.File())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	z1, err := Parse(reader, "example.org.", z.File(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if z1.SOA.Serial != 2025010100 {
+		t.Errorf("Expected serial %d in the zone file, got %d", 2025010100, z1.SOA.Serial)
+	}
+	for _, name := range []string{"new.example.org.", "host.dyn.example.org."} {
+		if n := count(z1, name, dns.TypeA); n != 1 {
+			t.Errorf("Expected 1 A record for %s in the zone file, got %d", name, n)
+		}
+	}
+}
+
+func TestUpdateCase(t *testing.T) {
+	z := updateZoneFrom(t, dbUpdateTest+"Mixed	3600 IN A 192.0.2.40\n")
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Remove([]dns.RR{test.A("mixed.example.org. 3600 IN A 192.0.2.40")})
+	if rcode, changed := z.update("key.", m); rcode != dns.RcodeSuccess || !changed {
+		t.Errorf("Expected the zone to change, got rcode %s", dns.RcodeToString[rcode])
+	}
+	if n := count(z, "mixed.example.org.", dns.TypeA); n != 0 {
+		t.Errorf("Expected %d A records for %s, got %d", 0, "mixed.example.org.", n)
+	}
+}
+
+func TestUpdateInclude(t *testing.T) {
+	include := filepath.Join(t.TempDir(), "db.include")
+	if err := os.WriteFile(include, []byte("inc 3600 IN A 192.0.2.50\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	z := updateZoneFrom(t, dbUpdateTest+"$INCLUDE "+include+"\n")
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Insert([]dns.RR{test.A("new.example.org. 300 IN A 192.0.2.20")})
+	if rcode, _ := z.update("key.", m); rcode != dns.RcodeRefused {
+		t.Errorf("Expected rcode %s, got %s", dns.RcodeToString[dns.RcodeRefused], dns.RcodeToString[rcode])
+	}
+}
+
+func TestServeDNSUpdate(t *testing.T) {
+	z := updateZone(t)
+	f := File{Zones: Zones{Z: map[string]*Zone{"example.org.": z}, Names: []string{"example.org."}}}
+	ts := &tsig.TSIGServer{Zones: []string{"example.org."}, Next: f}
+	ctx := context.TODO()
+
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	m.Insert([]dns.RR{test.A("new.example.org. 300 IN A 192.0.2.20")})
+
+	// Without TSIG the update is refused.
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := ts.ServeDNS(ctx, w, m.Copy()); err != nil {
+		t.Fatal(err)
+	}
+	if w.Msg.Rcode != dns.RcodeRefused {
+		t.Errorf("Expected rcode %s, got %s", dns.RcodeToString[dns.RcodeRefused], dns.RcodeToString[w.Msg.Rcode])
+	}
+
+	m.SetTsig("key.", dns.HmacSHA256, 300, time.Now().Unix())
+	w = dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := ts.ServeDNS(ctx, w, m); err != nil {
+		t.Fatal(err)
+	}
+	if w.Msg.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected rcode %s, got %s", dns.RcodeToString[dns.RcodeSuccess], dns.RcodeToString[w.Msg.Rcode])
+	}
+	if w.Msg.IsTsig() == nil {
+		t.Error("Expected TSIG in response")
+	}
+
+	tc := test.Case{Qname: "new.example.org.", Qtype: dns.TypeA, Answer: []dns.RR{
+		test.A("new.example.org. 300 IN A 192.0.2.20"),
+	}, Ns: []dns.RR{
+		test.NS("example.org. 3600 IN NS ns.example.org."),
+	}}
+	w = dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := f.ServeDNS(ctx, w, tc.Msg()); err != nil {
+		t.Fatal(err)
+	}
+	if err := test.SortAndCheck(w.Msg, tc); err != nil {
+		t.Error(err)
+	}
+}
@@ -31,6 +31,9 @@ type Zone struct {
 	reloadShutdown chan bool
 
 	Upstream *upstream.Upstream // Upstream for looking up external names during the resolution process.
+
+	updatePolicy []updatePolicy // Who may update which names with dynamic updates, none when empty.
+	updateMu     sync.Mutex     // Serializes dynamic updates.
 }
 
 // Apex contains the apex records of a zone: SOA, NS and their potential signatures.
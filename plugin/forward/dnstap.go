@@ -35,21 +35,23 @@ func toDnstap(ctx context.Context, f *Forward, host string, state request.Reques
 
 	for _, t := range f.tapPlugins {
 		// Query
-		q := new(tap.Message)
-		msg.SetQueryTime(q, start)
-		// Forwarder dnstap messages are from the perspective of the downstream server
-		// (upstream is the forward server)
-		msg.SetQueryAddress(q, state.W.RemoteAddr())
-		msg.SetResponseAddress(q, ta)
-		if t.IncludeRawMessage {
-			buf, _ := state.Req.Pack()
-			q.QueryMessage = buf
+		if t.ShouldTap(tap.Message_FORWARDER_QUERY, state, nil) {
+			q := new(tap.Message)
+			msg.SetQueryTime(q, start)
+			// Forwarder dnstap messages are from the perspective of the downstream server
+			// (upstream is the forward server)
+			msg.SetQueryAddress(q, state.W.RemoteAddr())
+			msg.SetResponseAddress(q, ta)
+			if t.IncludeRawMessage {
+				buf, _ := state.Req.Pack()
+				q.QueryMessage = buf
+			}
+			msg.SetType(q, tap.Message_FORWARDER_QUERY)
+			t.TapMessageWithMetadata(ctx, q, state)
 		}
-		msg.SetType(q, tap.Message_FORWARDER_QUERY)
-		t.TapMessageWithMetadata(ctx, q, state)
 
 		// Response
-		if reply != nil {
+		if reply != nil && t.ShouldTap(tap.Message_FORWARDER_RESPONSE, state, reply) {
 			r := new(tap.Message)
 			if t.IncludeRawMessage {
 				buf, _ := reply.Pack()
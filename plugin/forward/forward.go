@@ -8,6 +8,7 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"slices"
 	"sync/atomic"
 	"time"
 
@@ -17,11 +18,15 @@ import (
 	"github.com/coredns/coredns/plugin/metadata"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
 	"github.com/coredns/coredns/plugin/pkg/proxy"
+	"github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
 	ot "github.com/opentracing/opentracing-go"
 	otext "github.com/opentracing/opentracing-go/ext"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 var log = clog.NewWithPlugin("forward")
@@ -52,6 +57,9 @@ type Forward struct {
 	maxConcurrent              int64
 	failfastUnhealthyUpstreams bool
 
+	hedgeDelay time.Duration // send a hedged request after this delay, 0 disables hedging
+	hedgeRTTs  *rttWindow    // when set, the hedge delay is a percentile of the observed RTTs
+
 	opts proxy.Options // also here for testing
 
 	// ErrLimitExceeded indicates that a query was rejected because the number of concurrent queries has exceeded
@@ -115,7 +123,9 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 	var upstreamErr error
 	span = ot.SpanFromContext(ctx)
 	i := 0
-	list := f.List()
+	list := f.ListRequest(state)
+	// hedged holds the proxies that got a hedged request that failed, these are skipped for the rest of the list.
+	var hedged []*proxy.Proxy
 	deadline := time.Now().Add(defaultTimeout)
 	start := time.Now()
 	for time.Now().Before(deadline) && ctx.Err() == nil {
@@ -123,10 +133,14 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 			// reached the end of list, reset to begin
 			i = 0
 			fails = 0
+			hedged = hedged[:0]
 		}
 
 		proxy := list[i]
 		i++
+		if slices.Contains(hedged, proxy) {
+			continue
+		}
 		if proxy.Down(f.maxfails) {
 			fails++
 			if fails < len(f.proxies) {
@@ -144,10 +158,16 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 			proxy = r.List(f.proxies)[0]
 		}
 
+		connCtx := ctx
+		var otelChild oteltrace.Span
 		if span != nil {
 			child = span.Tracer().StartSpan("connect", ot.ChildOf(span.Context()))
 			otext.PeerAddress.Set(child, proxy.Addr())
 			ctx = ot.ContextWithSpan(ctx, child)
+			connCtx = ctx
+		} else {
+			connCtx, otelChild = trace.StartSpan(ctx, "connect", oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+				oteltrace.WithAttributes(attribute.String("peer.address", proxy.Addr())))
 		}
 
 		metadata.SetValueFunc(ctx, "forward/upstream", func() string {
@@ -155,28 +175,30 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 		})
 
 		var (
-			ret *dns.Msg
-			err error
+			ret  *dns.Msg
+			err  error
+			opts = f.opts
 		)
-		opts := f.opts
-
-		for {
-			ret, err = proxy.Connect(ctx, state, opts)
-
-			if err == ErrCachedClosed { // Remote side closed conn, can only happen with TCP.
-				continue
-			}
-			// Retry with TCP if truncated and prefer_udp configured.
-			if ret != nil && ret.Truncated && !opts.ForceTCP && opts.PreferUDP {
-				opts.ForceTCP = true
-				continue
+		if hedge := f.hedgeProxy(list, i, proxy); hedge != nil {
+			var sent bool
+			proxy, ret, opts, sent, err = f.hedgedConnect(connCtx, proxy, hedge, state)
+			if sent && err != nil {
+				hedged = append(hedged, hedge)
 			}
-			break
+		} else {
+			ret, opts, err = f.connect(connCtx, proxy, state)
 		}
 
 		if child != nil {
 			child.Finish()
 		}
+		if otelChild != nil {
+			if err != nil {
+				otelChild.RecordError(err)
+				otelChild.SetStatus(codes.Error, err.Error())
+			}
+			otelChild.End()
+		}
 
 		if len(f.tapPlugins) != 0 {
 			toDnstap(ctx, f, proxy.Addr(), state, opts, ret, start)
@@ -247,6 +269,36 @@ func (f *Forward) isAllowedDomain(name string) bool {
 	return true
 }
 
+// connect sends state to p. It retries when a cached connection was closed and over TCP when the
+// reply was truncated and prefer_udp is set. It returns the options that were used last.
+func (f *Forward) connect(ctx context.Context, p *proxy.Proxy, state request.Request) (*dns.Msg, proxy.Options, error) {
+	var (
+		ret *dns.Msg
+		err error
+	)
+	opts := f.opts
+	start := time.Now()
+
+	for {
+		ret, err = p.Connect(ctx, state, opts)
+
+		if err == ErrCachedClosed { // Remote side closed conn, can only happen with TCP.
+			continue
+		}
+		// Retry with TCP if truncated and prefer_udp configured.
+		if ret != nil && ret.Truncated && !opts.ForceTCP && opts.PreferUDP {
+			opts.ForceTCP = true
+			continue
+		}
+		break
+	}
+
+	if err == nil && f.hedgeRTTs != nil {
+		f.hedgeRTTs.add(time.Since(start))
+	}
+	return ret, opts, err
+}
+
 // ForceTCP returns if TCP is forced to be used even when the request comes in over UDP.
 func (f *Forward) ForceTCP() bool { return f.opts.ForceTCP }
 
@@ -256,6 +308,14 @@ func (f *Forward) PreferUDP() bool { return f.opts.PreferUDP }
 // List returns a set of proxies to be used for this client depending on the policy in f.
 func (f *Forward) List() []*proxy.Proxy { return f.p.List(f.proxies) }
 
+// ListRequest is like List, but lets a RequestPolicy select the proxies based on state.
+func (f *Forward) ListRequest(state request.Request) []*proxy.Proxy {
+	if rp, ok := f.p.(RequestPolicy); ok {
+		return rp.ListRequest(state, f.proxies)
+	}
+	return f.List()
+}
+
 var (
 	// ErrNoHealthy means no healthy proxies left.
 	ErrNoHealthy = errors.New("no healthy proxies")
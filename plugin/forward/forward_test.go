@@ -1,15 +1,23 @@
 package forward
 
 import (
+	"context"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/caddy/caddyfile"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin/dnstap"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
 	"github.com/coredns/coredns/plugin/pkg/proxy"
 	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
 )
 
 func TestList(t *testing.T) {
@@ -39,6 +47,124 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestListLatency(t *testing.T) {
+	// dnstest servers share a handler, the query name selects the delay of the reply.
+	delays := map[string]time.Duration{"slow.": 40 * time.Millisecond, "fast.": 0, "medium.": 20 * time.Millisecond}
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		time.Sleep(delays[r.Question[0].Name])
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	var proxies []*proxy.Proxy
+	for _, qname := range []string{"slow.", "fast.", "medium."} {
+		p := proxy.NewProxy("TestListLatency", s.Addr, transport.DNS)
+		p.Start(5 * time.Second)
+		defer p.Stop()
+		proxies = append(proxies, p)
+
+		m := new(dns.Msg)
+		m.SetQuestion(qname, dns.TypeA)
+		if _, err := p.Connect(context.Background(), request.Request{Req: m, W: &test.ResponseWriter{}}, proxy.Options{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f := Forward{proxies: proxies, p: &latency{}}
+
+	first := 0
+	for range 100 {
+		if f.List()[0] == proxies[1] {
+			first++
+		}
+	}
+	if first < 80 {
+		t.Errorf("Expected the fastest proxy to be first at least %d times, got %d", 80, first)
+	}
+}
+
+func TestListLatencyErrors(t *testing.T) {
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	// Nothing listens on the second address, that upstream only returns errors and has no RTT.
+	var proxies []*proxy.Proxy
+	for _, addr := range []string{s.Addr, "127.0.0.1:1"} {
+		p := proxy.NewProxy("TestListLatencyErrors", addr, transport.DNS)
+		p.Start(5 * time.Second)
+		defer p.Stop()
+		proxies = append(proxies, p)
+	}
+	for i, p := range proxies {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		if _, err := p.Connect(context.Background(), request.Request{Req: m, W: &test.ResponseWriter{}}, proxy.Options{}); (err != nil) != (i == 1) {
+			t.Fatalf("Proxy %d: unexpected error: %v", i, err)
+		}
+	}
+	f := Forward{proxies: proxies, p: &latency{}}
+
+	first := 0
+	for range 100 {
+		if f.List()[0] == proxies[0] {
+			first++
+		}
+	}
+	if first < 80 {
+		t.Errorf("Expected the working proxy to be first at least %d times, got %d", 80, first)
+	}
+}
+
+func TestListHash(t *testing.T) {
+	proxies := []*proxy.Proxy{
+		proxy.NewProxy("TestListHash", "1.1.1.1:53", transport.DNS),
+		proxy.NewProxy("TestListHash", "2.2.2.2:53", transport.DNS),
+		proxy.NewProxy("TestListHash", "3.3.3.3:53", transport.DNS),
+	}
+	f := Forward{proxies: proxies, p: &hash{}}
+
+	state := func(name string) request.Request {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		return request.Request{Req: m, W: &test.ResponseWriter{}}
+	}
+
+	first := f.ListRequest(state("example.org."))
+	if len(first) != len(proxies) {
+		t.Fatalf("Expected: %v results, got: %v", len(proxies), len(first))
+	}
+	for range 10 {
+		got := f.ListRequest(state("ExAmPlE.org."))
+		for i := range got {
+			if got[i] != first[i] {
+				t.Fatalf("Expected proxy %d to be %s, got %s", i, first[i].Addr(), got[i].Addr())
+			}
+		}
+	}
+
+	// Names are spread over all upstreams, and removing one upstream only moves its own names.
+	count := map[*proxy.Proxy]int{}
+	f2 := Forward{proxies: proxies[1:], p: &hash{}}
+	for i := range 300 {
+		name := fmt.Sprintf("%d.example.org.", i)
+		p := f.ListRequest(state(name))[0]
+		count[p]++
+		if p != proxies[0] && f2.ListRequest(state(name))[0] != p {
+			t.Errorf("Expected %s to stay on %s after removing %s", name, p.Addr(), proxies[0].Addr())
+		}
+	}
+	for _, p := range proxies {
+		if count[p] < 50 {
+			t.Errorf("Expected at least %d names on %s, got %d", 50, p.Addr(), count[p])
+		}
+	}
+}
+
 func TestSetTapPlugin(t *testing.T) {
 	input := `forward . 127.0.0.1
 	dnstap /tmp/dnstap.sock full
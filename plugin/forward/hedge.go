@@ -0,0 +1,157 @@
+package forward
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/proxy"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// hedgeProxy returns the proxy to send a hedged request to when p hasn't answered in time. This is
+// the next healthy proxy in list, starting at i. It returns nil when hedging is disabled or when
+// there is no other healthy proxy.
+func (f *Forward) hedgeProxy(list []*proxy.Proxy, i int, p *proxy.Proxy) *proxy.Proxy {
+	if f.hedgeDelay == 0 && f.hedgeRTTs == nil {
+		return nil
+	}
+	for j := range list {
+		h := list[(i+j)%len(list)]
+		if h != p && !h.Down(f.maxfails) {
+			return h
+		}
+	}
+	return nil
+}
+
+// hedgeAfter returns the delay after which a hedged request should be sent. It returns false when
+// the delay is a percentile and not enough RTTs have been observed yet.
+func (f *Forward) hedgeAfter() (time.Duration, bool) {
+	if f.hedgeRTTs != nil {
+		return f.hedgeRTTs.percentile()
+	}
+	return f.hedgeDelay, true
+}
+
+// hedgedConnect sends state to p, and when p doesn't answer within the hedge delay, to hedge as well.
+// The first good reply is returned together with the proxy that sent it, the other request is
+// cancelled. A reply is good when there is no error and the rcode isn't SERVFAIL or REFUSED. When
+// the reply from p isn't good, the hedged request is sent right away. If neither reply is good, a
+// reply is preferred over an error. The returned bool is true when the hedged request was sent.
+func (f *Forward) hedgedConnect(ctx context.Context, p, hedge *proxy.Proxy, state request.Request) (*proxy.Proxy, *dns.Msg, proxy.Options, bool, error) {
+	delay, ok := f.hedgeAfter()
+	if !ok {
+		ret, opts, err := f.connect(ctx, p, state)
+		return p, ret, opts, false, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		p    *proxy.Proxy
+		ret  *dns.Msg
+		opts proxy.Options
+		err  error
+	}
+	results := make(chan result, 2) // buffered, so the loser doesn't block after we've returned
+
+	// Each request gets its own copy of the message, as the loser may still be running when we return.
+	run := func(p *proxy.Proxy) {
+		st := request.Request{W: state.W, Req: state.Req.Copy()}
+		ret, opts, err := f.connect(ctx, p, st)
+		results <- result{p, ret, opts, err}
+	}
+	go run(p)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	hedged := false
+	pending := 1
+	var fallback *result // a reply that wasn't good, returned if nothing better comes along
+	for {
+		select {
+		case <-timer.C:
+			hedged = true
+			pending++
+			hedgedRequestCount.Add(1)
+			go run(hedge)
+
+		case res := <-results:
+			pending--
+			good := res.err == nil && res.ret.Rcode != dns.RcodeServerFailure && res.ret.Rcode != dns.RcodeRefused
+			if good {
+				if hedged {
+					hedgeWinCount.WithLabelValues(res.p.Addr(), strconv.FormatBool(res.p == hedge)).Add(1)
+				}
+				return res.p, res.ret, res.opts, hedged, res.err
+			}
+
+			if res.err == nil {
+				fallback = &res
+			}
+			if pending == 0 && hedged {
+				if res.err != nil && fallback != nil {
+					res = *fallback
+				}
+				return res.p, res.ret, res.opts, hedged, res.err
+			}
+
+			if res.err != nil && f.maxfails != 0 {
+				res.p.Healthcheck()
+			}
+			// The first request failed before the hedge delay, send the hedged request right away.
+			if !hedged {
+				timer.Reset(0)
+			}
+		}
+	}
+}
+
+// rttWindow holds the most recent round trip times to estimate a percentile of them.
+type rttWindow struct {
+	p float64 // percentile, between 0 and 100
+
+	mu     sync.Mutex
+	rtts   [rttWindowSize]time.Duration
+	n      int
+	cached time.Duration
+}
+
+func newRTTWindow(p float64) *rttWindow { return &rttWindow{p: p} }
+
+// add adds d to the window. Every rttWindowRecompute samples the percentile is recomputed.
+func (w *rttWindow) add(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rtts[w.n%rttWindowSize] = d
+	w.n++
+	if w.n%rttWindowRecompute != 0 {
+		return
+	}
+
+	sorted := make([]time.Duration, min(w.n, rttWindowSize))
+	copy(sorted, w.rtts[:len(sorted)])
+	slices.Sort(sorted)
+	i := int(float64(len(sorted)-1) * w.p / 100)
+	w.cached = sorted[i]
+}
+
+// percentile returns the percentile of the RTTs in the window, it returns false when there
+// aren't enough samples yet.
+func (w *rttWindow) percentile() (time.Duration, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.cached, w.n >= rttWindowRecompute
+}
+
+const (
+	rttWindowSize      = 256
+	rttWindowRecompute = 32
+)
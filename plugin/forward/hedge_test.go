@@ -0,0 +1,136 @@
+package forward
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/proxy"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHedge(t *testing.T) {
+	// dnstest servers share a handler, so the local address selects the slow server.
+	var slow atomic.Value
+	slow.Store("")
+	h := func(w dns.ResponseWriter, r *dns.Msg) {
+		if w.LocalAddr().String() == slow.Load().(string) {
+			time.Sleep(time.Second)
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	}
+	s := dnstest.NewServer(h)
+	defer s.Close()
+	s2 := dnstest.NewServer(h)
+	defer s2.Close()
+
+	slow.Store(s.Addr)
+
+	f := New()
+	f.p = &sequential{}
+	f.hedgeDelay = 20 * time.Millisecond
+	f.SetProxy(proxy.NewProxy("TestHedge", s.Addr, transport.DNS))
+	f.SetProxy(proxy.NewProxy("TestHedge", s2.Addr, transport.DNS))
+	defer f.OnShutdown()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+
+	start := time.Now()
+	if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatal("Expected to receive reply, but didn't")
+	}
+	if x := time.Since(start); x > 500*time.Millisecond {
+		t.Errorf("Expected the hedged request to answer within %s, took %s", 500*time.Millisecond, x)
+	}
+	if x := rec.Msg.Answer[0].Header().Name; x != "example.org." {
+		t.Errorf("Expected %s, got %s", "example.org.", x)
+	}
+	if rec.Msg.Id != m.Id {
+		t.Errorf("Expected reply ID %d, got %d", m.Id, rec.Msg.Id)
+	}
+	if x := testutil.ToFloat64(hedgeWinCount.WithLabelValues(s2.Addr, "true")); x != 1 {
+		t.Errorf("Expected %d hedged win for %s, got %f", 1, s2.Addr, x)
+	}
+}
+
+func TestHedgeFailedNotRetried(t *testing.T) {
+	// The first upstream refuses connections, the second never answers and the third does. dnstest
+	// servers share a handler, so the local address selects the server that doesn't answer.
+	var silent atomic.Value
+	silent.Store("")
+	var queries atomic.Int32
+	h := func(w dns.ResponseWriter, r *dns.Msg) {
+		if w.LocalAddr().String() == silent.Load().(string) {
+			if r.Question[0].Name == "example.org." {
+				queries.Add(1)
+			}
+			return
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	}
+	s := dnstest.NewServer(h)
+	defer s.Close()
+	s2 := dnstest.NewServer(h)
+	defer s2.Close()
+
+	silent.Store(s.Addr)
+
+	// Other tests lower the timeout, this needs time for more than one attempt.
+	defer func(d time.Duration) { defaultTimeout = d }(defaultTimeout)
+	defaultTimeout = 5 * time.Second
+
+	f := New()
+	f.p = &sequential{}
+	f.maxfails = 0
+	f.hedgeDelay = 20 * time.Millisecond
+	f.SetProxy(proxy.NewProxy("TestHedgeFailedNotRetried", "127.0.0.1:1", transport.DNS))
+	p := proxy.NewProxy("TestHedgeFailedNotRetried", s.Addr, transport.DNS)
+	p.SetReadTimeout(50 * time.Millisecond)
+	f.SetProxy(p)
+	f.SetProxy(proxy.NewProxy("TestHedgeFailedNotRetried", s2.Addr, transport.DNS))
+	defer f.OnShutdown()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if _, err := f.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m); err != nil {
+		t.Fatalf("Expected to receive reply, got: %s", err)
+	}
+	// The failed hedged request isn't followed by a request to the same upstream.
+	if x := queries.Load(); x != 1 {
+		t.Errorf("Expected %d query to the upstream that doesn't answer, got %d", 1, x)
+	}
+}
+
+func TestRTTWindow(t *testing.T) {
+	w := newRTTWindow(90)
+	for i := range rttWindowRecompute - 1 {
+		w.add(time.Duration(i) * time.Millisecond)
+	}
+	if _, ok := w.percentile(); ok {
+		t.Errorf("Expected no percentile with %d samples", rttWindowRecompute-1)
+	}
+
+	for i := range 2 * rttWindowSize {
+		w.add(time.Duration(i%100) * time.Millisecond)
+	}
+	p, ok := w.percentile()
+	if !ok {
+		t.Fatal("Expected a percentile")
+	}
+	if p < 85*time.Millisecond || p > 95*time.Millisecond {
+		t.Errorf("Expected the 90th percentile to be around %s, got %s", 90*time.Millisecond, p)
+	}
+}
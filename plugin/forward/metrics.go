@@ -22,4 +22,18 @@ var (
 		Name:      "max_concurrent_rejects_total",
 		Help:      "Counter of the number of queries rejected because the concurrent queries were at maximum.",
 	})
+
+	hedgedRequestCount = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "hedged_requests_total",
+		Help:      "Counter of the number of queries for which a hedged request was sent to a second upstream.",
+	})
+
+	hedgeWinCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "hedged_wins_total",
+		Help:      "Counter of the upstreams that answered first when a hedged request was sent.",
+	}, []string{"to", "hedged"})
 )
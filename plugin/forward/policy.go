@@ -1,11 +1,15 @@
 package forward
 
 import (
+	"hash/fnv"
+	"sort"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/plugin/pkg/proxy"
 	"github.com/coredns/coredns/plugin/pkg/rand"
+	"github.com/coredns/coredns/request"
 )
 
 // Policy defines a policy we use for selecting upstreams.
@@ -14,6 +18,12 @@ type Policy interface {
 	String() string
 }
 
+// RequestPolicy is a Policy that selects upstreams based on the request.
+type RequestPolicy interface {
+	Policy
+	ListRequest(request.Request, []*proxy.Proxy) []*proxy.Proxy
+}
+
 // random is a policy that implements random upstream selection.
 type random struct{}
 
@@ -66,4 +76,117 @@ func (r *sequential) List(p []*proxy.Proxy) []*proxy.Proxy {
 	return p
 }
 
+// latency is a policy that prefers the upstreams with the lowest smoothed round trip time, where
+// a penalty for the upstream's error rate is added to the RTT. To keep measuring upstreams that are slower or
+// recovering from errors, a random other upstream is put first for 1 in latencyExplore queries.
+type latency struct{}
+
+func (l *latency) String() string { return "latency" }
+
+func (l *latency) List(p []*proxy.Proxy) []*proxy.Proxy {
+	if len(p) < 2 {
+		return p
+	}
+
+	type scored struct {
+		p     *proxy.Proxy
+		score float64
+	}
+	worst := latencyWorst(p)
+	s := make([]scored, len(p))
+	for i := range p {
+		s[i] = scored{p[i], latencyScore(p[i], worst)}
+	}
+	sort.SliceStable(s, func(i, j int) bool { return s[i].score < s[j].score })
+
+	if rn.Int()%latencyExplore == 0 {
+		i := 1 + rn.Int()%(len(s)-1)
+		s[0], s[i] = s[i], s[0]
+	}
+
+	fast := make([]*proxy.Proxy, len(s))
+	for i := range s {
+		fast[i] = s[i].p
+	}
+	return fast
+}
+
+// latencyScore returns the score of p, lower is better. An upstream without a successful exchange
+// has no RTT, it is scored as worst, the slowest RTT of all upstreams. The error rate adds a penalty
+// relative to worst, so an upstream that fails often scores badly no matter how fast it is.
+func latencyScore(p *proxy.Proxy, worst time.Duration) float64 {
+	rtt := p.SRTT()
+	if rtt == 0 {
+		rtt = worst
+	}
+	return float64(rtt) + float64(worst)*latencyErrPenalty*p.ErrorRate()
+}
+
+// latencyWorst returns the slowest RTT of the upstreams in p, or their longest read timeout when none
+// has been measured yet.
+func latencyWorst(p []*proxy.Proxy) (worst time.Duration) {
+	for i := range p {
+		if rtt := p[i].SRTT(); rtt > worst {
+			worst = rtt
+		}
+	}
+	if worst > 0 {
+		return worst
+	}
+	for i := range p {
+		if t := p[i].ReadTimeout(); t > worst {
+			worst = t
+		}
+	}
+	return worst
+}
+
+const (
+	latencyExplore    = 20 // 1 in latencyExplore queries goes to a random other upstream first.
+	latencyErrPenalty = 10 // An upstream that always fails scores 10 times the slowest RTT worse.
+)
+
+// hash is a policy that uses rendezvous hashing on the (lower cased) query name to select the
+// upstream. The same name is always sent to the same upstream first, when that upstream fails the
+// one with the next highest hash is tried. Adding or removing an upstream only moves the names
+// that hash to that upstream.
+type hash struct{}
+
+func (h *hash) String() string { return "hash" }
+
+// List returns p unchanged, as without a request there is nothing to hash.
+func (h *hash) List(p []*proxy.Proxy) []*proxy.Proxy { return p }
+
+func (h *hash) ListRequest(state request.Request, p []*proxy.Proxy) []*proxy.Proxy {
+	if len(p) < 2 {
+		return p
+	}
+
+	type weighted struct {
+		p      *proxy.Proxy
+		weight uint64
+	}
+	name := state.Name()
+	w := make([]weighted, len(p))
+	for i := range p {
+		w[i] = weighted{p[i], hashWeight(name, p[i].Addr())}
+	}
+	sort.Slice(w, func(i, j int) bool { return w[i].weight > w[j].weight })
+
+	ring := make([]*proxy.Proxy, len(w))
+	for i := range w {
+		ring[i] = w[i].p
+	}
+	return ring
+}
+
+// hashWeight returns the rendezvous weight of upstream addr for name.
+func hashWeight(name, addr string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToLower(name)))
+	h.Write([]byte{0})
+	h.Write([]byte(addr))
+	return h.Sum64()
+}
+
 var rn = rand.New(time.Now().UnixNano())
@@ -125,13 +125,18 @@ func parseStanza(c *caddy.Controller) (*Forward, error) {
 	}
 
 	transports := make([]string, len(toHosts))
-	allowedTrans := map[string]bool{"dns": true, "tls": true}
+	allowedTrans := map[string]bool{"dns": true, "tls": true, "https": true, "quic": true}
 	for i, host := range toHosts {
 		trans, h := parse.Transport(host)
 
 		if !allowedTrans[trans] {
 			return f, fmt.Errorf("'%s' is not supported as a destination protocol in forward: %s", trans, host)
 		}
+		if trans == transport.HTTPS {
+			if err := proxy.CheckDoHURL(h); err != nil {
+				return f, fmt.Errorf("invalid DoH upstream %s: %s", host, err)
+			}
+		}
 		p := proxy.NewProxy("forward", h, trans)
 		f.proxies = append(f.proxies, p)
 		transports[i] = trans
@@ -153,13 +158,13 @@ func parseStanza(c *caddy.Controller) (*Forward, error) {
 
 	for i := range f.proxies {
 		// Only set this for proxies that need it.
-		if transports[i] == transport.TLS {
+		if transports[i] != transport.DNS {
 			f.proxies[i].SetTLSConfig(f.tlsConfig)
 		}
 		f.proxies[i].SetExpire(f.expire)
 		f.proxies[i].GetHealthchecker().SetRecursionDesired(f.opts.HCRecursionDesired)
 		// when TLS is used, checks are set to tcp-tls
-		if f.opts.ForceTCP && transports[i] != transport.TLS {
+		if f.opts.ForceTCP && transports[i] == transport.DNS {
 			f.proxies[i].GetHealthchecker().SetTCPTransport()
 		}
 		f.proxies[i].GetHealthchecker().SetDomain(f.opts.HCDomain)
@@ -274,6 +279,10 @@ func parseBlock(c *caddy.Controller, f *Forward) error {
 			f.p = &roundRobin{}
 		case "sequential":
 			f.p = &sequential{}
+		case "latency":
+			f.p = &latency{}
+		case "hash":
+			f.p = &hash{}
 		default:
 			return c.Errf("unknown policy '%s'", x)
 		}
@@ -306,6 +315,29 @@ func parseBlock(c *caddy.Controller, f *Forward) error {
 
 			f.nextAlternateRcodes = append(f.nextAlternateRcodes, rc)
 		}
+	case "hedge":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		if x := c.Val(); strings.HasPrefix(x, "p") {
+			p, err := strconv.ParseFloat(x[1:], 64)
+			if err != nil {
+				return err
+			}
+			if p <= 0 || p >= 100 {
+				return fmt.Errorf("hedge percentile must be between 0 and 100: %s", x)
+			}
+			f.hedgeRTTs = newRTTWindow(p)
+			return nil
+		}
+		dur, err := time.ParseDuration(c.Val())
+		if err != nil {
+			return err
+		}
+		if dur <= 0 {
+			return fmt.Errorf("hedge delay must be positive: %s", dur)
+		}
+		f.hedgeDelay = dur
 	case "failfast_all_unhealthy_upstreams":
 		args := c.RemainingArgs()
 		if len(args) != 0 {
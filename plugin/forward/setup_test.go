@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
@@ -36,6 +37,10 @@ func TestSetup(t *testing.T) {
 		{"forward . [::1]:53", false, ".", nil, 2, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, ""},
 		{"forward . [2003::1]:53", false, ".", nil, 2, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, ""},
 		{"forward . 127.0.0.1 \n", false, ".", nil, 2, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, ""},
+		{"forward . https://127.0.0.1", false, ".", nil, 2, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, ""},
+		{"forward . https://dns.example.org/dns-query", false, ".", nil, 2, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, ""},
+		{"forward . quic://127.0.0.1", false, ".", nil, 2, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, ""},
+		{"forward . quic://127.0.0.1:8853 tls://127.0.0.1", false, ".", nil, 2, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, ""},
 		{"forward 10.9.3.0/18 127.0.0.1", false, "0.9.10.in-addr.arpa.", nil, 2, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, ""},
 		{`forward . ::1
 		forward com ::2`, false, ".", nil, 2, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, "plugin"},
@@ -43,7 +48,7 @@ func TestSetup(t *testing.T) {
 		{"forward . a27.0.0.1", true, "", nil, 0, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, "not an IP"},
 		{"forward . 127.0.0.1 {\nblaatl\n}\n", true, "", nil, 0, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, "unknown property"},
 		{"forward . 127.0.0.1 {\nhealth_check 0.5s domain\n}\n", true, "", nil, 0, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, "Wrong argument count or unexpected line ending after 'domain'"},
-		{"forward . https://127.0.0.1 \n", true, ".", nil, 2, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, "'https' is not supported as a destination protocol in forward: https://127.0.0.1"},
+		{"forward . grpc://127.0.0.1 \n", true, ".", nil, 2, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, "'grpc' is not supported as a destination protocol in forward: grpc://127.0.0.1"},
 		{"forward xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx 127.0.0.1 \n", true, ".", nil, 2, proxy.Options{HCRecursionDesired: true, HCDomain: "."}, "unable to normalize 'xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx'"},
 	}
 
@@ -103,6 +108,12 @@ func TestSetupTLS(t *testing.T) {
 				tls
 			}`, false, "", ""},
 		{`forward . tls://127.0.0.1`, false, "", ""},
+		{`forward . https://127.0.0.1/dns-query {
+				tls_servername dns
+			}`, false, "dns", ""},
+		{`forward . quic://127.0.0.1 {
+				tls_servername dns
+			}`, false, "dns", ""},
 	}
 
 	for i, test := range tests {
@@ -423,3 +434,48 @@ func TestFailfastAllUnhealthyUpstreams(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupHedge(t *testing.T) {
+	tests := []struct {
+		input              string
+		shouldErr          bool
+		expectedDelay      time.Duration
+		expectedPercentile float64
+	}{
+		// positive
+		{"forward . 127.0.0.1 127.0.0.2\n", false, 0, 0},
+		{"forward . 127.0.0.1 127.0.0.2 {\nhedge 50ms\n}\n", false, 50 * time.Millisecond, 0},
+		{"forward . 127.0.0.1 127.0.0.2 {\nhedge p95\n}\n", false, 0, 95},
+		{"forward . 127.0.0.1 127.0.0.2 {\nhedge p99.9\n}\n", false, 0, 99.9},
+		// negative
+		{"forward . 127.0.0.1 {\nhedge\n}\n", true, 0, 0},
+		{"forward . 127.0.0.1 {\nhedge 0s\n}\n", true, 0, 0},
+		{"forward . 127.0.0.1 {\nhedge p100\n}\n", true, 0, 0},
+		{"forward . 127.0.0.1 {\nhedge pfast\n}\n", true, 0, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		fs, err := parseForward(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+		}
+
+		f := fs[0]
+		if f.hedgeDelay != test.expectedDelay {
+			t.Errorf("Test %d: expected hedge delay %s, got %s", i, test.expectedDelay, f.hedgeDelay)
+		}
+		if test.expectedPercentile == 0 && f.hedgeRTTs != nil {
+			t.Errorf("Test %d: expected no hedge percentile, got %f", i, f.hedgeRTTs.p)
+		}
+		if test.expectedPercentile != 0 && (f.hedgeRTTs == nil || f.hedgeRTTs.p != test.expectedPercentile) {
+			t.Errorf("Test %d: expected hedge percentile %f", i, test.expectedPercentile)
+		}
+	}
+}
@@ -8,10 +8,13 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/debug"
+	"github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
 	ot "github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 // GRPC represents a plugin instance that can proxy requests to another (DNS) server via gRPC protocol.
@@ -59,12 +62,25 @@ func (g *GRPC) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 		proxy := list[i]
 		i++
 
+		queryCtx := ctx
+		var otelChild oteltrace.Span
 		if span != nil {
 			child = span.Tracer().StartSpan("query", ot.ChildOf(span.Context()))
 			ctx = ot.ContextWithSpan(ctx, child)
+			queryCtx = ctx
+		} else if queryCtx, otelChild = trace.StartSpan(ctx, "query", oteltrace.WithSpanKind(oteltrace.SpanKindClient)); otelChild != nil {
+			// Propagate the trace context to the upstream.
+			queryCtx = trace.OutgoingContext(queryCtx)
 		}
 
-		ret, err = proxy.query(ctx, r)
+		ret, err = proxy.query(queryCtx, r)
+		if otelChild != nil {
+			if err != nil {
+				otelChild.RecordError(err)
+				otelChild.SetStatus(codes.Error, err.Error())
+			}
+			otelChild.End()
+		}
 		if err != nil {
 			// Continue with the next proxy
 			continue
@@ -35,6 +35,16 @@ type Externaler interface {
 	ExternalSerial(string) uint32
 }
 
+// Hostnamer defines the interface that an Externaler should implement to also serve the hostnames of
+// Ingresses and Gateway API routes.
+type Hostnamer interface {
+	// WatchHostnames starts watching the Ingresses and/or the Gateway API Gateways and HTTPRoutes.
+	WatchHostnames(ingress, gateway bool) error
+	// ExternalHostname returns a slice of msg.Services with the addresses of the objects with the
+	// queried hostname.
+	ExternalHostname(request.Request) ([]msg.Service, int)
+}
+
 // External serves records for External IPs and Loadbalance IPs of Services in Kubernetes clusters.
 type External struct {
 	Next  plugin.Handler
@@ -45,6 +55,8 @@ type External struct {
 	apex       string
 	ttl        uint32
 	headless   bool
+	ingress    bool
+	gateway    bool
 
 	upstream *upstream.Upstream
 
@@ -52,6 +64,7 @@ type External struct {
 	externalAddrFunc     func(request.Request, bool) []dns.RR
 	externalSerialFunc   func(string) uint32
 	externalServicesFunc func(string, bool) ([]msg.Service, map[string][]msg.Service)
+	externalHostnameFunc func(request.Request) ([]msg.Service, int)
 }
 
 // New returns a new and initialized *External.
@@ -85,6 +98,14 @@ func (e *External) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Ms
 
 	svc, rcode := e.externalFunc(state, e.headless)
 
+	// Names that aren't services may be the hostname of an Ingress or Gateway API route.
+	hostname := false
+	if len(svc) == 0 && e.externalHostnameFunc != nil {
+		if hsvc, _ := e.externalHostnameFunc(state); len(hsvc) > 0 {
+			svc, rcode, hostname = hsvc, dns.RcodeSuccess, true
+		}
+	}
+
 	m := new(dns.Msg)
 	m.SetReply(state.Req)
 	m.Authoritative = true
@@ -106,9 +127,13 @@ func (e *External) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Ms
 	case dns.TypeAAAA:
 		m.Answer, m.Truncated = e.aaaa(ctx, svc, state)
 	case dns.TypeSRV:
-		m.Answer, m.Extra = e.srv(ctx, svc, state)
+		if !hostname {
+			m.Answer, m.Extra = e.srv(ctx, svc, state)
+		}
 	case dns.TypePTR:
-		m.Answer = e.ptr(svc, state)
+		if !hostname {
+			m.Answer = e.ptr(svc, state)
+		}
 	default:
 		m.Ns = []dns.RR{e.soa(state)}
 	}
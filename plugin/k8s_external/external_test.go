@@ -55,6 +55,60 @@ func TestExternal(t *testing.T) {
 	}
 }
 
+func TestExternalHostname(t *testing.T) {
+	k := kubernetes.New([]string{"cluster.local."})
+	k.Namespaces = map[string]struct{}{"testns": {}}
+	k.APIConn = &external{}
+
+	e := New()
+	e.Zones = []string{"example.com."}
+	e.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	e.externalFunc = k.External
+	e.externalAddrFunc = externalAddress  // internal test function
+	e.externalSerialFunc = externalSerial // internal test function
+	e.externalHostnameFunc = k.ExternalHostname
+
+	tests := []test.Case{
+		{
+			Qname: "www.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{
+				test.A("www.example.com.	5	IN	A	1.2.3.10"),
+			},
+		},
+		// Hostnames don't have SRV records.
+		{
+			Qname: "www.example.com.", Qtype: dns.TypeSRV, Rcode: dns.RcodeSuccess,
+			Ns: []dns.RR{
+				test.SOA("example.com.	5	IN	SOA	ns1.dns.example.com. hostmaster.example.com. 1499347823 7200 1800 86400 5"),
+			},
+		},
+		{
+			Qname: "ftp.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError,
+			Ns: []dns.RR{
+				test.SOA("example.com.	5	IN	SOA	ns1.dns.example.com. hostmaster.example.com. 1499347823 7200 1800 86400 5"),
+			},
+		},
+		// Services still take precedence.
+		{
+			Qname: "svc1.testns.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{
+				test.A("svc1.testns.example.com.	5	IN	A	1.2.3.4"),
+			},
+		},
+	}
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := e.ServeDNS(ctx, w, tc.Msg()); err != nil {
+			t.Fatalf("Test %d: expected no error, got %v", i, err)
+		}
+		if err := test.SortAndCheck(w.Msg, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+}
+
 var tests = []test.Case{
 	// PTR reverse lookup
 	{
@@ -328,6 +382,16 @@ func (external) GetNamespaceByName(name string) (*object.Namespace, error) {
 	}, nil
 }
 
+func (external) NodeZone(string) string { return "" }
+func (external) HostnameIndex(host string) []kubernetes.HostAddresses {
+	if host != "www.example.com" {
+		return nil
+	}
+	return []kubernetes.HostAddresses{{Namespace: "testns", Addresses: []string{"1.2.3.10"}}}
+}
+
+func (external) WatchHostnames(context.Context, bool, bool) error { return nil }
+
 var epIndexExternal = map[string][]*object.Endpoints{
 	"svc-headless.testns": {
 		{
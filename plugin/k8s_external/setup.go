@@ -36,6 +36,17 @@ func setup(c *caddy.Controller) error {
 		e.externalAddrFunc = x.ExternalAddress
 		e.externalServicesFunc = x.ExternalServices
 		e.externalSerialFunc = x.ExternalSerial
+
+		if e.ingress || e.gateway {
+			h, ok := m.(Hostnamer)
+			if !ok {
+				return plugin.Error(pluginName, errors.New("kubernetes plugin does not implement the Hostnamer interface"))
+			}
+			if err := h.WatchHostnames(e.ingress, e.gateway); err != nil {
+				return plugin.Error(pluginName, err)
+			}
+			e.externalHostnameFunc = h.ExternalHostname
+		}
 		return nil
 	})
 
@@ -77,6 +88,16 @@ func parse(c *caddy.Controller) (*External, error) {
 				e.apex = args[0]
 			case "headless":
 				e.headless = true
+			case "ingress":
+				if len(c.RemainingArgs()) != 0 {
+					return nil, c.ArgErr()
+				}
+				e.ingress = true
+			case "gateway":
+				if len(c.RemainingArgs()) != 0 {
+					return nil, c.ArgErr()
+				}
+				e.gateway = true
 			case "fallthrough":
 				e.Fall.SetZonesFromArgs(c.RemainingArgs())
 			default:
@@ -69,3 +69,45 @@ func TestSetup(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupHostnames(t *testing.T) {
+	tests := []struct {
+		input           string
+		shouldErr       bool
+		expectedIngress bool
+		expectedGateway bool
+	}{
+		{`k8s_external example.org`, false, false, false},
+		{`k8s_external example.org {
+	ingress
+}`, false, true, false},
+		{`k8s_external example.org {
+	ingress
+	gateway
+}`, false, true, true},
+		{`k8s_external example.org {
+	gateway extra
+}`, true, false, false},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		e, err := parse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error but found one for input %s. Error was: %v", i, test.input, err)
+			continue
+		}
+		if e.ingress != test.expectedIngress {
+			t.Errorf("Test %d, expected ingress %v for input %s, got: %v", i, test.expectedIngress, test.input, e.ingress)
+		}
+		if e.gateway != test.expectedGateway {
+			t.Errorf("Test %d, expected gateway %v for input %s, got: %v", i, test.expectedGateway, test.input, e.gateway)
+		}
+	}
+}
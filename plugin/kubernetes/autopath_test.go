@@ -44,10 +44,14 @@ func (m *mockAPIConnector) GetNodeByName(ctx context.Context, name string) (*api
 func (m *mockAPIConnector) GetNamespaceByName(name string) (*object.Namespace, error) {
 	return nil, nil
 }
-func (m *mockAPIConnector) Run()                        {}
-func (m *mockAPIConnector) HasSynced() bool             { return true }
-func (m *mockAPIConnector) Stop() error                 { return nil }
-func (m *mockAPIConnector) Modified(ModifiedMode) int64 { return 0 }
+
+func (m *mockAPIConnector) NodeZone(string) string                           { return "" }
+func (m *mockAPIConnector) HostnameIndex(string) []HostAddresses             { return nil }
+func (m *mockAPIConnector) WatchHostnames(context.Context, bool, bool) error { return nil }
+func (m *mockAPIConnector) Run()                                             {}
+func (m *mockAPIConnector) HasSynced() bool                                  { return true }
+func (m *mockAPIConnector) Stop() error                                      { return nil }
+func (m *mockAPIConnector) Modified(ModifiedMode) int64                      { return 0 }
 
 func BenchmarkAutoPath(b *testing.B) {
 	k := &Kubernetes{
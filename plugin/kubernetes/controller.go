@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,6 +18,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
@@ -57,6 +59,12 @@ type dnsController interface {
 
 	GetNodeByName(context.Context, string) (*api.Node, error)
 	GetNamespaceByName(string) (*object.Namespace, error)
+	// NodeZone returns the topology zone of the node, or "" when it isn't known.
+	NodeZone(string) string
+	// HostnameIndex returns the addresses of the Ingresses and Gateway API objects with the hostname.
+	HostnameIndex(string) []HostAddresses
+	// WatchHostnames starts watching the Ingresses and/or the Gateway API objects.
+	WatchHostnames(context.Context, bool, bool) error
 
 	Run()
 	HasSynced() bool
@@ -80,6 +88,7 @@ type dnsControl struct {
 
 	client    kubernetes.Interface
 	mcsClient mcsClientset.MulticlusterV1alpha1Interface
+	dynClient dynamic.Interface
 
 	selector          labels.Selector
 	namespaceSelector labels.Selector
@@ -88,6 +97,7 @@ type dnsControl struct {
 	podController       cache.Controller
 	epController        cache.Controller
 	nsController        cache.Controller
+	nodeController      cache.Controller
 	svcImportController cache.Controller
 	mcEpController      cache.Controller
 
@@ -95,9 +105,22 @@ type dnsControl struct {
 	podLister       cache.Indexer
 	epLister        cache.Indexer
 	nsLister        cache.Store
+	nodeLister      cache.Store
 	svcImportLister cache.Indexer
 	mcEpLister      cache.Indexer
 
+	// The watches on the objects with hostnames are started on request of the external plugin, which
+	// can happen before or after Run. They are not part of HasSynced, because the Ingress or Gateway API
+	// resources might not exist in the cluster; their hostnames are only served once they have synced.
+	hostLock        sync.Mutex
+	running         bool
+	ingController   cache.Controller
+	gwController    cache.Controller
+	routeController cache.Controller
+	ingLister       cache.Indexer
+	gwLister        cache.Indexer
+	routeLister     cache.Indexer
+
 	// stopLock is used to enforce only a single call to Stop is active.
 	// Needed because we allow stopping through an http endpoint and
 	// allowing concurrent stoppers leads to stack traces.
@@ -108,12 +131,22 @@ type dnsControl struct {
 	zones             []string
 	endpointNameMode  bool
 	multiclusterZones []string
+	annotations       bool
+
+	// publisher writes the watch cache file, when enabled.
+	publisher *cachePublisher
 }
 
 type dnsControlOpts struct {
 	initPodCache       bool
 	initEndpointsCache bool
 	ignoreEmptyService bool
+	// topology enables the node watch needed for topology aware answers.
+	topology bool
+	// annotations enables the DNS policy set with annotations on namespaces and services.
+	annotations bool
+	// watchCache configures the watch cache file this controller publishes.
+	watchCache watchCacheOpts
 
 	// Label handling.
 	labelSelector          *meta.LabelSelector
@@ -137,6 +170,10 @@ func newdnsController(ctx context.Context, kubeClient kubernetes.Interface, mcsC
 		zones:             opts.zones,
 		endpointNameMode:  opts.endpointNameMode,
 		multiclusterZones: opts.multiclusterZones,
+		annotations:       opts.annotations,
+	}
+	if opts.watchCache.mode == watchCachePublish {
+		dns.publisher = &cachePublisher{dns: &dns, path: opts.watchCache.path, interval: opts.watchCache.interval}
 	}
 
 	dns.svcLister, dns.svcController = object.NewIndexerInformer(
@@ -180,17 +217,35 @@ func newdnsController(ctx context.Context, kubeClient kubernetes.Interface, mcsC
 		dns.epController = epController
 	}
 
+	// Namespace updates only change the records through their policy.
+	nsHandler := cache.ResourceEventHandlerFuncs{}
+	if opts.annotations {
+		nsHandler.UpdateFunc = dns.Update
+	}
 	dns.nsLister, dns.nsController = object.NewIndexerInformer(
 		&cache.ListWatch{
 			ListFunc:  namespaceListFunc(ctx, dns.client, dns.namespaceSelector),
 			WatchFunc: namespaceWatchFunc(ctx, dns.client, dns.namespaceSelector),
 		},
 		&api.Namespace{},
-		cache.ResourceEventHandlerFuncs{},
+		nsHandler,
 		cache.Indexers{},
 		object.DefaultProcessor(object.ToNamespace, nil),
 	)
 
+	if opts.topology {
+		dns.nodeLister, dns.nodeController = object.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc:  nodeListFunc(ctx, dns.client),
+				WatchFunc: nodeWatchFunc(ctx, dns.client),
+			},
+			&api.Node{},
+			cache.ResourceEventHandlerFuncs{},
+			cache.Indexers{},
+			object.DefaultProcessor(object.ToNode, nil),
+		)
+	}
+
 	if len(opts.multiclusterZones) > 0 {
 		mcsEpReq, _ := labels.NewRequirement(mcs.LabelServiceName, selection.Exists, []string{})
 		mcsEpSelector := dns.selector
@@ -347,6 +402,12 @@ func namespaceListFunc(ctx context.Context, c kubernetes.Interface, s labels.Sel
 	}
 }
 
+func nodeListFunc(ctx context.Context, c kubernetes.Interface) func(meta.ListOptions) (runtime.Object, error) {
+	return func(opts meta.ListOptions) (runtime.Object, error) {
+		return c.CoreV1().Nodes().List(ctx, opts)
+	}
+}
+
 func serviceImportListFunc(ctx context.Context, c mcsClientset.MulticlusterV1alpha1Interface, ns string, s labels.Selector) func(meta.ListOptions) (runtime.Object, error) {
 	return func(opts meta.ListOptions) (runtime.Object, error) {
 		if s != nil {
@@ -396,6 +457,12 @@ func namespaceWatchFunc(ctx context.Context, c kubernetes.Interface, s labels.Se
 	}
 }
 
+func nodeWatchFunc(ctx context.Context, c kubernetes.Interface) func(options meta.ListOptions) (watch.Interface, error) {
+	return func(options meta.ListOptions) (watch.Interface, error) {
+		return c.CoreV1().Nodes().Watch(ctx, options)
+	}
+}
+
 func serviceImportWatchFunc(ctx context.Context, c mcsClientset.MulticlusterV1alpha1Interface, ns string, s labels.Selector) func(options meta.ListOptions) (watch.Interface, error) {
 	return func(options meta.ListOptions) (watch.Interface, error) {
 		if s != nil {
@@ -433,12 +500,26 @@ func (dns *dnsControl) Run() {
 		go dns.podController.Run(dns.stopCh)
 	}
 	go dns.nsController.Run(dns.stopCh)
+	if dns.nodeController != nil {
+		go dns.nodeController.Run(dns.stopCh)
+	}
 	if dns.svcImportController != nil {
 		go dns.svcImportController.Run(dns.stopCh)
 	}
 	if dns.mcEpController != nil {
 		go dns.mcEpController.Run(dns.stopCh)
 	}
+	if dns.publisher != nil {
+		go dns.publisher.run(dns.stopCh)
+	}
+	dns.hostLock.Lock()
+	dns.running = true
+	for _, ctrl := range []cache.Controller{dns.ingController, dns.gwController, dns.routeController} {
+		if ctrl != nil {
+			go ctrl.Run(dns.stopCh)
+		}
+	}
+	dns.hostLock.Unlock()
 	<-dns.stopCh
 }
 
@@ -462,7 +543,11 @@ func (dns *dnsControl) HasSynced() bool {
 	if dns.mcEpController != nil {
 		f = dns.mcEpController.HasSynced()
 	}
-	return a && b && c && d && e && f
+	g := true
+	if dns.nodeController != nil {
+		g = dns.nodeController.HasSynced()
+	}
+	return a && b && c && d && e && f && g
 }
 
 func (dns *dnsControl) ServiceList() (svcs []*object.Service) {
@@ -647,6 +732,23 @@ func (dns *dnsControl) GetNamespaceByName(name string) (*object.Namespace, error
 	return ns, nil
 }
 
+// NodeZone returns the topology zone of the node with the given name. It returns "" when the node is
+// not known or when the nodes aren't watched.
+func (dns *dnsControl) NodeZone(name string) string {
+	if dns.nodeLister == nil {
+		return ""
+	}
+	o, exists, err := dns.nodeLister.GetByKey(name)
+	if err != nil || !exists {
+		return ""
+	}
+	n, ok := o.(*object.Node)
+	if !ok {
+		return ""
+	}
+	return n.Zone
+}
+
 func (dns *dnsControl) Add(obj interface{})               { dns.updateModified() }
 func (dns *dnsControl) Delete(obj interface{})            { dns.updateModified() }
 func (dns *dnsControl) Update(oldObj, newObj interface{}) { dns.detectChanges(oldObj, newObj) }
@@ -663,7 +765,7 @@ func (dns *dnsControl) detectChanges(oldObj, newObj interface{}) {
 	}
 	switch ob := obj.(type) {
 	case *object.Service:
-		imod, emod := serviceModified(oldObj, newObj)
+		imod, emod := serviceModified(oldObj, newObj, dns.annotations)
 		if imod {
 			dns.updateModified()
 		}
@@ -676,6 +778,11 @@ func (dns *dnsControl) detectChanges(oldObj, newObj interface{}) {
 		}
 	case *object.Pod:
 		dns.updateModified()
+	case *object.Namespace:
+		// Only the policy of a namespace changes the records.
+		if oldObj == nil || newObj == nil || !oldObj.(*object.Namespace).Policy.Equal(newObj.(*object.Namespace).Policy) {
+			dns.updateModified()
+		}
 	case *object.Endpoints:
 		if !endpointsEquivalent(oldObj.(*object.Endpoints), newObj.(*object.Endpoints)) {
 			dns.updateModified()
@@ -711,6 +818,9 @@ func subsetsEquivalent(sa, sb object.EndpointSubset) bool {
 		if aaddr.Hostname != baddr.Hostname {
 			return false
 		}
+		if aaddr.Zone != baddr.Zone || !slices.Equal(aaddr.ForZones, baddr.ForZones) {
+			return false
+		}
 	}
 
 	for port, aport := range sa.Ports {
@@ -770,8 +880,9 @@ func multiclusterEndpointsEquivalent(a, b *object.MultiClusterEndpoints) bool {
 
 // serviceModified checks the services passed for changes that result in changes
 // to internal and or external records.  It returns two booleans, one for internal
-// record changes, and a second for external record changes
-func serviceModified(oldObj, newObj interface{}) (intSvc, extSvc bool) {
+// record changes, and a second for external record changes. The policy of the services is only
+// compared when annotations is true.
+func serviceModified(oldObj, newObj interface{}, annotations bool) (intSvc, extSvc bool) {
 	if oldObj != nil && newObj == nil {
 		// deleted service only modifies external zone records if it had external ips
 		return true, len(oldObj.(*object.Service).ExternalIPs) > 0
@@ -800,6 +911,11 @@ func serviceModified(oldObj, newObj interface{}) (intSvc, extSvc bool) {
 	// ExternalName is mutable, affecting internal zone records
 	intSvc = oldSvc.ExternalName != newSvc.ExternalName
 
+	// The policy changes the records of both zones
+	if annotations && !oldSvc.Policy.Equal(newSvc.Policy) {
+		return true, true
+	}
+
 	if intSvc && extSvc {
 		return intSvc, extSvc
 	}
@@ -294,10 +294,11 @@ func createMultiClusterHeadlessSvc(suffix int, mcsClient mcsClientset.Multiclust
 
 func TestServiceModified(t *testing.T) {
 	tests := []struct {
-		oldSvc   interface{}
-		newSvc   interface{}
-		ichanged bool
-		echanged bool
+		oldSvc      interface{}
+		newSvc      interface{}
+		annotations bool
+		ichanged    bool
+		echanged    bool
 	}{
 		{
 			oldSvc:   nil,
@@ -329,6 +330,19 @@ func TestServiceModified(t *testing.T) {
 			ichanged: false,
 			echanged: true,
 		},
+		{
+			oldSvc:      &object.Service{},
+			newSvc:      &object.Service{Policy: &object.Policy{TTL: ttl(30)}},
+			annotations: true,
+			ichanged:    true,
+			echanged:    true,
+		},
+		{
+			oldSvc:   &object.Service{},
+			newSvc:   &object.Service{Policy: &object.Policy{TTL: ttl(30)}},
+			ichanged: false,
+			echanged: false,
+		},
 		{
 			oldSvc:   &object.Service{ExternalName: "10.0.0.1"},
 			newSvc:   &object.Service{ExternalName: "10.0.0.2"},
@@ -350,7 +364,7 @@ func TestServiceModified(t *testing.T) {
 	}
 
 	for i, test := range tests {
-		ichanged, echanged := serviceModified(test.oldSvc, test.newSvc)
+		ichanged, echanged := serviceModified(test.oldSvc, test.newSvc, test.annotations)
 		if test.ichanged != ichanged || test.echanged != echanged {
 			t.Errorf("Expected %v, %v for test %v. Got %v, %v", test.ichanged, test.echanged, i, ichanged, echanged)
 		}
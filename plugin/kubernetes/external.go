@@ -96,6 +96,9 @@ func (k *Kubernetes) External(state request.Request, headless bool) ([]msg.Servi
 		if service != svc.Name {
 			continue
 		}
+		if k.hidden(svc) {
+			continue
+		}
 
 		if headless && len(svc.ExternalIPs) == 0 && (svc.Headless() || endpoint != "") {
 			if endpointsList == nil {
@@ -163,6 +166,9 @@ func (k *Kubernetes) ExternalServices(zone string, headless bool) (services []ms
 	zonePath := msg.Path(zone, coredns)
 	headlessServices = make(map[string][]msg.Service)
 	for _, svc := range k.APIConn.ServiceList() {
+		if k.hidden(svc) {
+			continue
+		}
 		// Endpoints and headless services
 		if headless && len(svc.ExternalIPs) == 0 && svc.Headless() {
 			idx := object.ServiceKey(svc.Name, svc.Namespace)
@@ -229,6 +235,9 @@ func (k *Kubernetes) serviceRecordForExternalIP(ip string) []msg.Service {
 		if len(k.Namespaces) > 0 && !k.namespaceExposed(service.Namespace) {
 			continue
 		}
+		if k.hidden(service) {
+			continue
+		}
 		domain := strings.Join([]string{service.Name, service.Namespace}, ".")
 		svcs = append(svcs, msg.Service{Host: domain, TTL: k.ttl})
 	}
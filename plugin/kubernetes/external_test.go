@@ -121,6 +121,10 @@ func (external) GetNamespaceByName(name string) (*object.Namespace, error) {
 	}, nil
 }
 
+func (external) NodeZone(string) string                           { return "" }
+func (external) HostnameIndex(string) []HostAddresses             { return nil }
+func (external) WatchHostnames(context.Context, bool, bool) error { return nil }
+
 var epIndexExternal = map[string][]*object.Endpoints{
 	"svc-headless.testns": {
 		{
@@ -1153,6 +1153,10 @@ func (APIConnServeTest) GetNamespaceByName(name string) (*object.Namespace, erro
 	}, nil
 }
 
+func (APIConnServeTest) NodeZone(string) string                           { return "" }
+func (APIConnServeTest) HostnameIndex(string) []HostAddresses             { return nil }
+func (APIConnServeTest) WatchHostnames(context.Context, bool, bool) error { return nil }
+
 // Upstub implements an Upstreamer that returns a set response for test purposes
 type Upstub struct {
 	test.Case
@@ -0,0 +1,265 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	networking "k8s.io/api/networking/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const hostnameIndex = "Hostname"
+
+var errNoDynamicClient = errors.New("no client for the Gateway API")
+
+// HostAddresses are the addresses a hostname of an object in Namespace resolves to.
+type HostAddresses struct {
+	Namespace string
+	Addresses []string
+	// Ingress is true for the addresses of an Ingress, its wildcard hostnames only cover a single
+	// label. Wildcards of the Gateway API are suffix matches.
+	Ingress bool
+}
+
+// WatchHostnames implements the WatchHostnames call from the external plugin. It starts watching
+// Ingresses and/or the Gateways and HTTPRoutes of the Gateway API, so their hostnames can be resolved.
+func (k *Kubernetes) WatchHostnames(ingress, gateway bool) error {
+	return k.APIConn.WatchHostnames(context.Background(), ingress, gateway)
+}
+
+// ExternalHostname implements the ExternalHostname call from the external plugin. It returns the
+// status addresses of the Ingresses, Gateways and HTTPRoutes with the queried hostname. When there is
+// no object with exactly that hostname, the wildcard hostnames for the parent domains are tried. An
+// Ingress wildcard only matches a single label, so only the immediate parent is tried for those.
+func (k *Kubernetes) ExternalHostname(state request.Request) ([]msg.Service, int) {
+	name := strings.TrimSuffix(state.Name(), ".")
+	segs := dns.SplitDomainName(name)
+	for i := range segs {
+		host := name
+		if i > 0 {
+			host = "*." + strings.Join(segs[i:], ".")
+		}
+		var services []msg.Service
+		for _, h := range k.APIConn.HostnameIndex(host) {
+			if !k.namespaceExposed(h.Namespace) || (h.Ingress && i > 1) {
+				continue
+			}
+			for _, a := range h.Addresses {
+				services = append(services, msg.Service{Host: a, TTL: k.ttl, Key: msg.Path(state.Name(), coredns)})
+			}
+		}
+		if len(services) > 0 {
+			return services, dns.RcodeSuccess
+		}
+	}
+	return nil, dns.RcodeNameError
+}
+
+// WatchHostnames starts the watches on the objects with hostnames, if they aren't running yet.
+func (dns *dnsControl) WatchHostnames(ctx context.Context, ingress, gateway bool) error {
+	dns.hostLock.Lock()
+	defer dns.hostLock.Unlock()
+
+	if ingress && dns.ingLister == nil {
+		dns.ingLister, dns.ingController = object.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc:  ingressListFunc(ctx, dns.client, dns.selector),
+				WatchFunc: ingressWatchFunc(ctx, dns.client, dns.selector),
+			},
+			&networking.Ingress{},
+			cache.ResourceEventHandlerFuncs{},
+			cache.Indexers{hostnameIndex: ingressHostnameIndexFunc},
+			object.DefaultProcessor(object.ToIngress, nil),
+		)
+		dns.startHostController(dns.ingController)
+	}
+
+	if gateway && dns.gwLister == nil {
+		if dns.dynClient == nil {
+			return errNoDynamicClient
+		}
+		dns.gwLister, dns.gwController = object.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc:  dynamicListFunc(ctx, dns.dynClient, object.GatewayResource, dns.selector),
+				WatchFunc: dynamicWatchFunc(ctx, dns.dynClient, object.GatewayResource, dns.selector),
+			},
+			&unstructured.Unstructured{},
+			cache.ResourceEventHandlerFuncs{},
+			cache.Indexers{hostnameIndex: gatewayHostnameIndexFunc},
+			object.DefaultProcessor(object.ToGateway, nil),
+		)
+		dns.startHostController(dns.gwController)
+
+		dns.routeLister, dns.routeController = object.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc:  dynamicListFunc(ctx, dns.dynClient, object.HTTPRouteResource, dns.selector),
+				WatchFunc: dynamicWatchFunc(ctx, dns.dynClient, object.HTTPRouteResource, dns.selector),
+			},
+			&unstructured.Unstructured{},
+			cache.ResourceEventHandlerFuncs{},
+			cache.Indexers{hostnameIndex: routeHostnameIndexFunc},
+			object.DefaultProcessor(object.ToHTTPRoute, nil),
+		)
+		dns.startHostController(dns.routeController)
+	}
+	return nil
+}
+
+// startHostController runs ctrl when dns is already running, otherwise Run will start it.
+// The caller must hold hostLock.
+func (dns *dnsControl) startHostController(ctrl cache.Controller) {
+	if dns.running {
+		go ctrl.Run(dns.stopCh)
+	}
+}
+
+// HostnameIndex returns the addresses for host, from the Ingresses and Gateways with that hostname
+// and from the Gateways of the HTTPRoutes with that hostname. Objects whose watches haven't synced
+// yet are left out.
+func (dns *dnsControl) HostnameIndex(host string) (hosts []HostAddresses) {
+	ingLister, gwLister, routeLister := dns.hostnameListers()
+
+	if ingLister != nil {
+		os, _ := ingLister.ByIndex(hostnameIndex, host)
+		for _, o := range os {
+			if ing, ok := o.(*object.Ingress); ok && len(ing.Addresses) > 0 {
+				hosts = append(hosts, HostAddresses{Namespace: ing.Namespace, Addresses: ing.Addresses, Ingress: true})
+			}
+		}
+	}
+	if gwLister == nil {
+		return hosts
+	}
+	os, _ := gwLister.ByIndex(hostnameIndex, host)
+	for _, o := range os {
+		if gw, ok := o.(*object.Gateway); ok && len(gw.Addresses) > 0 {
+			hosts = append(hosts, HostAddresses{Namespace: gw.Namespace, Addresses: gw.Addresses})
+		}
+	}
+	os, _ = routeLister.ByIndex(hostnameIndex, host)
+	for _, o := range os {
+		route, ok := o.(*object.HTTPRoute)
+		if !ok {
+			continue
+		}
+		for _, key := range route.Gateways {
+			o, exists, err := gwLister.GetByKey(key)
+			if err != nil || !exists {
+				continue
+			}
+			if gw, ok := o.(*object.Gateway); ok && len(gw.Addresses) > 0 {
+				hosts = append(hosts, HostAddresses{Namespace: route.Namespace, Addresses: gw.Addresses})
+			}
+		}
+	}
+	return hosts
+}
+
+// hostnameListers returns the listers of the objects with hostnames, a lister is nil when its watch
+// isn't running or hasn't synced yet.
+func (dns *dnsControl) hostnameListers() (ingLister, gwLister, routeLister cache.Indexer) {
+	dns.hostLock.Lock()
+	defer dns.hostLock.Unlock()
+	if dns.ingController != nil && dns.ingController.HasSynced() {
+		ingLister = dns.ingLister
+	}
+	// The HTTPRoutes are resolved through their Gateways, both are needed.
+	if dns.gwController != nil && dns.gwController.HasSynced() && dns.routeController.HasSynced() {
+		gwLister, routeLister = dns.gwLister, dns.routeLister
+	}
+	return ingLister, gwLister, routeLister
+}
+
+func ingressHostnameIndexFunc(obj interface{}) ([]string, error) {
+	ing, ok := obj.(*object.Ingress)
+	if !ok {
+		return nil, errObj
+	}
+	return ing.Hostnames, nil
+}
+
+func gatewayHostnameIndexFunc(obj interface{}) ([]string, error) {
+	gw, ok := obj.(*object.Gateway)
+	if !ok {
+		return nil, errObj
+	}
+	return gw.Hostnames, nil
+}
+
+func routeHostnameIndexFunc(obj interface{}) ([]string, error) {
+	route, ok := obj.(*object.HTTPRoute)
+	if !ok {
+		return nil, errObj
+	}
+	return route.Hostnames, nil
+}
+
+func ingressListFunc(ctx context.Context, c kubernetes.Interface, s labels.Selector) func(meta.ListOptions) (runtime.Object, error) {
+	return func(opts meta.ListOptions) (runtime.Object, error) {
+		if s != nil {
+			opts.LabelSelector = s.String()
+		}
+		l, err := c.NetworkingV1().Ingresses("").List(ctx, opts)
+		if err != nil {
+			log.Warningf("Failed to list Ingresses: %s", err)
+			return nil, err
+		}
+		return l, nil
+	}
+}
+
+func ingressWatchFunc(ctx context.Context, c kubernetes.Interface, s labels.Selector) func(options meta.ListOptions) (watch.Interface, error) {
+	return func(options meta.ListOptions) (watch.Interface, error) {
+		if s != nil {
+			options.LabelSelector = s.String()
+		}
+		w, err := c.NetworkingV1().Ingresses("").Watch(ctx, options)
+		if err != nil {
+			log.Warningf("Failed to watch Ingresses: %s", err)
+			return nil, err
+		}
+		return w, nil
+	}
+}
+
+func dynamicListFunc(ctx context.Context, c dynamic.Interface, r schema.GroupVersionResource, s labels.Selector) func(meta.ListOptions) (runtime.Object, error) {
+	return func(opts meta.ListOptions) (runtime.Object, error) {
+		if s != nil {
+			opts.LabelSelector = s.String()
+		}
+		l, err := c.Resource(r).List(ctx, opts)
+		if err != nil {
+			log.Warningf("Failed to list %s: %s", r.Resource, err)
+			return nil, err
+		}
+		return l, nil
+	}
+}
+
+func dynamicWatchFunc(ctx context.Context, c dynamic.Interface, r schema.GroupVersionResource, s labels.Selector) func(options meta.ListOptions) (watch.Interface, error) {
+	return func(options meta.ListOptions) (watch.Interface, error) {
+		if s != nil {
+			options.LabelSelector = s.String()
+		}
+		w, err := c.Resource(r).Watch(ctx, options)
+		if err != nil {
+			log.Warningf("Failed to watch %s: %s", r.Resource, err)
+			return nil, err
+		}
+		return w, nil
+	}
+}
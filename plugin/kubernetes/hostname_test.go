@@ -0,0 +1,193 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	mcsClientsetFake "sigs.k8s.io/mcs-api/pkg/client/clientset/versioned/fake"
+)
+
+func gatewayObject(kind, name string, spec, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": "testns"},
+		"spec":       spec,
+		"status":     status,
+	}}
+}
+
+func kubernetesWithHostnames(ctx context.Context, t *testing.T) *Kubernetes {
+	client := fake.NewSimpleClientset()
+	client.CoreV1().Namespaces().Create(ctx, &api.Namespace{ObjectMeta: meta.ObjectMeta{Name: "testns"}}, meta.CreateOptions{})
+	client.NetworkingV1().Ingresses("testns").Create(ctx, &networking.Ingress{
+		ObjectMeta: meta.ObjectMeta{Name: "ing", Namespace: "testns"},
+		Spec: networking.IngressSpec{Rules: []networking.IngressRule{
+			{Host: "www.example.org"},
+			{Host: "*.apps.example.org"},
+		}},
+		Status: networking.IngressStatus{LoadBalancer: networking.IngressLoadBalancerStatus{
+			Ingress: []networking.IngressLoadBalancerIngress{{IP: "192.0.2.1"}, {Hostname: "lb.example.net"}},
+		}},
+	}, meta.CreateOptions{})
+
+	gw := gatewayObject("Gateway", "gw",
+		map[string]interface{}{"listeners": []interface{}{
+			map[string]interface{}{"name": "https", "hostname": "gw.example.org"},
+			map[string]interface{}{"name": "wildcard", "hostname": "*.gw.example.org"},
+		}},
+		map[string]interface{}{"addresses": []interface{}{
+			map[string]interface{}{"type": "IPAddress", "value": "192.0.2.10"},
+			map[string]interface{}{"value": "2001:db8::10"},
+			map[string]interface{}{"type": "NamedAddress", "value": "internal"},
+		}},
+	)
+	accepted := []interface{}{map[string]interface{}{"type": "Accepted", "status": "True"}}
+	route := gatewayObject("HTTPRoute", "route",
+		map[string]interface{}{"hostnames": []interface{}{"API.example.org"}},
+		map[string]interface{}{"parents": []interface{}{
+			map[string]interface{}{"parentRef": map[string]interface{}{"name": "gw"}, "conditions": accepted},
+		}},
+	)
+	pending := gatewayObject("HTTPRoute", "pending",
+		map[string]interface{}{"hostnames": []interface{}{"pending.example.org"}},
+		map[string]interface{}{"parents": []interface{}{
+			map[string]interface{}{"parentRef": map[string]interface{}{"name": "gw"}},
+		}},
+	)
+	dynClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			object.GatewayResource:   "GatewayList",
+			object.HTTPRouteResource: "HTTPRouteList",
+		},
+	)
+	// Created instead of passed to the fake, as that guesses the resource of a Gateway wrong.
+	for _, o := range []*unstructured.Unstructured{gw, route, pending} {
+		r := object.HTTPRouteResource
+		if o.GetKind() == "Gateway" {
+			r = object.GatewayResource
+		}
+		dynClient.Resource(r).Namespace("testns").Create(ctx, o, meta.CreateOptions{})
+	}
+
+	controller := newdnsController(ctx, client, mcsClientsetFake.NewSimpleClientset().MulticlusterV1alpha1(), dnsControlOpts{})
+	controller.dynClient = dynClient
+	k := New([]string{"cluster.local."})
+	k.APIConn = controller
+	if err := k.WatchHostnames(true, true); err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func hostnamesSynced(k *Kubernetes) bool {
+	ing, gw, _ := k.APIConn.(*dnsControl).hostnameListers()
+	return ing != nil && gw != nil
+}
+
+func TestExternalHostname(t *testing.T) {
+	ctx := context.Background()
+	k := kubernetesWithHostnames(ctx, t)
+
+	go k.APIConn.Run()
+	defer k.APIConn.Stop()
+	for !k.APIConn.HasSynced() || !hostnamesSynced(k) {
+		time.Sleep(time.Millisecond)
+	}
+
+	tests := []struct {
+		qname    string
+		rcode    int
+		expected []string
+	}{
+		{"www.example.org.", dns.RcodeSuccess, []string{"192.0.2.1", "lb.example.net"}},
+		{"WWW.example.org.", dns.RcodeSuccess, []string{"192.0.2.1", "lb.example.net"}},
+		{"foo.apps.example.org.", dns.RcodeSuccess, []string{"192.0.2.1", "lb.example.net"}},
+		// An Ingress wildcard covers a single label.
+		{"foo.bar.apps.example.org.", dns.RcodeNameError, nil},
+		// A Gateway wildcard is a suffix match.
+		{"foo.bar.gw.example.org.", dns.RcodeSuccess, []string{"192.0.2.10", "2001:db8::10"}},
+		{"gw.example.org.", dns.RcodeSuccess, []string{"192.0.2.10", "2001:db8::10"}},
+		{"api.example.org.", dns.RcodeSuccess, []string{"192.0.2.10", "2001:db8::10"}},
+		// Route isn't accepted by the gateway.
+		{"pending.example.org.", dns.RcodeNameError, nil},
+		{"apps.example.org.", dns.RcodeNameError, nil},
+		{"example.org.", dns.RcodeNameError, nil},
+	}
+
+	for i, tc := range tests {
+		state := request.Request{Req: new(dns.Msg).SetQuestion(tc.qname, dns.TypeA), Zone: "example.org."}
+		svcs, rcode := k.ExternalHostname(state)
+		if rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.rcode, rcode)
+		}
+		var hosts []string
+		for _, s := range svcs {
+			hosts = append(hosts, s.Host)
+		}
+		sort.Strings(hosts)
+		if len(hosts) != len(tc.expected) {
+			t.Errorf("Test %d: expected %v, got %v", i, tc.expected, hosts)
+			continue
+		}
+		for j := range hosts {
+			if hosts[j] != tc.expected[j] {
+				t.Errorf("Test %d: expected %v, got %v", i, tc.expected, hosts)
+				break
+			}
+		}
+	}
+
+	// Namespaces that aren't exposed are left out.
+	k.Namespaces = map[string]struct{}{"otherns": {}}
+	state := request.Request{Req: new(dns.Msg).SetQuestion("www.example.org.", dns.TypeA), Zone: "example.org."}
+	if svcs, rcode := k.ExternalHostname(state); rcode != dns.RcodeNameError || len(svcs) != 0 {
+		t.Errorf("Expected no addresses for a hostname in a namespace that isn't exposed, got %v", svcs)
+	}
+}
+
+func TestExternalHostnameNoGatewayAPI(t *testing.T) {
+	ctx := context.Background()
+	k := kubernetesWithHostnames(ctx, t)
+	// The Gateway API CRDs aren't installed.
+	k.APIConn.(*dnsControl).dynClient.(*dynamicfake.FakeDynamicClient).PrependReactor("*", "*", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("the server could not find the requested resource")
+	})
+
+	go k.APIConn.Run()
+	defer k.APIConn.Stop()
+	for !k.APIConn.HasSynced() {
+		time.Sleep(time.Millisecond)
+	}
+	for {
+		if ing, _, _ := k.APIConn.(*dnsControl).hostnameListers(); ing != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	state := request.Request{Req: new(dns.Msg).SetQuestion("www.example.org.", dns.TypeA), Zone: "example.org."}
+	if _, rcode := k.ExternalHostname(state); rcode != dns.RcodeSuccess {
+		t.Errorf("Expected the Ingress hostname to resolve, got rcode %d", rcode)
+	}
+	state = request.Request{Req: new(dns.Msg).SetQuestion("gw.example.org.", dns.TypeA), Zone: "example.org."}
+	if _, rcode := k.ExternalHostname(state); rcode != dns.RcodeNameError {
+		t.Errorf("Expected rcode %d for a Gateway hostname, got %d", dns.RcodeNameError, rcode)
+	}
+}
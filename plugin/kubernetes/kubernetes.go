@@ -22,6 +22,7 @@ import (
 	api "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -228,49 +229,13 @@ func (k *Kubernetes) getClientConfig() (*rest.Config, error) {
 
 // InitKubeCache initializes a new Kubernetes cache.
 func (k *Kubernetes) InitKubeCache(ctx context.Context) (onStart func() error, onShut func() error, err error) {
-	config, err := k.getClientConfig()
-	if err != nil {
+	if k.opts.watchCache.mode == watchCacheConsume {
+		// The objects are read from the watch cache file, no connection to the Kubernetes API is needed.
+		k.APIConn = newCacheControl(k.opts.watchCache.path, k.opts.watchCache.interval)
+	} else if err = k.initKubeController(ctx); err != nil {
 		return nil, nil, err
 	}
 
-	kubeClient, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create kubernetes notification controller: %q", err)
-	}
-
-	var mcsClient mcsClientset.MulticlusterV1alpha1Interface
-	if len(k.opts.multiclusterZones) > 0 {
-		mcsClient, err = mcsClientset.NewForConfig(config)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to create kubernetes multicluster notification controller: %q", err)
-		}
-	}
-
-	if k.opts.labelSelector != nil {
-		var selector labels.Selector
-		selector, err = meta.LabelSelectorAsSelector(k.opts.labelSelector)
-		if err != nil {
-			return nil, nil, fmt.Errorf("unable to create Selector for LabelSelector '%s': %q", k.opts.labelSelector, err)
-		}
-		k.opts.selector = selector
-	}
-
-	if k.opts.namespaceLabelSelector != nil {
-		var selector labels.Selector
-		selector, err = meta.LabelSelectorAsSelector(k.opts.namespaceLabelSelector)
-		if err != nil {
-			return nil, nil, fmt.Errorf("unable to create Selector for LabelSelector '%s': %q", k.opts.namespaceLabelSelector, err)
-		}
-		k.opts.namespaceSelector = selector
-	}
-
-	k.opts.initPodCache = k.podMode == podModeVerified
-
-	k.opts.zones = k.Zones
-	k.opts.endpointNameMode = k.endpointNameMode
-
-	k.APIConn = newdnsController(ctx, kubeClient, mcsClient, k.opts)
-
 	onStart = func() error {
 		go func() {
 			k.APIConn.Run()
@@ -306,6 +271,63 @@ func (k *Kubernetes) InitKubeCache(ctx context.Context) (onStart func() error, o
 	return onStart, onShut, err
 }
 
+// initKubeController sets k.APIConn to a controller that watches the Kubernetes API.
+func (k *Kubernetes) initKubeController(ctx context.Context) error {
+	config, err := k.getClientConfig()
+	if err != nil {
+		return err
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes notification controller: %q", err)
+	}
+
+	// The Gateway API objects are only watched on request of the external plugin, but the client
+	// needs the config.
+	dynClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes notification controller: %q", err)
+	}
+
+	var mcsClient mcsClientset.MulticlusterV1alpha1Interface
+	if len(k.opts.multiclusterZones) > 0 {
+		mcsClient, err = mcsClientset.NewForConfig(config)
+		if err != nil {
+			return fmt.Errorf("failed to create kubernetes multicluster notification controller: %q", err)
+		}
+	}
+
+	if k.opts.labelSelector != nil {
+		var selector labels.Selector
+		selector, err = meta.LabelSelectorAsSelector(k.opts.labelSelector)
+		if err != nil {
+			return fmt.Errorf("unable to create Selector for LabelSelector '%s': %q", k.opts.labelSelector, err)
+		}
+		k.opts.selector = selector
+	}
+
+	if k.opts.namespaceLabelSelector != nil {
+		var selector labels.Selector
+		selector, err = meta.LabelSelectorAsSelector(k.opts.namespaceLabelSelector)
+		if err != nil {
+			return fmt.Errorf("unable to create Selector for LabelSelector '%s': %q", k.opts.namespaceLabelSelector, err)
+		}
+		k.opts.namespaceSelector = selector
+	}
+
+	// Topology aware answers need the pods to find the node, and thus the zone, of the client.
+	k.opts.initPodCache = k.podMode == podModeVerified || k.opts.topology
+
+	k.opts.zones = k.Zones
+	k.opts.endpointNameMode = k.endpointNameMode
+
+	dnsCtrl := newdnsController(ctx, kubeClient, mcsClient, k.opts)
+	dnsCtrl.dynClient = dynClient
+	k.APIConn = dnsCtrl
+	return nil
+}
+
 // Records looks up services in kubernetes.
 func (k *Kubernetes) Records(ctx context.Context, state request.Request, exact bool) ([]msg.Service, error) {
 	multicluster := k.isMultiClusterZone(state.Zone)
@@ -333,7 +355,11 @@ func (k *Kubernetes) Records(ctx context.Context, state request.Request, exact b
 	var services []msg.Service
 	var err error
 	if !multicluster {
-		services, err = k.findServices(r, state.Zone)
+		client := ""
+		if k.opts.topology {
+			client = state.IP()
+		}
+		services, err = k.findServices(r, state.Zone, client, state.QType() == dns.TypeSRV)
 	} else {
 		services, err = k.findMultiClusterServices(r, state.Zone)
 	}
@@ -415,8 +441,10 @@ func (k *Kubernetes) findPods(r recordRequest, zone string) (pods []msg.Service,
 	return pods, err
 }
 
-// findServices returns the services matching r from the cache.
-func (k *Kubernetes) findServices(r recordRequest, zone string) (services []msg.Service, err error) {
+// findServices returns the services matching r from the cache. The endpoints of headless services are
+// filtered on the topology of the client with IP address client, when that is enabled. When srv is
+// true, or r has a port, only the ports that the DNS policy of a service allows SRV records for are used.
+func (k *Kubernetes) findServices(r recordRequest, zone, client string, srv bool) (services []msg.Service, err error) {
 	if !k.namespaceExposed(r.namespace) {
 		return nil, errNoItems
 	}
@@ -449,6 +477,13 @@ func (k *Kubernetes) findServices(r recordRequest, zone string) (services []msg.
 			continue
 		}
 
+		policy := k.policy(svc)
+		if policy.IsHidden() {
+			continue
+		}
+		ttl := policy.TTLOr(k.ttl)
+		ports := srv || r.port != ""
+
 		// If "ignore empty_service" option is set and no endpoints exist, return NXDOMAIN unless
 		// it's a headless or externalName service (covered below).
 		if k.opts.ignoreEmptyService && svc.Type != api.ServiceTypeExternalName && !svc.Headless() { // serve NXDOMAIN if no endpoint is able to answer
@@ -470,7 +505,7 @@ func (k *Kubernetes) findServices(r recordRequest, zone string) (services []msg.
 			if r.endpoint != "" || r.port != "" || r.protocol != "" {
 				continue
 			}
-			s := msg.Service{Key: strings.Join([]string{zonePath, Svc, svc.Namespace, svc.Name}, "/"), Host: svc.ExternalName, TTL: k.ttl}
+			s := msg.Service{Key: strings.Join([]string{zonePath, Svc, svc.Namespace, svc.Name}, "/"), Host: svc.ExternalName, TTL: ttl}
 			if t, _ := s.HostType(); t == dns.TypeCNAME {
 				s.Key = strings.Join([]string{zonePath, Svc, svc.Namespace, svc.Name}, "/")
 				services = append(services, s)
@@ -486,6 +521,11 @@ func (k *Kubernetes) findServices(r recordRequest, zone string) (services []msg.
 				endpointsList = endpointsListFunc()
 			}
 
+			var topology map[string]struct{}
+			if k.opts.topology && r.endpoint == "" {
+				topology = topologyFilter(svc, endpointsList, k.clientZone(client))
+			}
+
 			for _, ep := range endpointsList {
 				if object.EndpointsKey(svc.Name, svc.Namespace) != ep.Index {
 					continue
@@ -499,16 +539,25 @@ func (k *Kubernetes) findServices(r recordRequest, zone string) (services []msg.
 								continue
 							}
 						}
+						if topology != nil {
+							if _, ok := topology[addr.IP]; !ok {
+								continue
+							}
+						}
 
 						for _, p := range eps.Ports {
 							if !(matchPortAndProtocol(r.port, p.Name, r.protocol, p.Protocol)) {
 								continue
 							}
-							s := msg.Service{Host: addr.IP, Port: int(p.Port), TTL: k.ttl}
-							s.Key = strings.Join([]string{zonePath, Svc, svc.Namespace, svc.Name, endpointHostname(addr, k.endpointNameMode)}, "/")
 
 							err = nil
 
+							if ports && !policy.SRVPort(p.Name, p.Port) {
+								continue
+							}
+							s := msg.Service{Host: addr.IP, Port: int(p.Port), TTL: ttl}
+							s.Key = strings.Join([]string{zonePath, Svc, svc.Namespace, svc.Name, endpointHostname(addr, k.endpointNameMode)}, "/")
+
 							services = append(services, s)
 						}
 					}
@@ -525,8 +574,11 @@ func (k *Kubernetes) findServices(r recordRequest, zone string) (services []msg.
 
 			err = nil
 
+			if ports && !policy.SRVPort(p.Name, p.Port) {
+				continue
+			}
 			for _, ip := range svc.ClusterIPs {
-				s := msg.Service{Host: ip, Port: int(p.Port), TTL: k.ttl}
+				s := msg.Service{Host: ip, Port: int(p.Port), TTL: ttl}
 				s.Key = strings.Join([]string{zonePath, Svc, svc.Namespace, svc.Name}, "/")
 				services = append(services, s)
 			}
@@ -342,6 +342,10 @@ func (APIConnServiceTest) GetNamespaceByName(name string) (*object.Namespace, er
 	}, nil
 }
 
+func (APIConnServiceTest) NodeZone(string) string                           { return "" }
+func (APIConnServiceTest) HostnameIndex(string) []HostAddresses             { return nil }
+func (APIConnServiceTest) WatchHostnames(context.Context, bool, bool) error { return nil }
+
 func TestServices(t *testing.T) {
 	k := New([]string{"interwebs.test.", "clusterset.test."})
 	k.opts.multiclusterZones = []string{"clusterset.test."}
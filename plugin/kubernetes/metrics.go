@@ -49,6 +49,17 @@ var (
 		},
 		[]string{"code", "method", "host"},
 	)
+
+	// watchCacheStale is 1 when the consumed watch cache file hasn't been updated by the publisher for too long.
+	watchCacheStale = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: plugin.Namespace,
+			Subsystem: "kubernetes",
+			Name:      "watch_cache_stale",
+			Help:      "Whether the watch cache file hasn't been updated by the publisher for too long (1) or not (0).",
+		},
+		[]string{"file"},
+	)
 )
 
 func init() {
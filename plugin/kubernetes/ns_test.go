@@ -102,6 +102,10 @@ func (APIConnTest) GetNamespaceByName(name string) (*object.Namespace, error) {
 	return nil, fmt.Errorf("namespace not found")
 }
 
+func (APIConnTest) NodeZone(string) string                           { return "" }
+func (APIConnTest) HostnameIndex(string) []HostAddresses             { return nil }
+func (APIConnTest) WatchHostnames(context.Context, bool, bool) error { return nil }
+
 func TestNsAddrs(t *testing.T) {
 	k := New([]string{"inter.webs.test."})
 	k.APIConn = &APIConnTest{}
@@ -0,0 +1,217 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The Gateway API types aren't part of the Kubernetes API, they are read as unstructured objects.
+var (
+	// GatewayResource is the resource of the Gateway API Gateways.
+	GatewayResource = schema.GroupVersionResource{Group: gatewayGroup, Version: "v1", Resource: "gateways"}
+	// HTTPRouteResource is the resource of the Gateway API HTTPRoutes.
+	HTTPRouteResource = schema.GroupVersionResource{Group: gatewayGroup, Version: "v1", Resource: "httproutes"}
+)
+
+const gatewayGroup = "gateway.networking.k8s.io"
+
+// Gateway is a stripped down Gateway API Gateway with only the items we need for CoreDNS.
+type Gateway struct {
+	// Don't add new fields to this struct without talking to the CoreDNS maintainers.
+	Version   string
+	Name      string
+	Namespace string
+	// Hostnames are the hostnames of the listeners, lower cased.
+	Hostnames []string
+	// Addresses are the IP addresses or hostnames from the status.
+	Addresses []string
+
+	*Empty
+}
+
+// GatewayKey returns a string using for the index.
+func GatewayKey(name, namespace string) string { return namespace + "/" + name }
+
+// ToGateway converts an unstructured Gateway to a *Gateway.
+func ToGateway(obj meta.Object) (meta.Object, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object %v", obj)
+	}
+	g := &Gateway{
+		Version:   u.GetResourceVersion(),
+		Name:      u.GetName(),
+		Namespace: u.GetNamespace(),
+	}
+	listeners, _, _ := unstructured.NestedSlice(u.Object, "spec", "listeners")
+	for _, l := range listeners {
+		if h := stringField(l, "hostname"); h != "" {
+			g.Hostnames = append(g.Hostnames, strings.ToLower(h))
+		}
+	}
+	addrs, _, _ := unstructured.NestedSlice(u.Object, "status", "addresses")
+	for _, a := range addrs {
+		// The type defaults to IPAddress, other types than IPAddress and Hostname can't be put in DNS.
+		switch stringField(a, "type") {
+		case "", "IPAddress", "Hostname":
+			if v := stringField(a, "value"); v != "" {
+				g.Addresses = append(g.Addresses, v)
+			}
+		}
+	}
+
+	u.Object = nil
+
+	return g, nil
+}
+
+var _ runtime.Object = &Gateway{}
+
+// DeepCopyObject implements the ObjectKind interface.
+func (g *Gateway) DeepCopyObject() runtime.Object {
+	g1 := &Gateway{
+		Version:   g.Version,
+		Name:      g.Name,
+		Namespace: g.Namespace,
+		Hostnames: make([]string, len(g.Hostnames)),
+		Addresses: make([]string, len(g.Addresses)),
+	}
+	copy(g1.Hostnames, g.Hostnames)
+	copy(g1.Addresses, g.Addresses)
+	return g1
+}
+
+// GetNamespace implements the metav1.Object interface.
+func (g *Gateway) GetNamespace() string { return g.Namespace }
+
+// SetNamespace implements the metav1.Object interface.
+func (g *Gateway) SetNamespace(namespace string) {}
+
+// GetName implements the metav1.Object interface.
+func (g *Gateway) GetName() string { return g.Name }
+
+// SetName implements the metav1.Object interface.
+func (g *Gateway) SetName(name string) {}
+
+// GetResourceVersion implements the metav1.Object interface.
+func (g *Gateway) GetResourceVersion() string { return g.Version }
+
+// SetResourceVersion implements the metav1.Object interface.
+func (g *Gateway) SetResourceVersion(version string) {}
+
+// HTTPRoute is a stripped down Gateway API HTTPRoute with only the items we need for CoreDNS.
+type HTTPRoute struct {
+	// Don't add new fields to this struct without talking to the CoreDNS maintainers.
+	Version   string
+	Name      string
+	Namespace string
+	// Hostnames are the hostnames of the route, lower cased.
+	Hostnames []string
+	// Gateways are the keys, see GatewayKey, of the Gateways that accepted the route.
+	Gateways []string
+
+	*Empty
+}
+
+// ToHTTPRoute converts an unstructured HTTPRoute to a *HTTPRoute.
+func ToHTTPRoute(obj meta.Object) (meta.Object, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object %v", obj)
+	}
+	r := &HTTPRoute{
+		Version:   u.GetResourceVersion(),
+		Name:      u.GetName(),
+		Namespace: u.GetNamespace(),
+	}
+	hostnames, _, _ := unstructured.NestedStringSlice(u.Object, "spec", "hostnames")
+	for _, h := range hostnames {
+		r.Hostnames = append(r.Hostnames, strings.ToLower(h))
+	}
+	// Only use the parents that accepted the route, these are listed in the status.
+	parents, _, _ := unstructured.NestedSlice(u.Object, "status", "parents")
+	for _, p := range parents {
+		m, ok := p.(map[string]interface{})
+		if !ok || !accepted(m) {
+			continue
+		}
+		ref, ok := m["parentRef"]
+		if !ok {
+			continue
+		}
+		if g := stringField(ref, "group"); g != "" && g != gatewayGroup {
+			continue
+		}
+		if k := stringField(ref, "kind"); k != "" && k != "Gateway" {
+			continue
+		}
+		ns := stringField(ref, "namespace")
+		if ns == "" {
+			ns = r.Namespace
+		}
+		r.Gateways = append(r.Gateways, GatewayKey(stringField(ref, "name"), ns))
+	}
+
+	u.Object = nil
+
+	return r, nil
+}
+
+// accepted returns true if the route parent status has an Accepted condition that is true.
+func accepted(parent map[string]interface{}) bool {
+	conditions, _, _ := unstructured.NestedSlice(parent, "conditions")
+	for _, c := range conditions {
+		if stringField(c, "type") == "Accepted" && stringField(c, "status") == "True" {
+			return true
+		}
+	}
+	return false
+}
+
+func stringField(obj interface{}, field string) string {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	s, _ := m[field].(string)
+	return s
+}
+
+var _ runtime.Object = &HTTPRoute{}
+
+// DeepCopyObject implements the ObjectKind interface.
+func (r *HTTPRoute) DeepCopyObject() runtime.Object {
+	r1 := &HTTPRoute{
+		Version:   r.Version,
+		Name:      r.Name,
+		Namespace: r.Namespace,
+		Hostnames: make([]string, len(r.Hostnames)),
+		Gateways:  make([]string, len(r.Gateways)),
+	}
+	copy(r1.Hostnames, r.Hostnames)
+	copy(r1.Gateways, r.Gateways)
+	return r1
+}
+
+// GetNamespace implements the metav1.Object interface.
+func (r *HTTPRoute) GetNamespace() string { return r.Namespace }
+
+// SetNamespace implements the metav1.Object interface.
+func (r *HTTPRoute) SetNamespace(namespace string) {}
+
+// GetName implements the metav1.Object interface.
+func (r *HTTPRoute) GetName() string { return r.Name }
+
+// SetName implements the metav1.Object interface.
+func (r *HTTPRoute) SetName(name string) {}
+
+// GetResourceVersion implements the metav1.Object interface.
+func (r *HTTPRoute) GetResourceVersion() string { return r.Version }
+
+// SetResourceVersion implements the metav1.Object interface.
+func (r *HTTPRoute) SetResourceVersion(version string) {}
@@ -13,6 +13,8 @@ type Namespace struct {
 	// Don't add new fields to this struct without talking to the CoreDNS maintainers.
 	Version string
 	Name    string
+	// Policy is the DNS policy set with annotations, nil when there is none.
+	Policy *Policy
 
 	*Empty
 }
@@ -26,6 +28,7 @@ func ToNamespace(obj meta.Object) (meta.Object, error) {
 	n := &Namespace{
 		Version: ns.GetResourceVersion(),
 		Name:    ns.GetName(),
+		Policy:  ToPolicy(ns.GetAnnotations()),
 	}
 	*ns = api.Namespace{}
 	return n, nil
@@ -38,6 +41,7 @@ func (n *Namespace) DeepCopyObject() runtime.Object {
 	n1 := &Namespace{
 		Version: n.Version,
 		Name:    n.Name,
+		Policy:  n.Policy.DeepCopy(),
 	}
 	return n1
 }
@@ -17,6 +17,7 @@ type Pod struct {
 	Name      string
 	Namespace string
 	Labels    map[string]string
+	NodeName  string
 
 	*Empty
 }
@@ -35,6 +36,7 @@ func ToPod(obj meta.Object) (meta.Object, error) {
 		Namespace: apiPod.GetNamespace(),
 		Name:      apiPod.GetName(),
 		Labels:    apiPod.GetLabels(),
+		NodeName:  apiPod.Spec.NodeName,
 	}
 	t := apiPod.DeletionTimestamp
 	if t != nil && !(*t).Time.IsZero() {
@@ -57,6 +59,7 @@ func (p *Pod) DeepCopyObject() runtime.Object {
 		PodIP:     p.PodIP,
 		Namespace: p.Namespace,
 		Name:      p.Name,
+		NodeName:  p.NodeName,
 	}
 	return p1
 }
@@ -0,0 +1,146 @@
+package object
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The annotations that can be set on a namespace or a service to change how it is served.
+const (
+	// AnnotationTTL sets the TTL of the records, in seconds.
+	AnnotationTTL = "dns.coredns.io/ttl"
+	// AnnotationHidden hides the services from DNS when set to "true".
+	AnnotationHidden = "dns.coredns.io/hidden"
+	// AnnotationSRVPorts is a comma separated list of the names or numbers of the ports that get SRV records.
+	AnnotationSRVPorts = "dns.coredns.io/srv-ports"
+)
+
+// maxTTL is the highest TTL that can be set with an annotation, the same as for the ttl option.
+const maxTTL = 3600
+
+// Policy is the DNS policy set with annotations on a namespace or a service. Annotations that aren't
+// set, or that have an invalid value, are left at their zero value.
+type Policy struct {
+	// TTL is the TTL of the records, nil when not set.
+	TTL *uint32
+	// Hidden is nil when not set.
+	Hidden *bool
+	// SRVPorts are the ports that get SRV records, nil when not set.
+	SRVPorts []string
+}
+
+// ToPolicy returns the policy set with annotations, or nil when none of the annotations are set.
+func ToPolicy(annotations map[string]string) *Policy {
+	var p *Policy
+	if v, ok := annotations[AnnotationTTL]; ok {
+		if ttl, err := strconv.ParseUint(v, 10, 32); err == nil && ttl <= maxTTL {
+			t := uint32(ttl)
+			p = &Policy{TTL: &t}
+		}
+	}
+	if v, ok := annotations[AnnotationHidden]; ok {
+		if hidden, err := strconv.ParseBool(v); err == nil {
+			if p == nil {
+				p = &Policy{}
+			}
+			p.Hidden = &hidden
+		}
+	}
+	if v, ok := annotations[AnnotationSRVPorts]; ok {
+		if p == nil {
+			p = &Policy{}
+		}
+		p.SRVPorts = []string{}
+		for _, port := range strings.Split(v, ",") {
+			if port = strings.TrimSpace(port); port != "" {
+				p.SRVPorts = append(p.SRVPorts, port)
+			}
+		}
+	}
+	return p
+}
+
+// Merge returns the policy of p with the unset values taken from parent. Both p and parent may be nil.
+func (p *Policy) Merge(parent *Policy) *Policy {
+	if p == nil {
+		return parent
+	}
+	if parent == nil {
+		return p
+	}
+	m := *p
+	if m.TTL == nil {
+		m.TTL = parent.TTL
+	}
+	if m.Hidden == nil {
+		m.Hidden = parent.Hidden
+	}
+	if m.SRVPorts == nil {
+		m.SRVPorts = parent.SRVPorts
+	}
+	return &m
+}
+
+// IsHidden returns true if the services must not be served.
+func (p *Policy) IsHidden() bool { return p != nil && p.Hidden != nil && *p.Hidden }
+
+// SRVPort returns true if the port with the name and number gets SRV records.
+func (p *Policy) SRVPort(name string, port int32) bool {
+	if p == nil || p.SRVPorts == nil {
+		return true
+	}
+	number := strconv.Itoa(int(port))
+	for _, s := range p.SRVPorts {
+		if (name != "" && strings.EqualFold(s, name)) || s == number {
+			return true
+		}
+	}
+	return false
+}
+
+// TTLOr returns the TTL of the policy, or ttl when that isn't set.
+func (p *Policy) TTLOr(ttl uint32) uint32 {
+	if p == nil || p.TTL == nil {
+		return ttl
+	}
+	return *p.TTL
+}
+
+// DeepCopy returns a copy of p.
+func (p *Policy) DeepCopy() *Policy {
+	if p == nil {
+		return nil
+	}
+	p1 := &Policy{}
+	if p.TTL != nil {
+		ttl := *p.TTL
+		p1.TTL = &ttl
+	}
+	if p.Hidden != nil {
+		hidden := *p.Hidden
+		p1.Hidden = &hidden
+	}
+	if p.SRVPorts != nil {
+		p1.SRVPorts = make([]string, len(p.SRVPorts))
+		copy(p1.SRVPorts, p.SRVPorts)
+	}
+	return p1
+}
+
+// Equal returns true if p and p1 are the same policy.
+func (p *Policy) Equal(p1 *Policy) bool {
+	if p == nil || p1 == nil {
+		return p == p1
+	}
+	if (p.TTL == nil) != (p1.TTL == nil) || (p.TTL != nil && *p.TTL != *p1.TTL) {
+		return false
+	}
+	if p.IsHidden() != p1.IsHidden() || (p.Hidden == nil) != (p1.Hidden == nil) {
+		return false
+	}
+	if (p.SRVPorts == nil) != (p1.SRVPorts == nil) {
+		return false
+	}
+	return slices.Equal(p.SRVPorts, p1.SRVPorts)
+}
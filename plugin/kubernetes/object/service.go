@@ -20,9 +20,15 @@ type Service struct {
 	ExternalName string
 	Ports        []api.ServicePort
 
+	// TrafficDistribution is the traffic distribution preference, i.e. "PreferClose".
+	TrafficDistribution string
+
 	// ExternalIPs we may want to export.
 	ExternalIPs []string
 
+	// Policy is the DNS policy set with annotations, nil when there is none.
+	Policy *Policy
+
 	*Empty
 }
 
@@ -44,6 +50,8 @@ func ToService(obj meta.Object) (meta.Object, error) {
 		ExternalName: svc.Spec.ExternalName,
 
 		ExternalIPs: make([]string, len(svc.Status.LoadBalancer.Ingress)+len(svc.Spec.ExternalIPs)),
+
+		Policy: ToPolicy(svc.GetAnnotations()),
 	}
 
 	if len(svc.Spec.ClusterIPs) > 0 {
@@ -61,6 +69,10 @@ func ToService(obj meta.Object) (meta.Object, error) {
 		copy(s.Ports, svc.Spec.Ports)
 	}
 
+	if svc.Spec.TrafficDistribution != nil {
+		s.TrafficDistribution = *svc.Spec.TrafficDistribution
+	}
+
 	li := copy(s.ExternalIPs, svc.Spec.ExternalIPs)
 	for i, lb := range svc.Status.LoadBalancer.Ingress {
 		if lb.IP != "" {
@@ -94,6 +106,9 @@ func (s *Service) DeepCopyObject() runtime.Object {
 		ClusterIPs:   make([]string, len(s.ClusterIPs)),
 		Ports:        make([]api.ServicePort, len(s.Ports)),
 		ExternalIPs:  make([]string, len(s.ExternalIPs)),
+
+		TrafficDistribution: s.TrafficDistribution,
+		Policy:              s.Policy.DeepCopy(),
 	}
 	copy(s1.ClusterIPs, s.ClusterIPs)
 	copy(s1.Ports, s.Ports)
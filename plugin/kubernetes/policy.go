@@ -0,0 +1,19 @@
+package kubernetes
+
+import "github.com/coredns/coredns/plugin/kubernetes/object"
+
+// policy returns the DNS policy of svc, with the values that aren't set on the service taken from
+// its namespace. It returns nil when the annotations option isn't set.
+func (k *Kubernetes) policy(svc *object.Service) *object.Policy {
+	if !k.opts.annotations {
+		return nil
+	}
+	var nsPolicy *object.Policy
+	if ns, err := k.APIConn.GetNamespaceByName(svc.Namespace); err == nil {
+		nsPolicy = ns.Policy
+	}
+	return svc.Policy.Merge(nsPolicy)
+}
+
+// hidden returns true if svc must not be served, because of the policy of the service or its namespace.
+func (k *Kubernetes) hidden(svc *object.Service) bool { return k.policy(svc).IsHidden() }
@@ -0,0 +1,137 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	api "k8s.io/api/core/v1"
+)
+
+type APIConnPolicyTest struct{ APIConnServeTest }
+
+var isHidden = true
+
+func ttl(t uint32) *uint32 { return &t }
+
+var policyNamespaces = map[string]*object.Policy{
+	"slowns":   {TTL: ttl(60)},
+	"hiddenns": {Hidden: &isHidden},
+}
+
+var policySvcs = map[string][]*object.Service{
+	"svc.slowns": {{Name: "svc", Namespace: "slowns", ClusterIPs: []string{"10.0.3.1"},
+		Ports: []api.ServicePort{{Name: "http", Port: 80, Protocol: "TCP"}}}},
+	"fast.slowns": {{Name: "fast", Namespace: "slowns", ClusterIPs: []string{"10.0.3.2"},
+		Ports:  []api.ServicePort{{Name: "http", Port: 80, Protocol: "TCP"}},
+		Policy: &object.Policy{TTL: ttl(10)}}},
+	"nocache.slowns": {{Name: "nocache", Namespace: "slowns", ClusterIPs: []string{"10.0.3.6"},
+		Ports:  []api.ServicePort{{Name: "http", Port: 80, Protocol: "TCP"}},
+		Policy: &object.Policy{TTL: ttl(0)}}},
+	"svc.hiddenns": {{Name: "svc", Namespace: "hiddenns", ClusterIPs: []string{"10.0.3.3"},
+		Ports: []api.ServicePort{{Name: "http", Port: 80, Protocol: "TCP"}}}},
+	"hidden.testns": {{Name: "hidden", Namespace: "testns", ClusterIPs: []string{"10.0.3.4"},
+		Ports:  []api.ServicePort{{Name: "http", Port: 80, Protocol: "TCP"}},
+		Policy: &object.Policy{Hidden: &isHidden}}},
+	"ports.testns": {{Name: "ports", Namespace: "testns", ClusterIPs: []string{"10.0.3.5"},
+		Ports: []api.ServicePort{
+			{Name: "http", Port: 80, Protocol: "TCP"},
+			{Name: "admin", Port: 9090, Protocol: "TCP"},
+		},
+		Policy: &object.Policy{SRVPorts: []string{"http"}}}},
+}
+
+func (APIConnPolicyTest) SvcIndex(s string) []*object.Service { return policySvcs[s] }
+
+func (APIConnPolicyTest) GetNamespaceByName(name string) (*object.Namespace, error) {
+	return &object.Namespace{Name: name, Policy: policyNamespaces[name]}, nil
+}
+
+func TestServeDNSPolicy(t *testing.T) {
+	tests := []struct {
+		annotations bool
+		tc          test.Case
+	}{
+		// TTL of the namespace.
+		{true, test.Case{Qname: "svc.slowns.svc.cluster.local.", Qtype: dns.TypeA, Answer: []dns.RR{
+			test.A("svc.slowns.svc.cluster.local.	60	IN	A	10.0.3.1"),
+		}}},
+		// TTL of the service overrides the one of the namespace.
+		{true, test.Case{Qname: "fast.slowns.svc.cluster.local.", Qtype: dns.TypeA, Answer: []dns.RR{
+			test.A("fast.slowns.svc.cluster.local.	10	IN	A	10.0.3.2"),
+		}}},
+		// A TTL of 0 is a TTL as well.
+		{true, test.Case{Qname: "nocache.slowns.svc.cluster.local.", Qtype: dns.TypeA, Answer: []dns.RR{
+			test.A("nocache.slowns.svc.cluster.local.	0	IN	A	10.0.3.6"),
+		}}},
+		{true, test.Case{Qname: "svc.hiddenns.svc.cluster.local.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		}}},
+		{true, test.Case{Qname: "hidden.testns.svc.cluster.local.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		}}},
+		{true, test.Case{Qname: "ports.testns.svc.cluster.local.", Qtype: dns.TypeSRV, Answer: []dns.RR{
+			test.SRV("ports.testns.svc.cluster.local.	5	IN	SRV	0 100 80 ports.testns.svc.cluster.local."),
+		}, Extra: []dns.RR{
+			test.A("ports.testns.svc.cluster.local.	5	IN	A	10.0.3.5"),
+		}}},
+		{true, test.Case{Qname: "_admin._tcp.ports.testns.svc.cluster.local.", Qtype: dns.TypeSRV, Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		}}},
+		// Address records are not affected by the SRV ports.
+		{true, test.Case{Qname: "ports.testns.svc.cluster.local.", Qtype: dns.TypeA, Answer: []dns.RR{
+			test.A("ports.testns.svc.cluster.local.	5	IN	A	10.0.3.5"),
+		}}},
+		// Annotations aren't used when not enabled.
+		{false, test.Case{Qname: "hidden.testns.svc.cluster.local.", Qtype: dns.TypeA, Answer: []dns.RR{
+			test.A("hidden.testns.svc.cluster.local.	5	IN	A	10.0.3.4"),
+		}}},
+		{false, test.Case{Qname: "svc.slowns.svc.cluster.local.", Qtype: dns.TypeA, Answer: []dns.RR{
+			test.A("svc.slowns.svc.cluster.local.	5	IN	A	10.0.3.1"),
+		}}},
+	}
+
+	for i, tc := range tests {
+		k := New([]string{"cluster.local."})
+		k.APIConn = &APIConnPolicyTest{}
+		k.Next = test.NextHandler(dns.RcodeSuccess, nil)
+		k.opts.annotations = tc.annotations
+
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := k.ServeDNS(context.TODO(), w, tc.tc.Msg()); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		if err := test.SortAndCheck(w.Msg, tc.tc); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+}
+
+func TestToPolicy(t *testing.T) {
+	tests := []struct {
+		annotations map[string]string
+		expected    *object.Policy
+	}{
+		{nil, nil},
+		{map[string]string{"other": "value"}, nil},
+		{map[string]string{object.AnnotationTTL: "30"}, &object.Policy{TTL: ttl(30)}},
+		{map[string]string{object.AnnotationTTL: "0"}, &object.Policy{TTL: ttl(0)}},
+		// Invalid values are ignored.
+		{map[string]string{object.AnnotationTTL: "7200"}, nil},
+		{map[string]string{object.AnnotationTTL: "-1", object.AnnotationHidden: "yes"}, nil},
+		{map[string]string{object.AnnotationHidden: "true"}, &object.Policy{Hidden: &isHidden}},
+		{map[string]string{object.AnnotationSRVPorts: "http, 8080,"}, &object.Policy{SRVPorts: []string{"http", "8080"}}},
+		{map[string]string{object.AnnotationSRVPorts: ""}, &object.Policy{SRVPorts: []string{}}},
+	}
+
+	for i, tc := range tests {
+		p := object.ToPolicy(tc.annotations)
+		if !p.Equal(tc.expected) {
+			t.Errorf("Test %d: expected %+v, got %+v", i, tc.expected, p)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/kubernetes/object"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/request"
 )
@@ -33,8 +34,12 @@ func (k *Kubernetes) serviceRecordForIP(ip, name string) []msg.Service {
 		if len(k.Namespaces) > 0 && !k.namespaceExposed(service.Namespace) {
 			continue
 		}
+		policy := k.policy(service)
+		if policy.IsHidden() {
+			continue
+		}
 		domain := strings.Join([]string{service.Name, service.Namespace, Svc, k.primaryZone()}, ".")
-		return []msg.Service{{Host: domain, TTL: k.ttl}}
+		return []msg.Service{{Host: domain, TTL: policy.TTLOr(k.ttl)}}
 	}
 	// If no cluster ips match, search endpoints
 	var svcs []msg.Service
@@ -42,14 +47,31 @@ func (k *Kubernetes) serviceRecordForIP(ip, name string) []msg.Service {
 		if len(k.Namespaces) > 0 && !k.namespaceExposed(ep.Namespace) {
 			continue
 		}
+		ttl, hidden := k.endpointsPolicy(ep)
+		if hidden {
+			continue
+		}
 		for _, eps := range ep.Subsets {
 			for _, addr := range eps.Addresses {
 				if addr.IP == ip {
 					domain := strings.Join([]string{endpointHostname(addr, k.endpointNameMode), ep.Index, Svc, k.primaryZone()}, ".")
-					svcs = append(svcs, msg.Service{Host: domain, TTL: k.ttl})
+					svcs = append(svcs, msg.Service{Host: domain, TTL: ttl})
 				}
 			}
 		}
 	}
 	return svcs
 }
+
+// endpointsPolicy returns the TTL for the records of ep and if they are hidden, following the DNS policy
+// of the service of the endpoints.
+func (k *Kubernetes) endpointsPolicy(ep *object.Endpoints) (uint32, bool) {
+	if !k.opts.annotations {
+		return k.ttl, false
+	}
+	for _, svc := range k.APIConn.SvcIndex(ep.Index) {
+		policy := k.policy(svc)
+		return policy.TTLOr(k.ttl), policy.IsHidden()
+	}
+	return k.ttl, false
+}
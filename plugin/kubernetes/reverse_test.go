@@ -151,6 +151,10 @@ func (APIConnReverseTest) GetNamespaceByName(name string) (*object.Namespace, er
 	}, nil
 }
 
+func (APIConnReverseTest) NodeZone(string) string                           { return "" }
+func (APIConnReverseTest) HostnameIndex(string) []HostAddresses             { return nil }
+func (APIConnReverseTest) WatchHostnames(context.Context, bool, bool) error { return nil }
+
 func TestReverse(t *testing.T) {
 	k := New([]string{"cluster.local.", "0.10.in-addr.arpa.", "168.192.in-addr.arpa.", "0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.c.b.a.4.3.2.1.ip6.arpa.", "0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.3.0.0.7.7.0.0.0.0.d.f.ip6.arpa."})
 	k.APIConn = &APIConnReverseTest{}
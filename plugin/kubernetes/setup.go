@@ -7,6 +7,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
@@ -229,6 +230,41 @@ func ParseStanza(c *caddy.Controller) (*Kubernetes, error) {
 				overrides,
 			)
 			k8s.ClientConfig = config
+		case "topology":
+			if len(c.RemainingArgs()) != 0 {
+				return nil, c.ArgErr()
+			}
+			k8s.opts.topology = true
+		case "annotations":
+			if len(c.RemainingArgs()) != 0 {
+				return nil, c.ArgErr()
+			}
+			k8s.opts.annotations = true
+		case "watch_cache":
+			args := c.RemainingArgs()
+			if len(args) != 2 && len(args) != 3 {
+				return nil, c.ArgErr()
+			}
+			switch args[0] {
+			case "publish":
+				k8s.opts.watchCache.mode = watchCachePublish
+			case "consume":
+				k8s.opts.watchCache.mode = watchCacheConsume
+			default:
+				return nil, c.Errf("watch_cache must be publish or consume: %s", args[0])
+			}
+			k8s.opts.watchCache.path = args[1]
+			k8s.opts.watchCache.interval = defaultWatchCacheInterval
+			if len(args) == 3 {
+				d, err := time.ParseDuration(args[2])
+				if err != nil {
+					return nil, c.Errf("unable to parse watch_cache interval: %s", err)
+				}
+				if d <= 0 {
+					return nil, c.Errf("watch_cache interval must be positive: %s", d)
+				}
+				k8s.opts.watchCache.interval = d
+			}
 		case "multicluster":
 			k8s.opts.multiclusterZones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), []string{})
 		default:
@@ -240,6 +276,20 @@ func ParseStanza(c *caddy.Controller) (*Kubernetes, error) {
 		return nil, c.Errf("namespaces and namespace_labels cannot both be set")
 	}
 
+	// A consumer of the watch cache file only has the services, endpoints and namespaces of the publisher.
+	if k8s.opts.watchCache.mode == watchCacheConsume {
+		switch {
+		case k8s.podMode == podModeVerified:
+			return nil, c.Errf("pods verified cannot be used with watch_cache consume")
+		case k8s.opts.topology:
+			return nil, c.Errf("topology cannot be used with watch_cache consume")
+		case len(k8s.opts.multiclusterZones) > 0:
+			return nil, c.Errf("multicluster cannot be used with watch_cache consume")
+		case k8s.opts.labelSelector != nil || k8s.opts.namespaceLabelSelector != nil:
+			return nil, c.Errf("labels and namespace_labels cannot be used with watch_cache consume")
+		}
+	}
+
 	for _, multiclusterZone := range k8s.opts.multiclusterZones {
 		if !slices.Contains(k8s.Zones, multiclusterZone) {
 			fmt.Println(k8s.Zones)
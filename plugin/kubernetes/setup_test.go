@@ -4,6 +4,7 @@ import (
 	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/plugin/pkg/fall"
@@ -542,6 +543,67 @@ func TestKubernetesParseNoEndpoints(t *testing.T) {
 	}
 }
 
+func TestKubernetesParseTopology(t *testing.T) {
+	tests := []struct {
+		input              string // Corefile data as string
+		shouldErr          bool   // true if test case is expected to produce an error.
+		expectedErrContent string // substring from the expected error. Empty for positive cases.
+		expectedTopology   bool
+	}{
+		// valid
+		{
+			`kubernetes coredns.local {
+	topology
+}`,
+			false,
+			"",
+			true,
+		},
+		// invalid
+		{
+			`kubernetes coredns.local {
+	topology zone
+}`,
+			true,
+			"rong argument count or unexpected",
+			false,
+		},
+		// not set
+		{
+			`kubernetes coredns.local {
+}`,
+			false,
+			"",
+			false,
+		},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		k8sController, err := kubernetesParse(c)
+
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error, but did not find error for input '%s'. Error was: '%v'", i, test.input, err)
+		}
+
+		if err != nil {
+			if !test.shouldErr {
+				t.Errorf("Test %d: Expected no error but found one for input %s. Error was: %v", i, test.input, err)
+				continue
+			}
+
+			if !strings.Contains(err.Error(), test.expectedErrContent) {
+				t.Errorf("Test %d: Expected error to contain: %v, found error: %v, input: %s", i, test.expectedErrContent, err, test.input)
+			}
+			continue
+		}
+
+		if k8sController.opts.topology != test.expectedTopology {
+			t.Errorf("Test %d: Expected topology '%v', found '%v' for input '%s'", i, test.expectedTopology, k8sController.opts.topology, test.input)
+		}
+	}
+}
+
 func TestKubernetesParseIgnoreEmptyService(t *testing.T) {
 	tests := []struct {
 		input                 string // Corefile data as string
@@ -682,3 +744,96 @@ func TestKubernetesParseMulticluster(t *testing.T) {
 		}
 	}
 }
+
+func TestKubernetesParseAnnotations(t *testing.T) {
+	tests := []struct {
+		input               string // Corefile data as string
+		shouldErr           bool   // true if test case is expected to produce an error.
+		expectedAnnotations bool
+	}{
+		{`kubernetes coredns.local {
+	annotations
+}`, false, true},
+		{`kubernetes coredns.local {
+	annotations ttl
+}`, true, false},
+		{`kubernetes coredns.local`, false, false},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		k8sController, err := kubernetesParse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, but did not find error for input '%s'", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error but found one for input %s. Error was: %v", i, test.input, err)
+			continue
+		}
+		if k8sController.opts.annotations != test.expectedAnnotations {
+			t.Errorf("Test %d: Expected annotations '%v', found '%v' for input '%s'", i, test.expectedAnnotations, k8sController.opts.annotations, test.input)
+		}
+	}
+}
+
+func TestKubernetesParseWatchCache(t *testing.T) {
+	tests := []struct {
+		input              string // Corefile data as string
+		shouldErr          bool   // true if test case is expected to produce an error.
+		expectedErrContent string // substring from the expected error. Empty for positive cases.
+		expected           watchCacheOpts
+	}{
+		{`kubernetes coredns.local {
+	watch_cache publish /var/run/coredns/cache
+}`, false, "", watchCacheOpts{watchCachePublish, "/var/run/coredns/cache", defaultWatchCacheInterval}},
+		{`kubernetes coredns.local {
+	watch_cache consume /var/run/coredns/cache 5s
+}`, false, "", watchCacheOpts{watchCacheConsume, "/var/run/coredns/cache", 5 * time.Second}},
+		{`kubernetes coredns.local`, false, "", watchCacheOpts{}},
+		{`kubernetes coredns.local {
+	watch_cache share /var/run/coredns/cache
+}`, true, "must be publish or consume", watchCacheOpts{}},
+		{`kubernetes coredns.local {
+	watch_cache consume
+}`, true, "rong argument count or unexpected", watchCacheOpts{}},
+		{`kubernetes coredns.local {
+	watch_cache consume /var/run/coredns/cache 0s
+}`, true, "must be positive", watchCacheOpts{}},
+		{`kubernetes coredns.local {
+	watch_cache consume /var/run/coredns/cache
+	pods verified
+}`, true, "pods verified cannot be used", watchCacheOpts{}},
+		{`kubernetes coredns.local {
+	watch_cache consume /var/run/coredns/cache
+	topology
+}`, true, "topology cannot be used", watchCacheOpts{}},
+		{`kubernetes coredns.local {
+	watch_cache publish /var/run/coredns/cache
+	topology
+}`, false, "", watchCacheOpts{watchCachePublish, "/var/run/coredns/cache", defaultWatchCacheInterval}},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		k8sController, err := kubernetesParse(c)
+
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, but did not find error for input '%s'", i, test.input)
+			} else if !strings.Contains(err.Error(), test.expectedErrContent) {
+				t.Errorf("Test %d: Expected error to contain: %v, found error: %v, input: %s", i, test.expectedErrContent, err, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error but found one for input %s. Error was: %v", i, test.input, err)
+			continue
+		}
+		if k8sController.opts.watchCache != test.expected {
+			t.Errorf("Test %d: Expected watch cache '%+v', found '%+v' for input '%s'", i, test.expected, k8sController.opts.watchCache, test.input)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package kubernetes
+
+import (
+	"slices"
+
+	"github.com/coredns/coredns/plugin/kubernetes/object"
+
+	api "k8s.io/api/core/v1"
+)
+
+// clientZone returns the topology zone of the client, found via the node of the pod with the client's
+// IP address. It returns "" when the client isn't a pod or the zone of its node isn't known.
+func (k *Kubernetes) clientZone(ip string) string {
+	for _, p := range k.APIConn.PodIndex(ip) {
+		if p.NodeName == "" {
+			continue
+		}
+		if zone := k.APIConn.NodeZone(p.NodeName); zone != "" {
+			return zone
+		}
+	}
+	return ""
+}
+
+// topologyFilter returns the set of endpoint IPs of svc to give to a client in zone, following the
+// topology hints, or the traffic distribution of svc when there are no hints. Like kube-proxy, the
+// hints are only used when all endpoints have them. A nil set means no filtering: the client's zone
+// isn't known, the service doesn't ask for topology aware routing or no endpoint is in the zone.
+func topologyFilter(svc *object.Service, endpoints []*object.Endpoints, zone string) map[string]struct{} {
+	if zone == "" {
+		return nil
+	}
+
+	hints := true
+	var addrs []object.EndpointAddress
+	for _, ep := range endpoints {
+		if object.EndpointsKey(svc.Name, svc.Namespace) != ep.Index {
+			continue
+		}
+		for _, eps := range ep.Subsets {
+			for _, addr := range eps.Addresses {
+				if len(addr.ForZones) == 0 {
+					hints = false
+				}
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+
+	var inZone func(object.EndpointAddress) bool
+	switch {
+	case hints && len(addrs) > 0:
+		inZone = func(a object.EndpointAddress) bool { return slices.Contains(a.ForZones, zone) }
+	case svc.TrafficDistribution == api.ServiceTrafficDistributionPreferClose:
+		inZone = func(a object.EndpointAddress) bool { return a.Zone == zone }
+	default:
+		return nil
+	}
+
+	var ips map[string]struct{}
+	for _, a := range addrs {
+		if !inZone(a) {
+			continue
+		}
+		if ips == nil {
+			ips = map[string]struct{}{}
+		}
+		ips[a.IP] = struct{}{}
+	}
+	return ips
+}
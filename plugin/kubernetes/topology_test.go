@@ -0,0 +1,146 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/kubernetes/object"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	api "k8s.io/api/core/v1"
+)
+
+type APIConnTopologyTest struct{ APIConnServeTest }
+
+var topologySvcs = map[string][]*object.Service{
+	"hinted.testns": {{Name: "hinted", Namespace: "testns", ClusterIPs: []string{api.ClusterIPNone}}},
+	"close.testns": {{Name: "close", Namespace: "testns", ClusterIPs: []string{api.ClusterIPNone},
+		TrafficDistribution: api.ServiceTrafficDistributionPreferClose}},
+	"plain.testns": {{Name: "plain", Namespace: "testns", ClusterIPs: []string{api.ClusterIPNone}}},
+}
+
+func topologyEndpoints(name string, addrs ...object.EndpointAddress) []*object.Endpoints {
+	return []*object.Endpoints{{
+		Name: name, Namespace: "testns", Index: object.EndpointsKey(name, "testns"),
+		Subsets: []object.EndpointSubset{{Addresses: addrs, Ports: []object.EndpointPort{{Port: 80, Name: "http", Protocol: "TCP"}}}},
+	}}
+}
+
+var topologyEps = map[string][]*object.Endpoints{
+	"hinted.testns": topologyEndpoints("hinted",
+		object.EndpointAddress{IP: "10.0.0.1", Zone: "zone-a", ForZones: []string{"zone-a"}},
+		object.EndpointAddress{IP: "10.0.0.2", Zone: "zone-b", ForZones: []string{"zone-b"}},
+		object.EndpointAddress{IP: "10.0.0.3", Zone: "zone-c", ForZones: []string{"zone-a", "zone-c"}},
+	),
+	"close.testns": topologyEndpoints("close",
+		object.EndpointAddress{IP: "10.0.1.1", Zone: "zone-a"},
+		object.EndpointAddress{IP: "10.0.1.2", Zone: "zone-b"},
+	),
+	"plain.testns": topologyEndpoints("plain",
+		object.EndpointAddress{IP: "10.0.2.1", Zone: "zone-a"},
+		object.EndpointAddress{IP: "10.0.2.2", Zone: "zone-b"},
+	),
+}
+
+func (APIConnTopologyTest) SvcIndex(s string) []*object.Service  { return topologySvcs[s] }
+func (APIConnTopologyTest) EpIndex(s string) []*object.Endpoints { return topologyEps[s] }
+
+// The client, 10.240.0.1, runs on node-1 in zone-a.
+func (APIConnTopologyTest) PodIndex(ip string) []*object.Pod {
+	if ip != "10.240.0.1" {
+		return nil
+	}
+	return []*object.Pod{{Name: "client", Namespace: "testns", PodIP: ip, NodeName: "node-1"}}
+}
+
+func (APIConnTopologyTest) NodeZone(name string) string {
+	if name == "node-1" {
+		return "zone-a"
+	}
+	return ""
+}
+
+func TestServeDNSTopology(t *testing.T) {
+	tests := []struct {
+		qname    string
+		topology bool
+		answer   []dns.RR
+	}{
+		{"hinted.testns.svc.cluster.local.", true, []dns.RR{
+			test.A("hinted.testns.svc.cluster.local.	5	IN	A	10.0.0.1"),
+			test.A("hinted.testns.svc.cluster.local.	5	IN	A	10.0.0.3"),
+		}},
+		{"close.testns.svc.cluster.local.", true, []dns.RR{
+			test.A("close.testns.svc.cluster.local.	5	IN	A	10.0.1.1"),
+		}},
+		// No hints and no traffic distribution.
+		{"plain.testns.svc.cluster.local.", true, []dns.RR{
+			test.A("plain.testns.svc.cluster.local.	5	IN	A	10.0.2.1"),
+			test.A("plain.testns.svc.cluster.local.	5	IN	A	10.0.2.2"),
+		}},
+		// Topology disabled.
+		{"hinted.testns.svc.cluster.local.", false, []dns.RR{
+			test.A("hinted.testns.svc.cluster.local.	5	IN	A	10.0.0.1"),
+			test.A("hinted.testns.svc.cluster.local.	5	IN	A	10.0.0.2"),
+			test.A("hinted.testns.svc.cluster.local.	5	IN	A	10.0.0.3"),
+		}},
+		// Endpoint queries aren't filtered.
+		{"10-0-1-2.close.testns.svc.cluster.local.", true, []dns.RR{
+			test.A("10-0-1-2.close.testns.svc.cluster.local.	5	IN	A	10.0.1.2"),
+		}},
+	}
+
+	for i, tc := range tests {
+		k := New([]string{"cluster.local."})
+		k.APIConn = &APIConnTopologyTest{}
+		k.Next = test.NextHandler(dns.RcodeSuccess, nil)
+		k.opts.topology = tc.topology
+
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		r := test.Case{Qname: tc.qname, Qtype: dns.TypeA}.Msg()
+		if _, err := k.ServeDNS(context.TODO(), w, r); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		if err := test.SortAndCheck(w.Msg, test.Case{Qname: tc.qname, Qtype: dns.TypeA, Answer: tc.answer}); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+}
+
+func TestTopologyFilter(t *testing.T) {
+	svc := topologySvcs["close.testns"][0]
+	tests := []struct {
+		zone     string
+		eps      []*object.Endpoints
+		expected []string
+	}{
+		{"", topologyEps["close.testns"], nil},
+		{"zone-a", topologyEps["close.testns"], []string{"10.0.1.1"}},
+		// No endpoint in the zone of the client.
+		{"zone-z", topologyEps["close.testns"], nil},
+		// Hints are ignored when not all endpoints have them.
+		{"zone-b", topologyEndpoints("close",
+			object.EndpointAddress{IP: "10.0.1.1", Zone: "zone-a", ForZones: []string{"zone-a", "zone-b"}},
+			object.EndpointAddress{IP: "10.0.1.2", Zone: "zone-b"},
+		), []string{"10.0.1.2"}},
+		{"zone-b", topologyEndpoints("close",
+			object.EndpointAddress{IP: "10.0.1.1", Zone: "zone-a", ForZones: []string{"zone-a", "zone-b"}},
+			object.EndpointAddress{IP: "10.0.1.2", Zone: "zone-b", ForZones: []string{"zone-a"}},
+		), []string{"10.0.1.1"}},
+	}
+
+	for i, tc := range tests {
+		ips := topologyFilter(svc, tc.eps, tc.zone)
+		if len(ips) != len(tc.expected) {
+			t.Errorf("Test %d: expected %v, got %v", i, tc.expected, ips)
+			continue
+		}
+		for _, ip := range tc.expected {
+			if _, ok := ips[ip]; !ok {
+				t.Errorf("Test %d: expected %s in %v", i, ip, ips)
+			}
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package kubernetes
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/plugin/kubernetes/object"
+
+	api "k8s.io/api/core/v1"
+)
+
+// The watch cache file lets one instance, the publisher, share the services, endpoints and namespaces
+// it watches with other instances, the consumers. A consumer reads the file instead of watching the
+// Kubernetes API itself. The file holds a snapshot of the objects, which the publisher replaces
+// atomically, at most once per interval, when the objects have changed. When they haven't, the
+// publisher only updates the modification time of the file, so consumers can tell it is still alive.
+
+type watchCacheMode int
+
+const (
+	watchCacheNone watchCacheMode = iota
+	watchCachePublish
+	watchCacheConsume
+)
+
+const defaultWatchCacheInterval = time.Second
+
+// minWatchCacheMaxAge is the lower bound of the age after which a consumer considers the file stale,
+// which is 10 times the interval. File systems shared between nodes may cache the modification time.
+const minWatchCacheMaxAge = time.Minute
+
+// watchCacheOpts configures the watch cache file.
+type watchCacheOpts struct {
+	mode     watchCacheMode
+	path     string
+	interval time.Duration
+}
+
+var errNotInWatchCache = errors.New("not available in the watch cache")
+
+// watchCacheSnapshot is the content of the watch cache file.
+type watchCacheSnapshot struct {
+	// Written is when the snapshot was written, in nanoseconds. It must be the first field, consumers
+	// only read this to see if the file changed.
+	Written     int64
+	Modified    int64
+	ExtModified int64
+	Namespaces  []*object.Namespace
+	Services    []*object.Service
+	Endpoints   []*object.Endpoints
+}
+
+// cachePublisher writes the watch cache file for a dnsControl.
+type cachePublisher struct {
+	dns      *dnsControl
+	path     string
+	interval time.Duration
+
+	// modified is the modified timestamp in the last snapshot, written is when that was written.
+	modified int64
+	written  int64
+}
+
+// run writes the watch cache file every interval, when something changed, until stopCh is closed.
+func (p *cachePublisher) run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if !p.dns.HasSynced() {
+				continue
+			}
+			if err := p.publish(); err != nil {
+				log.Errorf("Failed to write watch cache file %q: %s", p.path, err)
+			}
+		}
+	}
+}
+
+// publish writes the snapshot when the objects have been modified since the last one. As the modified
+// timestamps have a resolution of a second, changes in the second the last snapshot was written in,
+// trigger a new one.
+func (p *cachePublisher) publish() error {
+	mod := max(p.dns.Modified(ModifiedInternal), p.dns.Modified(ModifiedExternal))
+	now := time.Now()
+	if p.written > 0 && mod == p.modified && mod < p.written {
+		// Nothing changed, let the consumers know we're still here.
+		return os.Chtimes(p.path, now, now)
+	}
+	s := &watchCacheSnapshot{
+		Written:     now.UnixNano(),
+		Modified:    p.dns.Modified(ModifiedInternal),
+		ExtModified: p.dns.Modified(ModifiedExternal),
+		Services:    p.dns.ServiceList(),
+		Endpoints:   p.dns.EndpointsList(),
+	}
+	for _, o := range p.dns.nsLister.List() {
+		if ns, ok := o.(*object.Namespace); ok {
+			s.Namespaces = append(s.Namespaces, ns)
+		}
+	}
+	if err := writeSnapshot(p.path, s); err != nil {
+		return err
+	}
+	p.modified, p.written = mod, now.Unix()
+	return nil
+}
+
+// writeSnapshot writes s to path. The file is replaced with a rename, so readers never see a partial file.
+func writeSnapshot(path string, s *watchCacheSnapshot) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	zw := gzip.NewWriter(f)
+	if err := json.NewEncoder(zw).Encode(s); err != nil {
+		f.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// readSnapshot reads the snapshot in path.
+func readSnapshot(path string) (*watchCacheSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	s := &watchCacheSnapshot{}
+	if err := json.NewDecoder(zr).Decode(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// snapshotWritten returns the Written field of the snapshot in path, without reading the rest of it.
+func snapshotWritten(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return 0, err
+	}
+	dec := json.NewDecoder(zr)
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return 0, errors.New("not a watch cache snapshot")
+	}
+	if t, err := dec.Token(); err != nil || t != "Written" {
+		return 0, errors.New("not a watch cache snapshot")
+	}
+	var written int64
+	err = dec.Decode(&written)
+	return written, err
+}
+
+// cacheControl is a dnsController that serves the objects from the watch cache file.
+type cacheControl struct {
+	path     string
+	interval time.Duration
+	maxAge   time.Duration // the file is stale when it hasn't been updated for this long.
+	now      func() time.Time
+
+	state atomic.Pointer[cacheState]
+	// touched is the modification time of the file, in nanoseconds, when it was last checked.
+	touched atomic.Int64
+	// failed is true when the last read of the file failed, stale when the file was stale, to only log
+	// the first time.
+	failed bool
+	stale  bool
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// cacheState holds a snapshot and the indexes on it. It isn't changed after it is created.
+type cacheState struct {
+	*watchCacheSnapshot
+
+	namespaces    map[string]*object.Namespace
+	svcIndex      map[string][]*object.Service
+	svcIPIndex    map[string][]*object.Service
+	svcExtIPIndex map[string][]*object.Service
+	epIndex       map[string][]*object.Endpoints
+	epIPIndex     map[string][]*object.Endpoints
+}
+
+func newCacheControl(path string, interval time.Duration) *cacheControl {
+	return &cacheControl{
+		path:     path,
+		interval: interval,
+		maxAge:   max(10*interval, minWatchCacheMaxAge),
+		now:      time.Now,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func newCacheState(s *watchCacheSnapshot) *cacheState {
+	c := &cacheState{
+		watchCacheSnapshot: s,
+		namespaces:         make(map[string]*object.Namespace, len(s.Namespaces)),
+		svcIndex:           make(map[string][]*object.Service, len(s.Services)),
+		svcIPIndex:         make(map[string][]*object.Service, len(s.Services)),
+		svcExtIPIndex:      make(map[string][]*object.Service),
+		epIndex:            make(map[string][]*object.Endpoints, len(s.Endpoints)),
+		epIPIndex:          make(map[string][]*object.Endpoints),
+	}
+	for _, ns := range s.Namespaces {
+		c.namespaces[ns.Name] = ns
+	}
+	for _, svc := range s.Services {
+		c.svcIndex[svc.Index] = append(c.svcIndex[svc.Index], svc)
+		for _, ip := range svc.ClusterIPs {
+			c.svcIPIndex[ip] = append(c.svcIPIndex[ip], svc)
+		}
+		for _, ip := range svc.ExternalIPs {
+			c.svcExtIPIndex[ip] = append(c.svcExtIPIndex[ip], svc)
+		}
+	}
+	for _, ep := range s.Endpoints {
+		c.epIndex[ep.Index] = append(c.epIndex[ep.Index], ep)
+		for _, ip := range ep.IndexIP {
+			c.epIPIndex[ip] = append(c.epIPIndex[ip], ep)
+		}
+	}
+	return c
+}
+
+// load reads the watch cache file when it holds another snapshot than the one last read.
+func (c *cacheControl) load() error {
+	fi, err := os.Stat(c.path)
+	if err != nil {
+		return err
+	}
+	c.touched.Store(fi.ModTime().UnixNano())
+
+	written, err := snapshotWritten(c.path)
+	if err != nil {
+		return err
+	}
+	if s := c.state.Load(); s != nil && s.Written == written {
+		return nil
+	}
+	s, err := readSnapshot(c.path)
+	if err != nil {
+		return err
+	}
+	c.state.Store(newCacheState(s))
+	return nil
+}
+
+// reload calls load and logs when it starts failing, or when the file becomes stale. A stale file is
+// also reported in the watch cache stale metric, the last snapshot is still served.
+func (c *cacheControl) reload() {
+	err := c.load()
+	if err != nil && !c.failed {
+		log.Warningf("Failed to read watch cache file %q: %s", c.path, err)
+	}
+	c.failed = err != nil
+
+	stale := c.isStale()
+	if stale && !c.stale {
+		log.Errorf("Watch cache file %q has not been updated for more than %s, the publisher may be down", c.path, c.maxAge)
+	}
+	if !stale && c.stale {
+		log.Infof("Watch cache file %q is updated again", c.path)
+	}
+	c.stale = stale
+	if stale {
+		watchCacheStale.WithLabelValues(c.path).Set(1)
+	} else {
+		watchCacheStale.WithLabelValues(c.path).Set(0)
+	}
+}
+
+// isStale returns true when the file hasn't been updated by the publisher for more than c.maxAge.
+func (c *cacheControl) isStale() bool {
+	return c.now().Sub(time.Unix(0, c.touched.Load())) > c.maxAge
+}
+
+// Run reads the watch cache file every interval, until Stop is called.
+func (c *cacheControl) Run() {
+	c.reload()
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+			c.reload()
+		}
+	}
+}
+
+// HasSynced returns true when the watch cache file has been read. A stale file doesn't change this,
+// the records of the last snapshot are better than none.
+func (c *cacheControl) HasSynced() bool { return c.state.Load() != nil }
+
+// Stop stops the controller.
+func (c *cacheControl) Stop() error {
+	err := fmt.Errorf("shutdown already in progress")
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+		err = nil
+	})
+	return err
+}
+
+// get returns the current state, which is empty when the file hasn't been read yet.
+func (c *cacheControl) get() *cacheState {
+	if s := c.state.Load(); s != nil {
+		return s
+	}
+	return &cacheState{watchCacheSnapshot: &watchCacheSnapshot{}}
+}
+
+func (c *cacheControl) ServiceList() []*object.Service     { return c.get().Services }
+func (c *cacheControl) EndpointsList() []*object.Endpoints { return c.get().Endpoints }
+func (c *cacheControl) SvcIndex(idx string) []*object.Service {
+	return c.get().svcIndex[idx]
+}
+func (c *cacheControl) SvcIndexReverse(ip string) []*object.Service {
+	return c.get().svcIPIndex[ip]
+}
+func (c *cacheControl) SvcExtIndexReverse(ip string) []*object.Service {
+	return c.get().svcExtIPIndex[ip]
+}
+func (c *cacheControl) EpIndex(idx string) []*object.Endpoints {
+	return c.get().epIndex[idx]
+}
+func (c *cacheControl) EpIndexReverse(ip string) []*object.Endpoints {
+	return c.get().epIPIndex[ip]
+}
+
+// GetNamespaceByName returns the namespace by name. If nothing is found an error is returned.
+func (c *cacheControl) GetNamespaceByName(name string) (*object.Namespace, error) {
+	ns, ok := c.get().namespaces[name]
+	if !ok {
+		return nil, fmt.Errorf("namespace not found")
+	}
+	return ns, nil
+}
+
+// Modified returns the modified timestamps of the snapshot, so all consumers have the same serial.
+func (c *cacheControl) Modified(mode ModifiedMode) int64 {
+	switch mode {
+	case ModifiedInternal:
+		return c.get().Modified
+	case ModifiedExternal:
+		return c.get().ExtModified
+	case ModifiedMultiCluster:
+		return 0
+	}
+	return -1
+}
+
+// Pods, nodes, multicluster services and hostnames aren't in the watch cache.
+
+func (c *cacheControl) ServiceImportList() []*object.ServiceImport       { return nil }
+func (c *cacheControl) SvcImportIndex(string) []*object.ServiceImport    { return nil }
+func (c *cacheControl) McEpIndex(string) []*object.MultiClusterEndpoints { return nil }
+func (c *cacheControl) PodIndex(string) []*object.Pod                    { return nil }
+func (c *cacheControl) NodeZone(string) string                           { return "" }
+func (c *cacheControl) HostnameIndex(string) []HostAddresses             { return nil }
+func (c *cacheControl) WatchHostnames(context.Context, bool, bool) error { return errNotInWatchCache }
+func (c *cacheControl) GetNodeByName(context.Context, string) (*api.Node, error) {
+	return nil, errNotInWatchCache
+}
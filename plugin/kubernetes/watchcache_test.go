@@ -0,0 +1,163 @@
+package kubernetes
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWatchCache(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.json.gz")
+
+	pub := kubernetesWithFakeClient(ctx, "10.0.0.0/30", true, "all")
+	ctrl := pub.APIConn.(*dnsControl)
+	ctrl.publisher = &cachePublisher{dns: ctrl, path: path, interval: 10 * time.Millisecond}
+
+	go pub.APIConn.Run()
+	defer pub.APIConn.Stop()
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	pub.Zones = append(pub.Zones, "in-addr.arpa.")
+	con := New([]string{"cluster.local.", "in-addr.arpa."})
+	con.APIConn = newCacheControl(path, 10*time.Millisecond)
+	go con.APIConn.Run()
+	defer con.APIConn.Stop()
+	for !con.APIConn.HasSynced() {
+		time.Sleep(time.Millisecond)
+	}
+
+	if con.APIConn.Modified(ModifiedInternal) != pub.APIConn.Modified(ModifiedInternal) {
+		t.Errorf("Expected modified %d, got %d", pub.APIConn.Modified(ModifiedInternal), con.APIConn.Modified(ModifiedInternal))
+	}
+
+	tests := []test.Case{
+		{Qname: "svc1.testns.svc.cluster.local.", Qtype: dns.TypeA},
+		{Qname: "svc3.testns.svc.cluster.local.", Qtype: dns.TypeA},
+		{Qname: "svc4.testns.svc.cluster.local.", Qtype: dns.TypeSRV},
+		{Qname: "2.0.0.10.in-addr.arpa.", Qtype: dns.TypePTR},
+		{Qname: "nosvc.testns.svc.cluster.local.", Qtype: dns.TypeA},
+		{Qname: "svc1.nons.svc.cluster.local.", Qtype: dns.TypeA},
+	}
+	for i, tc := range tests {
+		expected := dnstest.NewRecorder(&test.ResponseWriter{})
+		pub.ServeDNS(ctx, expected, tc.Msg())
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		con.ServeDNS(ctx, w, tc.Msg())
+
+		if w.Msg.Rcode != expected.Msg.Rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[expected.Msg.Rcode], dns.RcodeToString[w.Msg.Rcode])
+		}
+		tc.Rcode, tc.Answer, tc.Ns, tc.Extra = expected.Msg.Rcode, expected.Msg.Answer, expected.Msg.Ns, expected.Msg.Extra
+		if err := test.SortAndCheck(w.Msg, tc); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+
+	if err := con.WatchHostnames(true, false); err != errNotInWatchCache {
+		t.Errorf("Expected error %q, got %v", errNotInWatchCache, err)
+	}
+
+	// Without changes the publisher still updates the modification time of the file.
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.ModTime().After(old.Add(time.Minute)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the publisher to update the modification time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchCacheReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json.gz")
+	c := newCacheControl(path, time.Hour)
+
+	if err := c.load(); err == nil {
+		t.Fatal("Expected error for a missing file")
+	}
+	if c.HasSynced() {
+		t.Fatal("Expected not synced without a file")
+	}
+
+	if err := writeSnapshot(path, &watchCacheSnapshot{Written: 1, Modified: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.HasSynced() || c.Modified(ModifiedInternal) != 1 {
+		t.Fatalf("Expected modified 1, got %d", c.Modified(ModifiedInternal))
+	}
+
+	// A new snapshot is read when the file changes.
+	if err := writeSnapshot(path, &watchCacheSnapshot{Written: 2, Modified: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Modified(ModifiedInternal) != 2 {
+		t.Errorf("Expected modified 2, got %d", c.Modified(ModifiedInternal))
+	}
+
+	// The same snapshot isn't read again, even when the modification time changes.
+	if err := writeSnapshot(path, &watchCacheSnapshot{Written: 2, Modified: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Modified(ModifiedInternal) != 2 {
+		t.Errorf("Expected modified 2, got %d", c.Modified(ModifiedInternal))
+	}
+
+	// The file is stale when the publisher hasn't updated it for too long, the last snapshot is still served.
+	c.now = func() time.Time { return time.Now().Add(c.maxAge + time.Second) }
+	c.reload()
+	if !c.HasSynced() {
+		t.Error("Expected synced with a stale file")
+	}
+	if x := testutil.ToFloat64(watchCacheStale.WithLabelValues(path)); x != 1 {
+		t.Errorf("Expected stale metric %v, got %v", 1, x)
+	}
+	c.now = time.Now
+	c.reload()
+	if x := testutil.ToFloat64(watchCacheStale.WithLabelValues(path)); x != 0 {
+		t.Errorf("Expected stale metric %v, got %v", 0, x)
+	}
+
+	// A broken file keeps the last snapshot.
+	if err := os.WriteFile(path, []byte("not a snapshot"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.load(); err == nil {
+		t.Error("Expected error for a broken file")
+	}
+	if c.Modified(ModifiedInternal) != 2 {
+		t.Errorf("Expected modified 2, got %d", c.Modified(ModifiedInternal))
+	}
+}
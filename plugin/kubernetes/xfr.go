@@ -72,6 +72,11 @@ func (k *Kubernetes) transferServices(ch chan []dns.RR, zonePath string) {
 		if !k.namespaceExposed(svc.Namespace) {
 			continue
 		}
+		policy := k.policy(svc)
+		if policy.IsHidden() {
+			continue
+		}
+		ttl := policy.TTLOr(k.ttl)
 		svcBase := []string{zonePath, Svc, svc.Namespace, svc.Name}
 		switch svc.Type {
 		case api.ServiceTypeClusterIP, api.ServiceTypeNodePort, api.ServiceTypeLoadBalancer:
@@ -79,7 +84,7 @@ func (k *Kubernetes) transferServices(ch chan []dns.RR, zonePath string) {
 			if clusterIP != nil {
 				var host string
 				for _, ip := range svc.ClusterIPs {
-					s := msg.Service{Host: ip, TTL: k.ttl}
+					s := msg.Service{Host: ip, TTL: ttl}
 					s.Key = strings.Join(svcBase, "/")
 
 					// Change host from IP to Name for SRV records
@@ -87,7 +92,10 @@ func (k *Kubernetes) transferServices(ch chan []dns.RR, zonePath string) {
 				}
 
 				for _, p := range svc.Ports {
-					s := msg.Service{Host: host, Port: int(p.Port), TTL: k.ttl}
+					if !policy.SRVPort(p.Name, p.Port) {
+						continue
+					}
+					s := msg.Service{Host: host, Port: int(p.Port), TTL: ttl}
 					s.Key = strings.Join(svcBase, "/")
 
 					// Need to generate this to handle use cases for peer-finder
@@ -115,7 +123,7 @@ func (k *Kubernetes) transferServices(ch chan []dns.RR, zonePath string) {
 				for _, eps := range ep.Subsets {
 					srvWeight := calcSRVWeight(len(eps.Addresses))
 					for _, addr := range eps.Addresses {
-						s := msg.Service{Host: addr.IP, TTL: k.ttl}
+						s := msg.Service{Host: addr.IP, TTL: ttl}
 						s.Key = strings.Join(svcBase, "/")
 						// We don't need to change the msg.Service host from IP to Name yet
 						// so disregard the return value here
@@ -129,7 +137,7 @@ func (k *Kubernetes) transferServices(ch chan []dns.RR, zonePath string) {
 						for _, p := range eps.Ports {
 							// As per spec unnamed ports do not have a srv record
 							// https://github.com/kubernetes/dns/blob/master/docs/specification.md#232---srv-records
-							if p.Name == "" {
+							if p.Name == "" || !policy.SRVPort(p.Name, p.Port) {
 								continue
 							}
 
@@ -144,7 +152,7 @@ func (k *Kubernetes) transferServices(ch chan []dns.RR, zonePath string) {
 
 		case api.ServiceTypeExternalName:
 
-			s := msg.Service{Key: strings.Join(svcBase, "/"), Host: svc.ExternalName, TTL: k.ttl}
+			s := msg.Service{Key: strings.Join(svcBase, "/"), Host: svc.ExternalName, TTL: ttl}
 			if t, _ := s.HostType(); t == dns.TypeCNAME {
 				ch <- []dns.RR{s.NewCNAME(msg.Domain(s.Key), s.Host)}
 			}
@@ -44,9 +44,12 @@ func (l Logger) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 			class := response.Classify(tpe)
 			_, ok1 = rule.Class[class]
 		}
-		if ok || ok1 {
-			logstr := l.repl.Replace(ctx, state, rrw, rule.Format)
-			clog.Info(logstr)
+		if (ok || ok1) && rule.log(rrw) {
+			if rule.Format == JSONLogFormat {
+				clog.Info(l.repl.JSON(ctx, state, rrw))
+			} else {
+				clog.Info(l.repl.Replace(ctx, state, rrw, rule.Format))
+			}
 		}
 
 		return rc, err
@@ -62,6 +65,39 @@ type Rule struct {
 	NameScope string
 	Class     map[response.Class]struct{}
 	Format    string
+	Slow      time.Duration // only log queries that take at least this long, 0 logs all queries.
+
+	sampler *sampler // may be nil.
+	limiter *limiter // may be nil.
+}
+
+// log returns true if the query recorded in rrw should be logged, after applying the slow query
+// threshold, sampling and rate limit of the rule. Entries suppressed by the rate limit are reported
+// when the next entry is logged, or else by the limiter's run loop, once the next second starts.
+func (r Rule) log(rrw *dnstest.Recorder) bool {
+	if r.Slow > 0 && time.Since(rrw.Start) < r.Slow {
+		return false
+	}
+	if r.sampler != nil && !r.sampler.sample() {
+		return false
+	}
+	if r.limiter == nil {
+		return true
+	}
+	ok, suppressed := r.limiter.allow()
+	if suppressed > 0 {
+		r.reportSuppressed(suppressed)
+	}
+	return ok
+}
+
+// reportSuppressed logs that n entries were suppressed by the rate limit, in the format of the rule.
+func (r Rule) reportSuppressed(n int) {
+	if r.Format == JSONLogFormat {
+		clog.Infof(`{"suppressed":%d}`, n)
+	} else {
+		clog.Infof("Suppressed %d log lines in the last second", n)
+	}
 }
 
 const (
@@ -69,6 +105,8 @@ const (
 	CommonLogFormat = `{remote}:{port} ` + replacer.EmptyValue + ` {>id} "{type} {class} {name} {proto} {size} {>do} {>bufsize}" {rcode} {>rflags} {rsize} {duration}`
 	// CombinedLogFormat is the combined log format.
 	CombinedLogFormat = CommonLogFormat + ` "{>opcode}"`
+	// JSONLogFormat logs all values and metadata as a JSON object, see replacer.JSON.
+	JSONLogFormat = `{json}`
 	// DefaultLogFormat is the default log format.
 	DefaultLogFormat = CommonLogFormat
 )
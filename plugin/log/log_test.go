@@ -3,6 +3,7 @@ package log
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"log"
 	"strings"
@@ -278,3 +279,37 @@ func BenchmarkLogged(b *testing.B) {
 		logger.ServeDNS(ctx, rec, r)
 	}
 }
+
+func TestLoggedJSON(t *testing.T) {
+	rule := Rule{
+		NameScope: ".",
+		Format:    JSONLogFormat,
+		Class:     map[response.Class]struct{}{response.All: {}},
+	}
+
+	var f bytes.Buffer
+	log.SetOutput(&f)
+
+	logger := Logger{
+		Rules: []Rule{rule},
+		Next:  test.ErrorHandler(),
+		repl:  replacer.New(),
+	}
+
+	r := new(dns.Msg)
+	r.SetQuestion("example.org.", dns.TypeA)
+	logger.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), r)
+
+	logged := f.String()
+	i := strings.IndexByte(logged, '{')
+	if i < 0 {
+		t.Fatalf("Expected a JSON object to be logged. Logged string: %s", logged)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(logged[i:]), &entry); err != nil {
+		t.Fatalf("Expected valid JSON, got %s. Logged string: %s", err, logged)
+	}
+	if entry["name"] != "example.org." || entry["rcode"] != "SERVFAIL" || entry["size"] != float64(29) {
+		t.Errorf("Expected name, rcode and size to be logged. Logged string: %s", logged)
+	}
+}
@@ -1,7 +1,9 @@
 package log
 
 import (
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
@@ -20,6 +22,24 @@ func setup(c *caddy.Controller) error {
 		return plugin.Error("log", err)
 	}
 
+	// Report suppressed entries when no entry is logged after them. Rules of the same block share a limiter.
+	stop := make(chan struct{})
+	c.OnStartup(func() error {
+		started := map[*limiter]struct{}{}
+		for _, r := range rules {
+			if _, ok := started[r.limiter]; r.limiter == nil || ok {
+				continue
+			}
+			started[r.limiter] = struct{}{}
+			go r.limiter.run(stop, r.reportSuppressed)
+		}
+		return nil
+	})
+	c.OnShutdown(func() error {
+		close(stop)
+		return nil
+	})
+
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		return Logger{Next: next, Rules: rules, repl: replacer.New()}
 	})
@@ -68,8 +88,13 @@ func logParse(c *caddy.Controller) ([]Rule, error) {
 			}
 		}
 
-		// Class refinements in an extra block.
+		// Class refinements, sampling and limits in an extra block.
 		classes := make(map[response.Class]struct{})
+		var (
+			slow    time.Duration
+			sampler *sampler
+			limiter *limiter
+		)
 		for c.NextBlock() {
 			switch c.Val() {
 			// class followed by combinations of all, denial, error and success.
@@ -85,6 +110,33 @@ func logParse(c *caddy.Controller) ([]Rule, error) {
 					}
 					classes[cls] = struct{}{}
 				}
+			case "sample":
+				n, err := positiveArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if n > 1 {
+					sampler = newSampler(uint64(n))
+				}
+			case "lines-per-second":
+				n, err := positiveArg(c)
+				if err != nil {
+					return nil, err
+				}
+				limiter = newLimiter(n)
+			case "slow":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, c.Errf("invalid duration %q", args[0])
+				}
+				if d <= 0 {
+					return nil, c.Errf("duration must be positive: %s", d)
+				}
+				slow = d
 			default:
 				return nil, c.ArgErr()
 			}
@@ -95,8 +147,24 @@ func logParse(c *caddy.Controller) ([]Rule, error) {
 
 		for i := len(rules) - 1; i >= length; i-- {
 			rules[i].Class = classes
+			rules[i].Slow = slow
+			rules[i].sampler = sampler
+			rules[i].limiter = limiter
 		}
 	}
 
 	return rules, nil
 }
+
+// positiveArg returns the single positive integer argument of the current property.
+func positiveArg(c *caddy.Controller) (int, error) {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return 0, c.Errf("invalid number %q, must be a positive integer", args[0])
+	}
+	return n, nil
+}
@@ -3,6 +3,7 @@ package log
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/plugin/pkg/response"
@@ -34,6 +35,11 @@ func TestLogParse(t *testing.T) {
 			Format:    CombinedLogFormat,
 			Class:     map[response.Class]struct{}{response.All: {}},
 		}}},
+		{`log . {json}`, false, []Rule{{
+			NameScope: ".",
+			Format:    JSONLogFormat,
+			Class:     map[response.Class]struct{}{response.All: {}},
+		}}},
 		{`log example.org.
 		log example.net {combined}`, false, []Rule{{
 			NameScope: "example.org.",
@@ -182,3 +188,47 @@ func TestLogParse(t *testing.T) {
 		}
 	}
 }
+
+func TestLogParseThrottle(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		slow      time.Duration
+		sample    uint64
+		rate      int
+	}{
+		{"log", false, 0, 0, 0},
+		{"log {\nslow 100ms\n}", false, 100 * time.Millisecond, 0, 0},
+		{"log {\nsample 10\n}", false, 0, 10, 0},
+		{"log {\nsample 1\n}", false, 0, 0, 0},
+		{"log {\nlines-per-second 100\n}", false, 0, 0, 100},
+		{"log . {json} {\nclass error\nslow 1s\nsample 2\nlines-per-second 5\n}", false, time.Second, 2, 5},
+		{"log {\nslow\n}", true, 0, 0, 0},
+		{"log {\nslow 0s\n}", true, 0, 0, 0},
+		{"log {\nslow fast\n}", true, 0, 0, 0},
+		{"log {\nsample 0\n}", true, 0, 0, 0},
+		{"log {\nsample one\n}", true, 0, 0, 0},
+		{"log {\nlines-per-second\n}", true, 0, 0, 0},
+		{"log {\nlines-per-second -1\n}", true, 0, 0, 0},
+	}
+	for i, tc := range tests {
+		rules, err := logParse(caddy.NewTestController("dns", tc.input))
+		if (err != nil) != tc.shouldErr {
+			t.Errorf("Test %d: expected error to be %t, got %v", i, tc.shouldErr, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		r := rules[0]
+		if r.Slow != tc.slow {
+			t.Errorf("Test %d: expected slow %s, got %s", i, tc.slow, r.Slow)
+		}
+		if (r.sampler == nil && tc.sample != 0) || (r.sampler != nil && r.sampler.n != tc.sample) {
+			t.Errorf("Test %d: expected sample %d, got %v", i, tc.sample, r.sampler)
+		}
+		if (r.limiter == nil && tc.rate != 0) || (r.limiter != nil && r.limiter.rate != tc.rate) {
+			t.Errorf("Test %d: expected lines-per-second %d, got %v", i, tc.rate, r.limiter)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sampler selects 1 in n entries.
+type sampler struct {
+	n     uint64
+	count atomic.Uint64
+}
+
+func newSampler(n uint64) *sampler { return &sampler{n: n} }
+
+// sample returns true if the entry should be logged.
+func (s *sampler) sample() bool {
+	return (s.count.Add(1)-1)%s.n == 0
+}
+
+// limiter allows up to rate entries per second.
+type limiter struct {
+	rate int
+
+	sync.Mutex
+	start      time.Time // start of the current second.
+	count      int       // entries logged in the current second.
+	suppressed int       // entries suppressed in the current second.
+	now        func() time.Time
+}
+
+func newLimiter(rate int) *limiter { return &limiter{rate: rate, now: time.Now} }
+
+// allow returns true if the entry may be logged. When a new second has started, it also returns the
+// number of entries that were suppressed in the previous second, so these can be reported.
+func (l *limiter) allow() (bool, int) {
+	l.Lock()
+	defer l.Unlock()
+
+	suppressed := l.roll()
+	if l.count >= l.rate {
+		l.suppressed++
+		return false, suppressed
+	}
+	l.count++
+	return true, suppressed
+}
+
+// flush starts a new second when the current one is over and returns the number of entries that were
+// suppressed in it. This reports the suppressed entries when no entry is logged after them.
+func (l *limiter) flush() int {
+	l.Lock()
+	defer l.Unlock()
+	return l.roll()
+}
+
+// roll starts a new second when the current one is over, and returns the number of suppressed entries
+// of the second that ended. The caller must hold the lock.
+func (l *limiter) roll() int {
+	now := l.now()
+	if now.Sub(l.start) < time.Second {
+		return 0
+	}
+	suppressed := l.suppressed
+	l.start = now
+	l.count = 0
+	l.suppressed = 0
+	return suppressed
+}
+
+// run calls flush every second and reports the suppressed entries with report, until stop is closed.
+func (l *limiter) run(stop <-chan struct{}, report func(int)) {
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+			if n := l.flush(); n > 0 {
+				report(n)
+			}
+		}
+	}
+}
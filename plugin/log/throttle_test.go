@@ -0,0 +1,132 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/replacer"
+	"github.com/coredns/coredns/plugin/pkg/response"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestSampler(t *testing.T) {
+	s := newSampler(3)
+	sampled := 0
+	for i := range 9 {
+		if s.sample() {
+			if i%3 != 0 {
+				t.Errorf("Expected entry %d not to be sampled", i)
+			}
+			sampled++
+		}
+	}
+	if sampled != 3 {
+		t.Errorf("Expected 3 sampled entries, got %d", sampled)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newLimiter(2)
+	l.now = func() time.Time { return now }
+
+	tests := []struct {
+		advance    time.Duration
+		ok         bool
+		suppressed int
+	}{
+		{0, true, 0},
+		{100 * time.Millisecond, true, 0},
+		{100 * time.Millisecond, false, 0},
+		{100 * time.Millisecond, false, 0},
+		{time.Second, true, 2},
+		{0, true, 0},
+		{0, false, 0},
+		{2 * time.Second, true, 1},
+	}
+	for i, tc := range tests {
+		now = now.Add(tc.advance)
+		ok, suppressed := l.allow()
+		if ok != tc.ok {
+			t.Errorf("Test %d: expected allowed to be %t, got %t", i, tc.ok, ok)
+		}
+		if suppressed != tc.suppressed {
+			t.Errorf("Test %d: expected %d suppressed, got %d", i, tc.suppressed, suppressed)
+		}
+	}
+}
+
+func TestLimiterFlush(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newLimiter(1)
+	l.now = func() time.Time { return now }
+
+	for range 3 {
+		l.allow()
+	}
+	if n := l.flush(); n != 0 {
+		t.Errorf("Expected %d suppressed before the second is over, got %d", 0, n)
+	}
+	now = now.Add(time.Second)
+	if n := l.flush(); n != 2 {
+		t.Errorf("Expected %d suppressed, got %d", 2, n)
+	}
+	// The suppressed entries are only reported once.
+	now = now.Add(time.Second)
+	if n := l.flush(); n != 0 {
+		t.Errorf("Expected %d suppressed, got %d", 0, n)
+	}
+	if ok, n := l.allow(); !ok || n != 0 {
+		t.Errorf("Expected allowed with %d suppressed, got %t and %d", 0, ok, n)
+	}
+}
+
+func TestLoggedThrottled(t *testing.T) {
+	slow := func(d time.Duration) test.HandlerFunc {
+		return func(_ context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			time.Sleep(d)
+			m := new(dns.Msg)
+			m.SetReply(r)
+			w.WriteMsg(m)
+			return dns.RcodeSuccess, nil
+		}
+	}
+
+	tests := []struct {
+		rule   Rule
+		next   test.HandlerFunc
+		logged int
+	}{
+		{Rule{Slow: 20 * time.Millisecond}, slow(0), 0},
+		{Rule{Slow: 20 * time.Millisecond}, slow(25 * time.Millisecond), 3},
+		{Rule{sampler: newSampler(2)}, slow(0), 2},
+		{Rule{limiter: newLimiter(1)}, slow(0), 1},
+	}
+
+	for i, tc := range tests {
+		var f bytes.Buffer
+		log.SetOutput(&f)
+
+		tc.rule.NameScope = "."
+		tc.rule.Format = "{name}"
+		tc.rule.Class = map[response.Class]struct{}{response.All: {}}
+		logger := Logger{Rules: []Rule{tc.rule}, Next: tc.next, repl: replacer.New()}
+
+		r := new(dns.Msg)
+		r.SetQuestion("example.org.", dns.TypeA)
+		for range 3 {
+			logger.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), r)
+		}
+
+		if logged := strings.Count(f.String(), "example.org."); logged != tc.logged {
+			t.Errorf("Test %d: expected %d entries to be logged, got %d", i, tc.logged, logged)
+		}
+	}
+}
@@ -29,6 +29,9 @@ type Metrics struct {
 	mux *http.ServeMux
 	srv *http.Server
 
+	otlp      *otlp // may be nil.
+	otlpSetup bool
+
 	zoneNames []string
 	zoneMap   map[string]struct{}
 	zoneMu    sync.RWMutex
@@ -133,8 +136,11 @@ func (m *Metrics) stopServer() error {
 	return nil
 }
 
-// OnFinalShutdown tears down the metrics listener on shutdown and restart.
-func (m *Metrics) OnFinalShutdown() error { return m.stopServer() }
+// OnFinalShutdown tears down the metrics listener and OTLP exporter on shutdown and restart.
+func (m *Metrics) OnFinalShutdown() error {
+	m.stopOTLP()
+	return m.stopServer()
+}
 
 func keys(m map[string]struct{}) []string {
 	sx := []string{}
@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/coremain"
+
+	"github.com/prometheus/client_golang/prometheus"
+	otelprom "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+const (
+	otlpGRPC = "grpc"
+	otlpHTTP = "http"
+
+	defaultOTLPInterval = 60 * time.Second
+)
+
+// otlp pushes the metrics gathered from a registry to an OpenTelemetry collector.
+type otlp struct {
+	protocol string // otlpGRPC or otlpHTTP.
+	endpoint string // HOST:PORT or a URL.
+	insecure bool
+	interval time.Duration
+	headers  map[string]string
+	attrs    map[string]string // resource attributes, on top of the defaults.
+
+	servers map[string]struct{} // servers and zones of the Server Blocks pushing to this collector.
+	zones   map[string]struct{}
+
+	provider *sdkmetric.MeterProvider
+}
+
+func newOTLP(protocol, endpoint string) *otlp {
+	return &otlp{
+		protocol: protocol,
+		endpoint: endpoint,
+		interval: defaultOTLPInterval,
+		headers:  map[string]string{},
+		attrs:    map[string]string{},
+		servers:  map[string]struct{}{},
+		zones:    map[string]struct{}{},
+	}
+}
+
+// otlps holds the exporters of the instance being set up, so Server Blocks that push to the same
+// collector share an exporter, and the resource lists the servers and zones of all of them.
+var otlps = struct {
+	sync.Mutex
+	ctx caddy.Context
+	m   map[string]*otlp
+}{}
+
+// sharedOTLP returns the exporter for the collector of o in the instance with context ctx. This is o
+// when it's the first one; the configuration of the first one is used.
+func sharedOTLP(ctx caddy.Context, o *otlp) *otlp {
+	otlps.Lock()
+	defer otlps.Unlock()
+	if otlps.ctx != ctx {
+		otlps.ctx, otlps.m = ctx, map[string]*otlp{}
+	}
+	if s, ok := otlps.m[o.key()]; ok {
+		return s
+	}
+	otlps.m[o.key()] = o
+	return o
+}
+
+// addServer adds server and the zones it serves to the resource attributes.
+func (o *otlp) addServer(server string, zones []string) {
+	o.servers[server] = struct{}{}
+	for _, z := range zones {
+		o.zones[z] = struct{}{}
+	}
+}
+
+// key returns the key that identifies this exporter, a collector only gets the metrics once.
+func (o *otlp) key() string { return "otlp+" + o.protocol + "://" + o.endpoint }
+
+// start starts pushing the metrics from g every interval.
+func (o *otlp) start(g prometheus.Gatherer) error {
+	exp, err := o.exporter()
+	if err != nil {
+		return err
+	}
+	reader := sdkmetric.NewPeriodicReader(exp,
+		sdkmetric.WithInterval(o.interval),
+		sdkmetric.WithProducer(otelprom.NewMetricProducer(otelprom.WithGatherer(g))),
+	)
+	o.provider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(o.resource()))
+	return nil
+}
+
+// stop pushes the metrics one last time and stops the exporter.
+func (o *otlp) stop() error {
+	if o.provider == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := o.provider.Shutdown(ctx)
+	o.provider = nil
+	return err
+}
+
+func (o *otlp) exporter() (sdkmetric.Exporter, error) {
+	url := strings.Contains(o.endpoint, "://")
+	if o.protocol == otlpHTTP {
+		opts := []otlpmetrichttp.Option{otlpmetrichttp.WithHeaders(o.headers)}
+		if url {
+			opts = append(opts, otlpmetrichttp.WithEndpointURL(o.endpoint))
+		} else {
+			opts = append(opts, otlpmetrichttp.WithEndpoint(o.endpoint))
+		}
+		if o.insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		return otlpmetrichttp.New(context.Background(), opts...)
+	}
+
+	opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithHeaders(o.headers)}
+	if url {
+		opts = append(opts, otlpmetricgrpc.WithEndpointURL(o.endpoint))
+	} else {
+		opts = append(opts, otlpmetricgrpc.WithEndpoint(o.endpoint))
+	}
+	if o.insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	return otlpmetricgrpc.New(context.Background(), opts...)
+}
+
+// resource returns the resource the metrics are pushed for. The attributes configured override the
+// defaults.
+func (o *otlp) resource() *resource.Resource {
+	kvs := []attribute.KeyValue{}
+	for k, set := range map[string]map[string]struct{}{"coredns.server": o.servers, "coredns.zone": o.zones} {
+		if _, ok := o.attrs[k]; ok || len(set) == 0 {
+			continue
+		}
+		vals := keys(set)
+		sort.Strings(vals)
+		kvs = append(kvs, attribute.StringSlice(k, vals))
+	}
+
+	attrs := map[string]string{
+		"service.name":    "coredns",
+		"service.version": coremain.CoreVersion,
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		attrs["host.name"] = hostname
+	}
+	for k, v := range o.attrs {
+		attrs[k] = v
+	}
+
+	for k, v := range attrs {
+		kvs = append(kvs, attribute.String(k, v))
+	}
+	return resource.NewSchemaless(kvs...)
+}
+
+// startOTLP starts the OTLP exporter of m.
+func (m *Metrics) startOTLP() error {
+	if err := m.otlp.start(m.Reg); err != nil {
+		log.Errorf("Failed to start OTLP metrics exporter: %s", err)
+		return err
+	}
+	m.otlpSetup = true
+	return nil
+}
+
+// stopOTLP stops the OTLP exporter of m, if it was started by m.
+func (m *Metrics) stopOTLP() error {
+	if !m.otlpSetup {
+		return nil
+	}
+	u.Unset(m.otlp.key())
+	m.otlpSetup = false
+	if err := m.otlp.stop(); err != nil {
+		log.Infof("Failed to stop OTLP metrics exporter: %s", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestOTLPExport(t *testing.T) {
+	received := make(chan []byte, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" {
+			t.Errorf("Expected metrics to be pushed to /v1/metrics, got %s", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+	}))
+	defer srv.Close()
+
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "coredns_test_total", Help: "Test counter."})
+	reg.MustRegister(counter)
+	counter.Inc()
+
+	o := newOTLP(otlpHTTP, srv.URL+"/v1/metrics")
+	o.interval = 10 * time.Millisecond
+	o.attrs["zone"] = "example.org."
+	if err := o.start(reg); err != nil {
+		t.Fatal(err)
+	}
+	defer o.stop()
+
+	select {
+	case body := <-received:
+		// The request is a protobuf, check the names show up in there.
+		for _, s := range []string{"coredns_test_total", "service.name", "coredns", "zone", "example.org."} {
+			if !strings.Contains(string(body), s) {
+				t.Errorf("Expected %q to be pushed", s)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected metrics to be pushed")
+	}
+}
+
+func TestOTLPShared(t *testing.T) {
+	ctx := caddy.NewTestController("dns", "").Context()
+	a := sharedOTLP(ctx, newOTLP(otlpGRPC, "a:1"))
+	a.addServer("dns://:53", []string{"example.org."})
+	b := sharedOTLP(ctx, newOTLP(otlpGRPC, "a:1"))
+	if b != a {
+		t.Fatalf("Expected Server Blocks pushing to the same collector to share the exporter")
+	}
+	b.addServer("dns://:1053", []string{"example.org.", "example.net."})
+
+	if c := sharedOTLP(ctx, newOTLP(otlpGRPC, "b:1")); c == a {
+		t.Errorf("Expected another collector to get its own exporter")
+	}
+	if c := sharedOTLP(caddy.NewTestController("dns", "").Context(), newOTLP(otlpGRPC, "a:1")); c == a {
+		t.Errorf("Expected a new instance to get its own exporter")
+	}
+
+	attrs := a.resource().Set()
+	server, _ := attrs.Value("coredns.server")
+	if got := server.AsStringSlice(); !reflect.DeepEqual(got, []string{"dns://:1053", "dns://:53"}) {
+		t.Errorf("Expected both servers in the resource, got %v", got)
+	}
+	zone, _ := attrs.Value("coredns.zone")
+	if got := zone.AsStringSlice(); !reflect.DeepEqual(got, []string{"example.net.", "example.org."}) {
+		t.Errorf("Expected both zones in the resource, got %v", got)
+	}
+
+	// A configured attribute overrides these.
+	a.attrs["coredns.zone"] = "example.com."
+	if zone, _ := a.resource().Set().Value("coredns.zone"); zone.AsString() != "example.com." {
+		t.Errorf("Expected the configured zone, got %v", zone.Emit())
+	}
+}
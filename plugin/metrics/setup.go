@@ -3,6 +3,7 @@ package metrics
 import (
 	"net"
 	"runtime"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
@@ -31,6 +32,17 @@ func setup(c *caddy.Controller) error {
 	c.OnStartup(func() error { m.Reg = registry.getOrSet(m.Addr, m.Reg); u.Set(m.Addr, m.OnStartup); return nil })
 	c.OnRestartFailed(func() error { m.Reg = registry.getOrSet(m.Addr, m.Reg); u.Set(m.Addr, m.OnStartup); return nil })
 
+	if m.otlp != nil {
+		m.otlp = sharedOTLP(c.Context(), m.otlp)
+		conf := dnsserver.GetConfig(c)
+		for _, h := range conf.ListenHosts {
+			m.otlp.addServer(conf.Transport+"://"+net.JoinHostPort(h, conf.Port), m.ZoneNames())
+		}
+		c.OnStartup(func() error { u.Set(m.otlp.key(), m.startOTLP); return nil })
+		c.OnRestartFailed(func() error { u.Set(m.otlp.key(), m.startOTLP); return nil })
+		c.OnRestart(m.stopOTLP)
+	}
+
 	c.OnStartup(func() error { return u.ForEach() })
 	c.OnRestartFailed(func() error { return u.ForEach() })
 
@@ -97,6 +109,58 @@ func parse(c *caddy.Controller) (*Metrics, error) {
 		default:
 			return met, c.ArgErr()
 		}
+
+		for c.NextBlock() {
+			if c.Val() != "otlp" && met.otlp == nil {
+				return met, c.Errf("%s requires otlp to be set first", c.Val())
+			}
+			switch c.Val() {
+			case "otlp":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return met, c.ArgErr()
+				}
+				if args[0] != otlpGRPC && args[0] != otlpHTTP {
+					return met, c.Errf("unknown OTLP protocol %q", args[0])
+				}
+				if met.otlp != nil {
+					return met, c.Err("otlp can only be set once")
+				}
+				met.otlp = newOTLP(args[0], args[1])
+			case "interval":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return met, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return met, err
+				}
+				if d < time.Second {
+					return met, c.Errf("interval must be at least 1s: %s", d)
+				}
+				met.otlp.interval = d
+			case "insecure":
+				if c.NextArg() {
+					return met, c.ArgErr()
+				}
+				met.otlp.insecure = true
+			case "header":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return met, c.ArgErr()
+				}
+				met.otlp.headers[args[0]] = args[1]
+			case "resource":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return met, c.ArgErr()
+				}
+				met.otlp.attrs[args[0]] = args[1]
+			default:
+				return met, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
 	}
 	return met, nil
 }
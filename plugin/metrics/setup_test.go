@@ -1,7 +1,9 @@
 package metrics
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/coredns/caddy"
 )
@@ -40,3 +42,67 @@ func TestPrometheusParse(t *testing.T) {
 		}
 	}
 }
+
+func TestPrometheusParseOTLP(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		protocol  string
+		endpoint  string
+		insecure  bool
+		interval  time.Duration
+		headers   map[string]string
+		attrs     map[string]string
+	}{
+		{`prometheus {
+			otlp grpc collector:4317
+		}`, false, "grpc", "collector:4317", false, defaultOTLPInterval, map[string]string{}, map[string]string{}},
+		{`prometheus localhost:9253 {
+			otlp http http://collector:4318/v1/metrics
+			interval 15s
+			insecure
+			header Authorization "Bearer token"
+			resource server dns://:53
+			resource zone example.org.
+		}`, false, "http", "http://collector:4318/v1/metrics", true, 15 * time.Second,
+			map[string]string{"Authorization": "Bearer token"},
+			map[string]string{"server": "dns://:53", "zone": "example.org."}},
+		// fails
+		{"prometheus {\notlp\n}", true, "", "", false, 0, nil, nil},
+		{"prometheus {\notlp udp collector:4317\n}", true, "", "", false, 0, nil, nil},
+		{"prometheus {\notlp grpc a:1\notlp grpc b:1\n}", true, "", "", false, 0, nil, nil},
+		{"prometheus {\ninterval 10s\n}", true, "", "", false, 0, nil, nil},
+		{"prometheus {\notlp grpc a:1\ninterval 10ms\n}", true, "", "", false, 0, nil, nil},
+		{"prometheus {\notlp grpc a:1\ninterval soon\n}", true, "", "", false, 0, nil, nil},
+		{"prometheus {\notlp grpc a:1\ninsecure yes\n}", true, "", "", false, 0, nil, nil},
+		{"prometheus {\notlp grpc a:1\nheader a\n}", true, "", "", false, 0, nil, nil},
+		{"prometheus {\notlp grpc a:1\nresource a\n}", true, "", "", false, 0, nil, nil},
+		{"prometheus {\notlp grpc a:1\nunknown\n}", true, "", "", false, 0, nil, nil},
+	}
+	for i, test := range tests {
+		m, err := parse(caddy.NewTestController("dns", test.input))
+		if (err != nil) != test.shouldErr {
+			t.Errorf("Test %d: expected error to be %t, got %v", i, test.shouldErr, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		o := m.otlp
+		if o.protocol != test.protocol || o.endpoint != test.endpoint {
+			t.Errorf("Test %d: expected %s %s, got %s %s", i, test.protocol, test.endpoint, o.protocol, o.endpoint)
+		}
+		if o.insecure != test.insecure {
+			t.Errorf("Test %d: expected insecure to be %t", i, test.insecure)
+		}
+		if o.interval != test.interval {
+			t.Errorf("Test %d: expected interval %s, got %s", i, test.interval, o.interval)
+		}
+		if !reflect.DeepEqual(o.headers, test.headers) {
+			t.Errorf("Test %d: expected headers %v, got %v", i, test.headers, o.headers)
+		}
+		if !reflect.DeepEqual(o.attrs, test.attrs) {
+			t.Errorf("Test %d: expected resource %v, got %v", i, test.attrs, o.attrs)
+		}
+	}
+}
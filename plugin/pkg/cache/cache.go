@@ -57,6 +57,14 @@ func (c *Cache) Get(key uint64) (interface{}, bool) {
 	return c.shards[shard].Get(key)
 }
 
+// GetOrAdd looks up the element under key. If it doesn't exist, the element returned by f is added,
+// under the same lock, so concurrent callers all get the same element. Returns true if the element
+// already existed.
+func (c *Cache) GetOrAdd(key uint64, f func() interface{}) (interface{}, bool) {
+	shard := key & (shardSize - 1)
+	return c.shards[shard].GetOrAdd(key, f)
+}
+
 // Remove removes the element indexed with key.
 func (c *Cache) Remove(key uint64) {
 	shard := key & (shardSize - 1)
@@ -101,6 +109,28 @@ func (s *shard) Add(key uint64, el interface{}) bool {
 	return eviction
 }
 
+// GetOrAdd looks up the element indexed under key and adds the element returned by f if it doesn't exist.
+// Returns true if the element already existed.
+func (s *shard) GetOrAdd(key uint64, f func() interface{}) (interface{}, bool) {
+	if el, found := s.Get(key); found {
+		return el, true
+	}
+	s.Lock()
+	defer s.Unlock()
+	if el, found := s.items[key]; found {
+		return el, true
+	}
+	if len(s.items) >= s.size {
+		for k := range s.items {
+			delete(s.items, k)
+			break
+		}
+	}
+	el := f()
+	s.items[key] = el
+	return el, false
+}
+
 // Remove removes the element indexed by key from the cache.
 func (s *shard) Remove(key uint64) {
 	s.Lock()
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"sync"
 	"testing"
 )
 
@@ -24,6 +25,32 @@ func TestCacheAddAndGet(t *testing.T) {
 	}
 }
 
+func TestCacheGetOrAdd(t *testing.T) {
+	c := New(4)
+	if el, found := c.GetOrAdd(1, func() interface{} { return 1 }); found || el != 1 {
+		t.Fatalf("Expected the new element to be added, got %v", el)
+	}
+	if el, found := c.GetOrAdd(1, func() interface{} { return 2 }); !found || el != 1 {
+		t.Fatalf("Expected the existing element, got %v", el)
+	}
+
+	var wg sync.WaitGroup
+	els := make([]interface{}, 10)
+	for i := range els {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			els[i], _ = c.GetOrAdd(2, func() interface{} { return new(int) })
+		}()
+	}
+	wg.Wait()
+	for i := range els {
+		if els[i] != els[0] {
+			t.Fatalf("Expected all callers to get the same element")
+		}
+	}
+}
+
 func TestCacheLen(t *testing.T) {
 	c := New(4)
 
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"strings"
 
@@ -28,7 +29,8 @@ func stripZone(host string) string {
 // HostPortOrFile parses the strings in s, each string can either be a
 // address, [scheme://]address:port or a filename. The address part is checked
 // and in case of filename a resolv.conf like file is (assumed) and parsed and
-// the nameservers found are returned.
+// the nameservers found are returned. For https:// a URL is accepted, if it lacks
+// a port the default HTTPS port is added.
 func HostPortOrFile(s ...string) ([]string, error) {
 	var servers []string
 	for _, h := range s {
@@ -42,6 +44,19 @@ func HostPortOrFile(s ...string) ([]string, error) {
 			continue
 		}
 
+		if trans == transport.HTTPS {
+			// DNS-over-HTTPS endpoints are URLs, these may use a host name and a path.
+			u, err := url.Parse(h)
+			if err != nil || u.Hostname() == "" {
+				return servers, fmt.Errorf("not a valid URL: %q", h)
+			}
+			if u.Port() == "" {
+				u.Host = net.JoinHostPort(u.Hostname(), transport.HTTPSPort)
+			}
+			servers = append(servers, u.String())
+			continue
+		}
+
 		addr, _, err := net.SplitHostPort(host)
 
 		if err != nil {
@@ -65,8 +80,6 @@ func HostPortOrFile(s ...string) ([]string, error) {
 				ss = transport.QUIC + "://" + net.JoinHostPort(host, transport.QUICPort)
 			case transport.GRPC:
 				ss = transport.GRPC + "://" + net.JoinHostPort(host, transport.GRPCPort)
-			case transport.HTTPS:
-				ss = transport.HTTPS + "://" + net.JoinHostPort(host, transport.HTTPSPort)
 			}
 			servers = append(servers, ss)
 			continue
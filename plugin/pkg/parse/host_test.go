@@ -68,6 +68,26 @@ func TestHostPortOrFile(t *testing.T) {
 			"",
 			true,
 		},
+		{
+			"https://8.8.8.8",
+			"https://8.8.8.8:443",
+			false,
+		},
+		{
+			"https://dns.example.org/dns-query",
+			"https://dns.example.org:443/dns-query",
+			false,
+		},
+		{
+			"https://[fd01::1]:8443/resolve",
+			"https://[fd01::1]:8443/resolve",
+			false,
+		},
+		{
+			"https:///dns-query",
+			"",
+			true,
+		},
 	}
 
 	err := os.WriteFile("resolv.conf", []byte("nameserver 127.0.0.1\n"), 0600)
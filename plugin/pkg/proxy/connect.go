@@ -107,6 +107,26 @@ func (t *Transport) Dial(proto string) (*persistConn, bool, error) {
 func (p *Proxy) Connect(ctx context.Context, state request.Request, opts Options) (*dns.Msg, error) {
 	start := time.Now()
 
+	var (
+		ret *dns.Msg
+		err error
+	)
+	if p.ext != nil {
+		ret, err = p.connectExchanger(ctx, state)
+	} else {
+		ret, err = p.connect(ctx, state, opts)
+	}
+
+	// A closed cached connection or a cancelled query doesn't say anything about the upstream.
+	if err != ErrCachedClosed && ctx.Err() == nil {
+		p.observe(time.Since(start), err)
+	}
+	return ret, err
+}
+
+func (p *Proxy) connect(ctx context.Context, state request.Request, opts Options) (*dns.Msg, error) {
+	start := time.Now()
+
 	var proto string
 	switch {
 	case opts.ForceTCP: // TCP flag has precedence over UDP flag
@@ -128,6 +148,11 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts Options
 		pc.c.UDPSize = 512
 	}
 
+	// Unblock the write and read when ctx is cancelled, i.e. when another (hedged) query already won.
+	// If this fires after we've read the reply the connection is closed instead of given back.
+	stop := context.AfterFunc(ctx, func() { pc.c.SetDeadline(time.Now()) })
+	defer stop()
+
 	pc.c.SetWriteDeadline(time.Now().Add(maxTimeout))
 	// records the origin Id before upstream.
 	originId := state.Req.Id
@@ -179,7 +204,41 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts Options
 	// recovery the origin Id after upstream.
 	ret.Id = originId
 
-	p.transport.Yield(pc)
+	if stop() {
+		p.transport.Yield(pc)
+	} else {
+		pc.c.Close() // deadline was reset by the cancellation, not giving it back
+	}
+
+	rc, ok := dns.RcodeToString[ret.Rcode]
+	if !ok {
+		rc = strconv.Itoa(ret.Rcode)
+	}
+
+	requestDuration.WithLabelValues(p.proxyName, p.addr, rc).Observe(time.Since(start).Seconds())
+
+	return ret, nil
+}
+
+// connectExchanger is Connect for the upstreams that have their own transport (DoH and DoQ).
+func (p *Proxy) connectExchanger(ctx context.Context, state request.Request) (*dns.Msg, error) {
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, maxTimeout+p.readTimeout)
+	defer cancel()
+
+	// Both RFC 8484 (DoH) and RFC 9250 (DoQ) want an ID of 0, DoQ even requires it.
+	originId := state.Req.Id
+	state.Req.Id = 0
+	defer func() {
+		state.Req.Id = originId
+	}()
+
+	ret, err := p.ext.exchange(ctx, state.Req)
+	if err != nil {
+		return nil, err
+	}
+	ret.Id = originId
 
 	rc, ok := dns.RcodeToString[ret.Rcode]
 	if !ok {
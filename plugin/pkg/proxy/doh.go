@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/trace"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// dohTransport sends DNS messages to a DNS-over-HTTPS (RFC 8484) endpoint. The underlying
+// http.Transport keeps the (HTTP/2) connection open and multiplexes concurrent queries over it.
+type dohTransport struct {
+	url string
+	tr  *http.Transport
+	c   *http.Client
+}
+
+func newDoHTransport(u string) *dohTransport {
+	tr := &http.Transport{
+		DialContext:           (&net.Dialer{Timeout: maxDialTimeout}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConnsPerHost:   1,
+		IdleConnTimeout:       defaultExpire,
+		TLSHandshakeTimeout:   maxTimeout,
+		ResponseHeaderTimeout: maxTimeout,
+		TLSClientConfig:       &tls.Config{},
+	}
+	return &dohTransport{url: u, tr: tr, c: &http.Client{Transport: tr}}
+}
+
+// parseDoHURL parses a DoH upstream as given in the configuration (without the https:// prefix)
+// and returns the host:port to use as the address and the full URL to send queries to. When no
+// port is given 443 is used, when no path is given doh.Path is used.
+func parseDoHURL(s string) (addr, u string, err error) {
+	pu, err := url.Parse(transport.HTTPS + "://" + s)
+	if err != nil {
+		return "", "", err
+	}
+	if pu.Hostname() == "" {
+		return "", "", fmt.Errorf("no host in DoH URL: %q", s)
+	}
+	port := pu.Port()
+	if port == "" {
+		port = transport.HTTPSPort
+	}
+	if pu.Path == "" {
+		pu.Path = doh.Path
+	}
+	pu.Host = net.JoinHostPort(pu.Hostname(), port)
+	return pu.Host, pu.String(), nil
+}
+
+// CheckDoHURL returns an error when addr, a DoH upstream without the https:// prefix, can't be used
+// with NewProxy.
+func CheckDoHURL(addr string) error {
+	_, _, err := parseDoHURL(addr)
+	return err
+}
+
+// SetTLSConfig sets the TLS config in the transport. The config is cloned because it is shared
+// with the DoT proxies and we need to offer HTTP/2 in the ALPN extension.
+func (d *dohTransport) SetTLSConfig(cfg *tls.Config) {
+	c := cfg.Clone()
+	c.NextProtos = []string{"h2", "http/1.1"}
+	d.tr.TLSClientConfig = c
+}
+
+// SetExpire sets the time after which idle connections are closed.
+func (d *dohTransport) SetExpire(expire time.Duration) { d.tr.IdleConnTimeout = expire }
+
+// Stop closes all idle connections.
+func (d *dohTransport) Stop() { d.tr.CloseIdleConnections() }
+
+// exchange sends m as a POST request and returns the reply.
+func (d *dohTransport) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	buf, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", doh.MimeType)
+	req.Header.Set("Accept", doh.MimeType)
+	// Continue the trace of the query at the upstream, when it is traced.
+	trace.Propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := d.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected HTTP status from %s: %d", d.url, resp.StatusCode)
+	}
+	return doh.ResponseToMsg(resp)
+}
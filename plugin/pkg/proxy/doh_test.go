@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func newDoHServer(t *testing.T, h func(r *dns.Msg) *dns.Msg) (*httptest.Server, *tls.Config) {
+	t.Helper()
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			http.Error(w, "HTTP/2 expected", http.StatusBadRequest)
+			return
+		}
+		m, err := doh.RequestToMsg(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		buf, _ := h(m).Pack()
+		w.Header().Set("Content-Type", doh.MimeType)
+		w.Write(buf)
+	}))
+	s.EnableHTTP2 = true
+	s.StartTLS()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Certificate())
+	return s, &tls.Config{RootCAs: pool}
+}
+
+func TestProxyDoH(t *testing.T) {
+	ids := uint32(0)
+	s, cfg := newDoHServer(t, func(r *dns.Msg) *dns.Msg {
+		if r.Id != 0 {
+			atomic.AddUint32(&ids, 1)
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.1"))
+		return ret
+	})
+	defer s.Close()
+
+	p := NewProxy("TestProxyDoH", strings.TrimPrefix(s.URL, "https://"), transport.HTTPS)
+	p.SetTLSConfig(cfg)
+	p.Start(5 * time.Second)
+	defer p.Stop()
+
+	if x := p.Addr(); x != s.Listener.Addr().String() {
+		t.Errorf("Expected address %s, got %s", s.Listener.Addr().String(), x)
+	}
+
+	for range 3 {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		req := request.Request{Req: m, W: &test.ResponseWriter{}}
+
+		resp, err := p.Connect(context.Background(), req, Options{})
+		if err != nil {
+			t.Fatalf("Failed to connect to DoH server: %s", err)
+		}
+		if resp.Id != m.Id {
+			t.Errorf("Expected reply ID %d, got %d", m.Id, resp.Id)
+		}
+		if x := resp.Answer[0].Header().Name; x != "example.org." {
+			t.Errorf("Expected %s, got %s", "example.org.", x)
+		}
+	}
+	if x := atomic.LoadUint32(&ids); x != 0 {
+		t.Errorf("Expected all queries to be sent with ID 0, got %d with other IDs", x)
+	}
+}
+
+func TestProxyDoHTraceContext(t *testing.T) {
+	traceparent := make(chan string, 1)
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent <- r.Header.Get("traceparent")
+		m, _ := doh.RequestToMsg(r)
+		m.Response = true
+		buf, _ := m.Pack()
+		w.Header().Set("Content-Type", doh.MimeType)
+		w.Write(buf)
+	}))
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	defer s.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Certificate())
+
+	p := NewProxy("TestProxyDoHTraceContext", strings.TrimPrefix(s.URL, "https://"), transport.HTTPS)
+	p.SetTLSConfig(&tls.Config{RootCAs: pool})
+	p.Start(5 * time.Second)
+	defer p.Stop()
+
+	sc := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    oteltrace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     oteltrace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: oteltrace.FlagsSampled,
+	})
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	req := request.Request{Req: m, W: &test.ResponseWriter{}}
+	if _, err := p.Connect(oteltrace.ContextWithSpanContext(context.Background(), sc), req, Options{}); err != nil {
+		t.Fatalf("Failed to connect to DoH server: %s", err)
+	}
+
+	expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	if x := <-traceparent; x != expected {
+		t.Errorf("Expected traceparent %q, got %q", expected, x)
+	}
+}
+
+func TestProxyDoHFail(t *testing.T) {
+	s, cfg := newDoHServer(t, func(r *dns.Msg) *dns.Msg { return r })
+	defer s.Close()
+
+	// No certificate pool, server certificate can't be verified.
+	p := NewProxy("TestProxyDoHFail", strings.TrimPrefix(s.URL, "https://"), transport.HTTPS)
+	p.SetTLSConfig(&tls.Config{})
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if _, err := p.Connect(context.Background(), request.Request{Req: m, W: &test.ResponseWriter{}}, Options{}); err == nil {
+		t.Fatal("Expected *not* to receive reply, but got one")
+	}
+
+	if err := p.GetHealthchecker().Check(p); err == nil {
+		t.Error("Expected health check to fail")
+	}
+	if x := p.Fails(); x != 1 {
+		t.Errorf("Expected fails to be %d, got %d", 1, x)
+	}
+
+	// With the correct certificate pool the health check should succeed and reset the fails.
+	p.SetTLSConfig(cfg)
+	if err := p.GetHealthchecker().Check(p); err != nil {
+		t.Errorf("Expected health check to succeed, got: %s", err)
+	}
+	if x := p.Fails(); x != 0 {
+		t.Errorf("Expected fails to be %d, got %d", 0, x)
+	}
+}
+
+func TestParseDoHURL(t *testing.T) {
+	tests := []struct {
+		in        string
+		addr      string
+		url       string
+		shouldErr bool
+	}{
+		{"dns.example.org", "dns.example.org:443", "https://dns.example.org:443/dns-query", false},
+		{"dns.example.org:8443/resolve", "dns.example.org:8443", "https://dns.example.org:8443/resolve", false},
+		{"[::1]/dns-query", "[::1]:443", "https://[::1]:443/dns-query", false},
+		{"/dns-query", "", "", true},
+	}
+	for i, tc := range tests {
+		addr, u, err := parseDoHURL(tc.in)
+		if (err != nil) != tc.shouldErr {
+			t.Fatalf("Test %d: expected error %t, got %v", i, tc.shouldErr, err)
+		}
+		if addr != tc.addr || u != tc.url {
+			t.Errorf("Test %d: expected %s and %s, got %s and %s", i, tc.addr, tc.url, addr, u)
+		}
+		if err := CheckDoHURL(tc.in); (err != nil) != tc.shouldErr {
+			t.Errorf("Test %d: expected CheckDoHURL error %t, got %v", i, tc.shouldErr, err)
+		}
+	}
+}
+
+func TestProxyDoHInvalidURL(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected an invalid DoH URL to panic instead of building a proxy")
+		}
+	}()
+	NewProxy("TestProxyDoHInvalidURL", "/dns-query", transport.HTTPS)
+}
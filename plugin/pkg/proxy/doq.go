@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+const (
+	// doqCodeNoError is used when closing the connection without an error, see RFC 9250, Section 4.3.
+	doqCodeNoError quic.ApplicationErrorCode = 0
+	// doqALPN is the ALPN token for DNS-over-QUIC.
+	doqALPN = "doq"
+)
+
+// doqTransport sends DNS messages to a DNS-over-QUIC (RFC 9250) endpoint. It keeps a single long-lived
+// QUIC connection open and opens a new stream on it for every query.
+type doqTransport struct {
+	addr       string
+	tlsConfig  *tls.Config
+	quicConfig *quic.Config
+
+	mu   sync.Mutex
+	conn quic.Connection
+}
+
+func newDoQTransport(addr string) *doqTransport {
+	return &doqTransport{
+		addr:       addr,
+		tlsConfig:  &tls.Config{NextProtos: []string{doqALPN}},
+		quicConfig: &quic.Config{MaxIdleTimeout: defaultExpire, HandshakeIdleTimeout: maxTimeout},
+	}
+}
+
+// SetTLSConfig sets the TLS config in the transport. The config is cloned because it is shared
+// with the other proxies and we need to set the doq ALPN token.
+func (d *doqTransport) SetTLSConfig(cfg *tls.Config) {
+	c := cfg.Clone()
+	c.NextProtos = []string{doqALPN}
+	d.mu.Lock()
+	d.tlsConfig = c
+	d.mu.Unlock()
+}
+
+// SetExpire sets the idle timeout of the QUIC connection, it is used for connections dialed after this call.
+func (d *doqTransport) SetExpire(expire time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := d.quicConfig.Clone()
+	c.MaxIdleTimeout = expire
+	d.quicConfig = c
+}
+
+// Stop closes the QUIC connection.
+func (d *doqTransport) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn != nil {
+		d.conn.CloseWithError(doqCodeNoError, "")
+		d.conn = nil
+	}
+}
+
+// dial returns the current connection or opens a new one when there is none or the current one is closed.
+func (d *doqTransport) dial(ctx context.Context) (quic.Connection, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn != nil && d.conn.Context().Err() == nil {
+		return d.conn, nil
+	}
+	conn, err := quic.DialAddr(ctx, d.addr, d.tlsConfig, d.quicConfig)
+	if err != nil {
+		return nil, err
+	}
+	d.conn = conn
+	return conn, nil
+}
+
+// reset drops conn, if it is still the current connection.
+func (d *doqTransport) reset(conn quic.Connection) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn == conn {
+		d.conn.CloseWithError(doqCodeNoError, "")
+		d.conn = nil
+	}
+}
+
+// exchange sends m on a new stream and returns the reply.
+func (d *doqTransport) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	buf, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := d.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		// Running out of time while waiting for stream credit says nothing about the connection, keep it.
+		if conn.Context().Err() == nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// The connection might have been closed by the remote (i.e. idle timeout), retry once with a new one.
+		d.reset(conn)
+		if conn, err = d.dial(ctx); err != nil {
+			return nil, err
+		}
+		if stream, err = conn.OpenStreamSync(ctx); err != nil {
+			if conn.Context().Err() != nil || ctx.Err() == nil {
+				d.reset(conn)
+			}
+			return nil, err
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+
+	// Messages are prefixed with a 2 byte length field, like DNS over TCP. See RFC 9250, Section 4.2.
+	msg := make([]byte, 2+len(buf))
+	binary.BigEndian.PutUint16(msg, uint16(len(buf)))
+	copy(msg[2:], buf)
+	if _, err := stream.Write(msg); err != nil {
+		stream.CancelRead(0)
+		return nil, err
+	}
+	// Closing the stream only closes the write direction, this signals the server we're done sending.
+	stream.Close()
+
+	ret, err := readDoQMsg(stream)
+	if err != nil {
+		stream.CancelRead(0)
+		return nil, err
+	}
+	return ret, nil
+}
+
+func readDoQMsg(r io.Reader) (*dns.Msg, error) {
+	var l uint16
+	if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+		return nil, err
+	}
+	if l == 0 {
+		return nil, errors.New("zero length DoQ message")
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ctls "github.com/coredns/coredns/plugin/pkg/tls"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// newDoQServer starts a minimal DoQ server answering every query with h. It returns the listener
+// and a counter of accepted connections.
+func newDoQServer(t *testing.T, h func(r *dns.Msg) *dns.Msg) (*quic.Listener, *uint32) {
+	t.Helper()
+	return newDoQServerConfig(t, nil, h)
+}
+
+// newDoQServerConfig is like newDoQServer, but uses qcfg for the QUIC listener.
+func newDoQServerConfig(t *testing.T, qcfg *quic.Config, h func(r *dns.Msg) *dns.Msg) (*quic.Listener, *uint32) {
+	t.Helper()
+	cfg, err := ctls.NewTLSConfig("../../tls/test_cert.pem", "../../tls/test_key.pem", "../../tls/test_ca.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.NextProtos = []string{"doq"}
+
+	l, err := quic.ListenAddr("127.0.0.1:0", cfg, qcfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conns := uint32(0)
+	go func() {
+		for {
+			conn, err := l.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			atomic.AddUint32(&conns, 1)
+			go func() {
+				for {
+					stream, err := conn.AcceptStream(context.Background())
+					if err != nil {
+						return
+					}
+					go func() {
+						defer stream.Close()
+						r, err := readDoQMsg(stream)
+						if err != nil {
+							return
+						}
+						buf, _ := h(r).Pack()
+						l := make([]byte, 2)
+						binary.BigEndian.PutUint16(l, uint16(len(buf)))
+						stream.Write(append(l, buf...))
+					}()
+				}
+			}()
+		}
+	}()
+	return l, &conns
+}
+
+func TestProxyDoQ(t *testing.T) {
+	ids := uint32(0)
+	l, conns := newDoQServer(t, func(r *dns.Msg) *dns.Msg {
+		if r.Id != 0 {
+			atomic.AddUint32(&ids, 1)
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.1"))
+		return ret
+	})
+	defer l.Close()
+
+	p := NewProxy("TestProxyDoQ", l.Addr().String(), transport.QUIC)
+	p.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	p.Start(5 * time.Second)
+	defer p.Stop()
+
+	for range 3 {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		req := request.Request{Req: m, W: &test.ResponseWriter{}}
+
+		resp, err := p.Connect(context.Background(), req, Options{})
+		if err != nil {
+			t.Fatalf("Failed to connect to DoQ server: %s", err)
+		}
+		if resp.Id != m.Id {
+			t.Errorf("Expected reply ID %d, got %d", m.Id, resp.Id)
+		}
+		if x := resp.Answer[0].Header().Name; x != "example.org." {
+			t.Errorf("Expected %s, got %s", "example.org.", x)
+		}
+	}
+	if x := atomic.LoadUint32(&ids); x != 0 {
+		t.Errorf("Expected all queries to be sent with ID 0, got %d with other IDs", x)
+	}
+	if x := atomic.LoadUint32(conns); x != 1 {
+		t.Errorf("Expected all queries to use %d connection, got %d", 1, x)
+	}
+
+	if err := p.GetHealthchecker().Check(p); err != nil {
+		t.Errorf("Expected health check to succeed, got: %s", err)
+	}
+
+	// After the connection is closed, a new one should be set up.
+	p.ext.Stop()
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if _, err := p.Connect(context.Background(), request.Request{Req: m, W: &test.ResponseWriter{}}, Options{}); err != nil {
+		t.Fatalf("Failed to reconnect to DoQ server: %s", err)
+	}
+	if x := atomic.LoadUint32(conns); x != 2 {
+		t.Errorf("Expected %d connections, got %d", 2, x)
+	}
+}
+
+func TestProxyDoQFail(t *testing.T) {
+	l, _ := newDoQServer(t, func(r *dns.Msg) *dns.Msg { return r })
+	defer l.Close()
+
+	// Certificate can't be verified.
+	p := NewProxy("TestProxyDoQFail", l.Addr().String(), transport.QUIC)
+	p.SetTLSConfig(&tls.Config{})
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if _, err := p.Connect(context.Background(), request.Request{Req: m, W: &test.ResponseWriter{}}, Options{}); err == nil {
+		t.Fatal("Expected *not* to receive reply, but got one")
+	}
+	if err := p.GetHealthchecker().Check(p); err == nil {
+		t.Error("Expected health check to fail")
+	}
+	if x := p.Fails(); x != 1 {
+		t.Errorf("Expected fails to be %d, got %d", 1, x)
+	}
+}
+
+func TestProxyDoQStreamTimeout(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	// Only allow a single stream, so a second query has to wait for the first one to be answered.
+	l, conns := newDoQServerConfig(t, &quic.Config{MaxIncomingStreams: 1}, func(r *dns.Msg) *dns.Msg {
+		if r.Question[0].Name == "block.example.org." {
+			close(started)
+			<-release
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		return ret
+	})
+	defer l.Close()
+
+	p := NewProxy("TestProxyDoQStreamTimeout", l.Addr().String(), transport.QUIC)
+	p.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	p.Start(5 * time.Second)
+	defer p.Stop()
+
+	errc := make(chan error)
+	go func() {
+		m := new(dns.Msg)
+		m.SetQuestion("block.example.org.", dns.TypeA)
+		_, err := p.Connect(context.Background(), request.Request{Req: m, W: &test.ResponseWriter{}}, Options{})
+		errc <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if _, err := p.Connect(ctx, request.Request{Req: m, W: &test.ResponseWriter{}}, Options{}); err == nil {
+		t.Fatal("Expected the query to time out waiting for a stream")
+	}
+
+	// The timeout must not have torn down the connection the blocked query is using.
+	close(release)
+	if err := <-errc; err != nil {
+		t.Errorf("Expected the blocked query to succeed, got: %s", err)
+	}
+	if x := atomic.LoadUint32(conns); x != 1 {
+		t.Errorf("Expected %d connection, got %d", 1, x)
+	}
+}
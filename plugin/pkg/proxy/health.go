@@ -1,7 +1,9 @@
 package proxy
 
 import (
+	"context"
 	"crypto/tls"
+	"fmt"
 	"sync/atomic"
 	"time"
 
@@ -51,6 +53,14 @@ func NewHealthChecker(proxyName, trans string, recursionDesired bool, domain str
 			domain:           domain,
 			proxyName:        proxyName,
 		}
+	case transport.HTTPS, transport.QUIC:
+		return &exchangeHc{
+			recursionDesired: recursionDesired,
+			domain:           domain,
+			readTimeout:      1 * time.Second,
+			writeTimeout:     1 * time.Second,
+			proxyName:        proxyName,
+		}
 	}
 
 	log.Warningf("No healthchecker for transport %q", trans)
@@ -132,3 +142,59 @@ func (h *dnsHc) send(addr string) error {
 
 	return err
 }
+
+// exchangeHc is a health checker for a DNS-over-HTTPS or DNS-over-QUIC endpoint. It sends the health
+// check query over the proxy's own transport, so a check also verifies the (re)used connection.
+type exchangeHc struct {
+	recursionDesired bool
+	domain           string
+	tlsConfig        *tls.Config
+	readTimeout      time.Duration
+	writeTimeout     time.Duration
+
+	proxyName string
+}
+
+func (h *exchangeHc) SetTLSConfig(cfg *tls.Config)    { h.tlsConfig = cfg }
+func (h *exchangeHc) GetTLSConfig() *tls.Config       { return h.tlsConfig }
+func (h *exchangeHc) SetRecursionDesired(b bool)      { h.recursionDesired = b }
+func (h *exchangeHc) GetRecursionDesired() bool       { return h.recursionDesired }
+func (h *exchangeHc) SetDomain(domain string)         { h.domain = domain }
+func (h *exchangeHc) GetDomain() string               { return h.domain }
+func (h *exchangeHc) GetReadTimeout() time.Duration   { return h.readTimeout }
+func (h *exchangeHc) SetReadTimeout(t time.Duration)  { h.readTimeout = t }
+func (h *exchangeHc) GetWriteTimeout() time.Duration  { return h.writeTimeout }
+func (h *exchangeHc) SetWriteTimeout(t time.Duration) { h.writeTimeout = t }
+
+// SetTCPTransport is a noop, the transport is fixed for DoH and DoQ.
+func (h *exchangeHc) SetTCPTransport() {}
+
+// Check is used as the up.Func in the up.Probe.
+func (h *exchangeHc) Check(p *Proxy) error {
+	err := h.send(p)
+	if err != nil {
+		healthcheckFailureCount.WithLabelValues(p.proxyName, p.addr).Add(1)
+		p.incrementFails()
+		return err
+	}
+
+	atomic.StoreUint32(&p.fails, 0)
+	return nil
+}
+
+func (h *exchangeHc) send(p *Proxy) error {
+	if p.ext == nil {
+		return fmt.Errorf("no DoH or DoQ transport for %s", p.addr)
+	}
+	ping := new(dns.Msg)
+	ping.SetQuestion(h.domain, dns.TypeNS)
+	ping.RecursionDesired = h.recursionDesired
+	ping.Id = 0
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.readTimeout+h.writeTimeout)
+	defer cancel()
+
+	// Any valid DNS reply, whatever the rcode, means the upstream is alive.
+	_, err := p.ext.exchange(ctx, ping)
+	return err
+}
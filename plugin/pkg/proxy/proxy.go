@@ -2,21 +2,30 @@ package proxy
 
 import (
 	"crypto/tls"
+	"fmt"
 	"runtime"
 	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/plugin/pkg/up"
 )
 
 // Proxy defines an upstream host.
 type Proxy struct {
+	// smoothed round trip time and its mean deviation (in ns), and the error rate (in parts per
+	// million) of the exchanges with this upstream. These need to be first for proper alignment.
+	srtt    int64
+	rttvar  int64
+	errRate int64
+
 	fails     uint32
 	addr      string
 	proxyName string
 
 	transport *Transport
+	ext       exchanger // DNS-over-HTTPS or DNS-over-QUIC transport, nil for DNS and DNS-over-TLS.
 
 	readTimeout time.Duration
 
@@ -25,14 +34,30 @@ type Proxy struct {
 	health HealthChecker
 }
 
-// NewProxy returns a new proxy.
+// NewProxy returns a new proxy. For DNS-over-HTTPS (transport.HTTPS) addr is the URL of the
+// upstream without the https:// prefix, i.e. "dns.example.org/dns-query". This must be checked with
+// CheckDoHURL first, NewProxy panics on an invalid URL.
 func NewProxy(proxyName, addr, trans string) *Proxy {
+	var ext exchanger
+	switch trans {
+	case transport.HTTPS:
+		hostport, u, err := parseDoHURL(addr)
+		if err != nil {
+			panic(fmt.Sprintf("invalid DoH upstream %q: %s", addr, err))
+		}
+		addr = hostport
+		ext = newDoHTransport(u)
+	case transport.QUIC:
+		ext = newDoQTransport(addr)
+	}
+
 	p := &Proxy{
 		addr:        addr,
 		fails:       0,
 		probe:       up.New(),
 		readTimeout: 2 * time.Second,
 		transport:   newTransport(proxyName, addr),
+		ext:         ext,
 		health:      NewHealthChecker(proxyName, trans, true, "."),
 		proxyName:   proxyName,
 	}
@@ -45,12 +70,21 @@ func (p *Proxy) Addr() string { return p.addr }
 
 // SetTLSConfig sets the TLS config in the lower p.transport and in the healthchecking client.
 func (p *Proxy) SetTLSConfig(cfg *tls.Config) {
-	p.transport.SetTLSConfig(cfg)
+	if p.ext != nil {
+		p.ext.SetTLSConfig(cfg)
+	} else {
+		p.transport.SetTLSConfig(cfg)
+	}
 	p.health.SetTLSConfig(cfg)
 }
 
 // SetExpire sets the expire duration in the lower p.transport.
-func (p *Proxy) SetExpire(expire time.Duration) { p.transport.SetExpire(expire) }
+func (p *Proxy) SetExpire(expire time.Duration) {
+	if p.ext != nil {
+		p.ext.SetExpire(expire)
+	}
+	p.transport.SetExpire(expire)
+}
 
 func (p *Proxy) GetHealthchecker() HealthChecker {
 	return p.health
@@ -83,8 +117,13 @@ func (p *Proxy) Down(maxfails uint32) bool {
 }
 
 // Stop close stops the health checking goroutine.
-func (p *Proxy) Stop()      { p.probe.Stop() }
-func (p *Proxy) finalizer() { p.transport.Stop() }
+func (p *Proxy) Stop() { p.probe.Stop() }
+func (p *Proxy) finalizer() {
+	p.transport.Stop()
+	if p.ext != nil {
+		p.ext.Stop()
+	}
+}
 
 // Start starts the proxy's healthchecking.
 func (p *Proxy) Start(duration time.Duration) {
@@ -96,6 +135,47 @@ func (p *Proxy) SetReadTimeout(duration time.Duration) {
 	p.readTimeout = duration
 }
 
+// ReadTimeout returns the time to wait for a reply from the upstream.
+func (p *Proxy) ReadTimeout() time.Duration { return p.readTimeout }
+
+// SRTT returns the smoothed round trip time of the exchanges with this upstream, zero if nothing
+// has been measured yet.
+func (p *Proxy) SRTT() time.Duration { return time.Duration(atomic.LoadInt64(&p.srtt)) }
+
+// RTTVar returns the mean deviation of the round trip time of the exchanges with this upstream.
+func (p *Proxy) RTTVar() time.Duration { return time.Duration(atomic.LoadInt64(&p.rttvar)) }
+
+// ErrorRate returns the smoothed fraction (0 to 1) of exchanges with this upstream that returned an error.
+func (p *Proxy) ErrorRate() float64 { return float64(atomic.LoadInt64(&p.errRate)) / errRateScale }
+
+// observe updates the smoothed RTT and error rate with the outcome of a single exchange. The
+// RTT is computed as in RFC 6298, failed exchanges only update the error rate.
+func (p *Proxy) observe(rtt time.Duration, err error) {
+	if err != nil {
+		ewma(&p.errRate, errRateScale, errRateWeight)
+		return
+	}
+	ewma(&p.errRate, 0, errRateWeight)
+
+	srtt := atomic.LoadInt64(&p.srtt)
+	if srtt == 0 {
+		atomic.StoreInt64(&p.srtt, int64(rtt))
+		atomic.StoreInt64(&p.rttvar, int64(rtt/2))
+		return
+	}
+	dev := int64(rtt) - srtt
+	if dev < 0 {
+		dev = -dev
+	}
+	ewma(&p.rttvar, dev, rttvarWeight)
+	ewma(&p.srtt, int64(rtt), srttWeight)
+}
+
+// ewma moves the average in avg towards sample, moderated by weight.
+func ewma(avg *int64, sample, weight int64) {
+	atomic.AddInt64(avg, (sample-atomic.LoadInt64(avg))/weight)
+}
+
 // incrementFails increments the number of fails safely.
 func (p *Proxy) incrementFails() {
 	curVal := atomic.LoadUint32(&p.fails)
@@ -108,4 +188,9 @@ func (p *Proxy) incrementFails() {
 
 const (
 	maxTimeout = 2 * time.Second
+
+	srttWeight    = 8
+	rttvarWeight  = 4
+	errRateWeight = 16
+	errRateScale  = 1e6
 )
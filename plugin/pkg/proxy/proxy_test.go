@@ -223,3 +223,67 @@ func TestShouldTruncateResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestProxyObserve(t *testing.T) {
+	p := NewProxy("TestProxyObserve", "127.0.0.1:53", transport.DNS)
+
+	p.observe(100*time.Millisecond, nil)
+	if x := p.SRTT(); x != 100*time.Millisecond {
+		t.Errorf("Expected SRTT to be %s, got %s", 100*time.Millisecond, x)
+	}
+	if x := p.RTTVar(); x != 50*time.Millisecond {
+		t.Errorf("Expected RTTVar to be %s, got %s", 50*time.Millisecond, x)
+	}
+
+	for range 50 {
+		p.observe(20*time.Millisecond, nil)
+	}
+	if x := p.SRTT(); x > 25*time.Millisecond {
+		t.Errorf("Expected SRTT to converge to %s, got %s", 20*time.Millisecond, x)
+	}
+	if x := p.ErrorRate(); x != 0 {
+		t.Errorf("Expected error rate to be 0, got %f", x)
+	}
+
+	srtt := p.SRTT()
+	for range 10 {
+		p.observe(time.Second, errors.New("timeout"))
+	}
+	if x := p.SRTT(); x != srtt {
+		t.Errorf("Expected SRTT to be unchanged by errors, got %s", x)
+	}
+	if x := p.ErrorRate(); x < 0.4 || x > 1 {
+		t.Errorf("Expected error rate to be between 0.4 and 1, got %f", x)
+	}
+}
+
+func TestProxyCancel(t *testing.T) {
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		time.Sleep(time.Second)
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	p := NewProxy("TestProxyCancel", s.Addr, transport.DNS)
+	p.Start(5 * time.Second)
+	defer p.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	req := request.Request{Req: m, W: &test.ResponseWriter{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.Connect(ctx, req, Options{}); err == nil {
+		t.Fatal("Expected an error for a cancelled query")
+	}
+	if x := time.Since(start); x > 500*time.Millisecond {
+		t.Errorf("Expected the query to be cancelled within %s, took %s", 500*time.Millisecond, x)
+	}
+	if x := p.ErrorRate(); x != 0 {
+		t.Errorf("Expected a cancelled query not to count as an error, got error rate %f", x)
+	}
+}
@@ -1,7 +1,12 @@
 package proxy
 
 import (
+	"context"
+	"crypto/tls"
 	"net"
+	"time"
+
+	"github.com/miekg/dns"
 )
 
 type transportType int
@@ -37,3 +42,12 @@ func (t *Transport) transportTypeFromConn(pc *persistConn) transportType {
 
 	return typeTLS
 }
+
+// exchanger is a transport that is not handled by the persistent connection cache in Transport, it
+// is used for DNS-over-HTTPS and DNS-over-QUIC, where a single connection multiplexes all queries.
+type exchanger interface {
+	exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error)
+	SetTLSConfig(*tls.Config)
+	SetExpire(time.Duration)
+	Stop()
+}
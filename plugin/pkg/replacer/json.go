@@ -0,0 +1,131 @@
+package replacer
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// JSON returns all the values the labels can be replaced with, and all metadata, as a JSON object on a
+// single line. Numbers and booleans are written as such, the duration as a number of seconds and the
+// metadata as an object keyed by label. Values that aren't known are left out.
+func (r Replacer) JSON(ctx context.Context, state request.Request, rr *dnstest.Recorder) string {
+	b := bufPool.Get().([]byte)
+	b = append(b, '{')
+
+	if (request.Request{}) != state {
+		b = AppendJSONField(b, "remote", state.IP())
+		if port, err := strconv.Atoi(state.Port()); err == nil {
+			b = AppendJSONInt(b, "port", int64(port))
+		}
+		b = AppendJSONField(b, "local", state.LocalIP())
+		b = AppendJSONInt(b, "id", int64(state.Req.Id))
+		b = AppendJSONInt(b, "opcode", int64(state.Req.Opcode))
+		b = AppendJSONField(b, "type", state.Type())
+		b = AppendJSONField(b, "class", state.Class())
+		b = AppendJSONField(b, "name", state.Name())
+		b = AppendJSONField(b, "proto", state.Proto())
+		b = AppendJSONInt(b, "size", int64(state.Req.Len()))
+		b = appendJSONKey(b, "do")
+		b = strconv.AppendBool(b, state.Do())
+		b = AppendJSONInt(b, "bufsize", int64(state.Size()))
+	}
+
+	if rr != nil {
+		if rr.Msg != nil {
+			rcode := dns.RcodeToString[rr.Rcode]
+			if rcode == "" {
+				rcode = strconv.Itoa(rr.Rcode)
+			}
+			b = AppendJSONField(b, "rcode", rcode)
+			b = AppendJSONField(b, "rflags", string(appendFlags(nil, rr.Msg.MsgHdr)))
+		}
+		b = AppendJSONInt(b, "rsize", int64(rr.Len))
+		b = appendJSONKey(b, "duration")
+		b = strconv.AppendFloat(b, time.Since(rr.Start).Seconds(), 'f', -1, 64)
+	}
+
+	if labels := metadata.Labels(ctx); len(labels) > 0 {
+		sort.Strings(labels)
+		b = appendJSONKey(b, "metadata")
+		b = append(b, '{')
+		for _, l := range labels {
+			if fm := metadata.ValueFunc(ctx, l); fm != nil {
+				b = AppendJSONField(b, l, fm())
+			}
+		}
+		b = append(b, '}')
+	}
+
+	b = append(b, '}')
+	s := string(b)
+	//nolint:staticcheck
+	bufPool.Put(b[:0])
+	return s
+}
+
+// AppendJSONField appends the key and the string value as a JSON object member to b. A comma is added
+// when b doesn't end with the opening brace of the object.
+func AppendJSONField(b []byte, key, value string) []byte {
+	b = appendJSONKey(b, key)
+	return appendJSONString(b, value)
+}
+
+// AppendJSONInt appends the key and the integer value as a JSON object member to b.
+func AppendJSONInt(b []byte, key string, i int64) []byte {
+	b = appendJSONKey(b, key)
+	return strconv.AppendInt(b, i, 10)
+}
+
+func appendJSONKey(b []byte, key string) []byte {
+	if n := len(b); n > 0 && b[n-1] != '{' {
+		b = append(b, ',')
+	}
+	b = appendJSONString(b, key)
+	return append(b, ':')
+}
+
+const hex = "0123456789abcdef"
+
+// appendJSONString appends s as a quoted and escaped JSON string. Invalid UTF-8 is replaced
+// with U+FFFD.
+func appendJSONString(b []byte, s string) []byte {
+	b = append(b, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				b = append(b, '\\', c)
+			case c == '\n':
+				b = append(b, '\\', 'n')
+			case c == '\r':
+				b = append(b, '\\', 'r')
+			case c == '\t':
+				b = append(b, '\\', 't')
+			case c < 0x20:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			default:
+				b = append(b, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, "\ufffd"...)
+		} else {
+			b = append(b, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(b, '"')
+}
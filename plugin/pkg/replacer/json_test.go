@@ -0,0 +1,107 @@
+package replacer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func TestJSON(t *testing.T) {
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	r := new(dns.Msg)
+	r.SetQuestion("example.org.", dns.TypeHINFO)
+	r.Id = 1053
+	r.SetEdns0(4097, true)
+	state := request.Request{W: w, Req: r}
+
+	m := metadata.Metadata{
+		Zones: []string{"."},
+		Providers: []metadata.Provider{
+			testProvider{"test/quote": func() string { return "a \"quoted\"\n\x01value" }},
+			testProvider{"test/utf8": func() string { return "caf\xc3\xa9 \xff" }},
+		},
+	}
+	ctx := m.Collect(context.TODO(), state)
+
+	resp := new(dns.Msg)
+	resp.SetRcode(r, dns.RcodeNameError)
+	resp.Authoritative = true
+	w.WriteMsg(resp)
+
+	out := New().JSON(ctx, state, w)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("Expected valid JSON, got %s: %s", err, out)
+	}
+
+	expected := map[string]interface{}{
+		"remote":  "10.240.0.1",
+		"port":    float64(40212),
+		"local":   "127.0.0.1",
+		"id":      float64(1053),
+		"opcode":  float64(0),
+		"type":    "HINFO",
+		"class":   "IN",
+		"name":    "example.org.",
+		"proto":   "udp",
+		"size":    float64(r.Len()),
+		"do":      true,
+		"bufsize": float64(4097),
+		"rcode":   "NXDOMAIN",
+		"rflags":  "qr,aa,rd",
+		"rsize":   float64(w.Len),
+	}
+	for k, v := range expected {
+		if got[k] != v {
+			t.Errorf("Expected %s to be %v, got %v", k, v, got[k])
+		}
+	}
+	if _, ok := got["duration"].(float64); !ok {
+		t.Errorf("Expected duration to be a number, got %v", got["duration"])
+	}
+
+	md, ok := got["metadata"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected metadata object, got %v", got["metadata"])
+	}
+	if x := md["test/quote"]; x != "a \"quoted\"\n\x01value" {
+		t.Errorf("Expected quoted metadata to round trip, got %q", x)
+	}
+	if x := md["test/utf8"]; x != "caf\u00e9 \ufffd" {
+		t.Errorf("Expected invalid UTF-8 to be replaced, got %q", x)
+	}
+}
+
+func TestJSONNoResponse(t *testing.T) {
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	r := new(dns.Msg)
+	r.SetQuestion("example.org.", dns.TypeA)
+	state := request.Request{W: w, Req: r}
+
+	out := New().JSON(context.TODO(), state, w)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("Expected valid JSON, got %s: %s", err, out)
+	}
+	for _, k := range []string{"rcode", "rflags", "metadata"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("Expected no %s, got %v", k, got[k])
+		}
+	}
+	if got["name"] != "example.org." {
+		t.Errorf("Expected name to be example.org., got %v", got["name"])
+	}
+
+	if out := New().JSON(context.TODO(), request.Request{}, nil); out != "{}" {
+		t.Errorf("Expected empty object, got %s", out)
+	}
+}
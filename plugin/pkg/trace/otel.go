@@ -0,0 +1,74 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/coredns/coredns/plugin"
+
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// Propagator propagates the OpenTelemetry span context using the W3C Trace Context headers.
+var Propagator propagation.TextMapPropagator = propagation.TraceContext{}
+
+// StartSpan starts a span named name as a child of the OpenTelemetry span in ctx, if there is one and it
+// is recording. If not, ctx is returned as is, together with a nil span.
+func StartSpan(ctx context.Context, name string, opts ...oteltrace.SpanStartOption) (context.Context, oteltrace.Span) {
+	span := oteltrace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return ctx, nil
+	}
+	return span.TracerProvider().Tracer(plugin.TracerName).Start(ctx, name, opts...)
+}
+
+// OutgoingContext returns ctx with the span context in ctx added to the outgoing gRPC metadata.
+func OutgoingContext(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	Propagator.Inject(ctx, MetadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// IncomingContext returns ctx with the span context from the incoming gRPC metadata, if any.
+func IncomingContext(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return Propagator.Extract(ctx, MetadataCarrier(md))
+}
+
+// IncomingHTTPContext returns ctx with the span context from the HTTP headers h, if any.
+func IncomingHTTPContext(ctx context.Context, h http.Header) context.Context {
+	return Propagator.Extract(ctx, propagation.HeaderCarrier(h))
+}
+
+// MetadataCarrier adapts gRPC metadata to a propagation.TextMapCarrier.
+type MetadataCarrier metadata.MD
+
+// Get implements propagation.TextMapCarrier.
+func (m MetadataCarrier) Get(key string) string {
+	if v := metadata.MD(m).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Set implements propagation.TextMapCarrier.
+func (m MetadataCarrier) Set(key, value string) { metadata.MD(m).Set(key, value) }
+
+// Keys implements propagation.TextMapCarrier.
+func (m MetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/miekg/dns"
 	ot "github.com/opentracing/opentracing-go"
 	"github.com/prometheus/client_golang/prometheus"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 type (
@@ -76,6 +77,10 @@ func NextOrFailure(name string, next Handler, ctx context.Context, w dns.Respons
 			child := span.Tracer().StartSpan(next.Name(), ot.ChildOf(span.Context()))
 			defer child.Finish()
 			ctx = ot.ContextWithSpan(ctx, child)
+		} else if span := oteltrace.SpanFromContext(ctx); span.IsRecording() {
+			var child oteltrace.Span
+			ctx, child = span.TracerProvider().Tracer(TracerName).Start(ctx, next.Name())
+			defer child.End()
 		}
 		return next.ServeDNS(ctx, w, r)
 	}
@@ -99,6 +104,9 @@ func ClientWrite(rcode int) bool {
 	return true
 }
 
+// TracerName is the name of the OpenTelemetry tracer used for the spans of CoreDNS.
+const TracerName = "github.com/coredns/coredns"
+
 // Namespace is the namespace used for the metrics.
 const Namespace = "coredns"
 
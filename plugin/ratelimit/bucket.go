@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket that is refilled with rate tokens per second, up to rate tokens. Every
+// response takes a token. The balance can go negative up to rate * window tokens, so a client that
+// keeps sending queries while being limited stays limited: it is only allowed again once the debt has
+// been repaid.
+type bucket struct {
+	sync.Mutex
+	balance float64
+	last    time.Time
+	limited int // number of limited responses, used for slipping.
+}
+
+func newBucket(rate float64, now time.Time) *bucket {
+	return &bucket{balance: rate, last: now}
+}
+
+// take takes a token from the bucket and returns true if the response is allowed. When it isn't,
+// the number of limited responses so far is returned as well.
+func (b *bucket) take(now time.Time, rate float64, window time.Duration) (bool, int) {
+	b.Lock()
+	defer b.Unlock()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.balance += elapsed * rate
+		if b.balance > rate {
+			b.balance = rate
+		}
+	}
+	b.last = now
+
+	b.balance--
+	if debt := -rate * window.Seconds(); b.balance < debt {
+		b.balance = debt
+	}
+	if b.balance >= 0 {
+		b.limited = 0
+		return true, 0
+	}
+	b.limited++
+	return false, b.limited
+}
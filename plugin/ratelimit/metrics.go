@@ -0,0 +1,25 @@
+package ratelimit
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// dropped is the number of responses that were dropped because of the rate limit.
+	dropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "ratelimit",
+		Name:      "dropped_total",
+		Help:      "Counter of responses dropped because of the rate limit.",
+	}, []string{"server", "zone", "view", "type"})
+	// slipped is the number of responses that were replaced by a truncated response because of the rate limit.
+	slipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "ratelimit",
+		Name:      "slipped_total",
+		Help:      "Counter of responses replaced by a truncated response because of the rate limit.",
+	}, []string{"server", "zone", "view", "type"})
+)
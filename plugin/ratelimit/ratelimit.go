@@ -0,0 +1,210 @@
+// Package ratelimit implements a plugin that does response rate limiting (RRL).
+//
+// See: https://www.isc.org/docs/ISC-TN-2012-1.txt
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/request"
+
+	"github.com/infobloxopen/go-trees/iptree"
+	"github.com/miekg/dns"
+)
+
+// Response types, these are also used as label values in the metrics.
+const (
+	typeResponse = "response"
+	typeReferral = "referral"
+	typeNoData   = "nodata"
+	typeNXDomain = "nxdomain"
+	typeError    = "error"
+)
+
+// RateLimit limits the rate of identical responses sent to a client network over UDP.
+type RateLimit struct {
+	Next  plugin.Handler
+	Zones []string
+
+	rates  map[string]float64 // responses per second per response type, 0 means unlimited.
+	window time.Duration
+	slip   int
+	v4, v6 int // prefix lengths that make up a client network.
+
+	allowlist *iptree.Tree // clients that aren't limited, may be nil.
+
+	buckets *cache.Cache
+	now     func() time.Time
+}
+
+// New returns a RateLimit with the default settings.
+func New() *RateLimit {
+	rl := &RateLimit{
+		rates:   map[string]float64{},
+		window:  defaultWindow,
+		slip:    defaultSlip,
+		v4:      defaultIPv4Prefix,
+		v6:      defaultIPv6Prefix,
+		buckets: cache.New(defaultTableSize),
+		now:     time.Now,
+	}
+	for _, t := range []string{typeResponse, typeReferral, typeNoData, typeNXDomain, typeError} {
+		rl.rates[t] = defaultRate
+	}
+	return rl
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (rl *RateLimit) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	zone := plugin.Zones(rl.Zones).Matches(state.Name())
+	// Only UDP is limited: TCP can't be spoofed, so can't be used for reflection.
+	if zone == "" || state.Proto() != "udp" {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+	ip := clientIP(state)
+	if ip == nil || rl.allowed(ip) {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+
+	rw := &ResponseWriter{
+		ResponseWriter: w,
+		rl:             rl,
+		state:          state,
+		network:        rl.network(ip),
+		server:         metrics.WithServer(ctx),
+		zone:           zone,
+		view:           metrics.WithView(ctx),
+	}
+	return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, rw, r)
+}
+
+// allowed returns true if ip is on the allowlist.
+func (rl *RateLimit) allowed(ip net.IP) bool {
+	if rl.allowlist == nil {
+		return false
+	}
+	_, ok := rl.allowlist.GetByIP(ip)
+	return ok
+}
+
+// network returns the client network ip is part of.
+func (rl *RateLimit) network(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(rl.v4, 32)).String() + "/" + strconv.Itoa(rl.v4)
+	}
+	return ip.Mask(net.CIDRMask(rl.v6, 128)).String() + "/" + strconv.Itoa(rl.v6)
+}
+
+// take takes a token from the bucket for the key and returns true if the response is allowed. If it
+// isn't, it also returns true when the response should slip.
+func (rl *RateLimit) take(key string, rate float64) (allowed, slip bool) {
+	now := rl.now()
+	k := cache.Hash([]byte(key))
+
+	v, _ := rl.buckets.GetOrAdd(k, func() interface{} { return newBucket(rate, now) })
+
+	ok, n := v.(*bucket).take(now, rate, rl.window)
+	if ok {
+		return true, false
+	}
+	return false, rl.slip > 0 && n%rl.slip == 0
+}
+
+// ResponseWriter applies the rate limit to the response that is written.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	rl    *RateLimit
+	state request.Request
+
+	network string
+	server  string
+	zone    string
+	view    string
+}
+
+// WriteMsg implements the dns.ResponseWriter interface. When the response is limited, it is dropped or,
+// when it slips, replaced with an empty truncated response so a legitimate client retries over TCP.
+func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	typ, name := classify(res)
+	rate := w.rl.rates[typ]
+	if rate == 0 {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	key := w.network + "\x00" + typ + "\x00" + strings.ToLower(name)
+	if typ == typeResponse {
+		key += "\x00" + strconv.Itoa(int(w.state.QType()))
+	}
+	allowed, slip := w.rl.take(key, rate)
+	if allowed {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+	if !slip {
+		dropped.WithLabelValues(w.server, w.zone, w.view, typ).Inc()
+		return nil
+	}
+
+	slipped.WithLabelValues(w.server, w.zone, w.view, typ).Inc()
+	m := new(dns.Msg)
+	m.SetReply(w.state.Req)
+	m.Truncated = true
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+// classify returns the response type of m and the name that identifies it. Like BIND, NXDOMAIN
+// responses are identified by the zone (the owner of the SOA record) instead of the query name, so that
+// random names in the same zone end up in the same bucket. Errors are identified by the client only.
+func classify(m *dns.Msg) (string, string) {
+	qname := ""
+	if len(m.Question) > 0 {
+		qname = m.Question[0].Name
+	}
+
+	switch m.Rcode {
+	case dns.RcodeSuccess:
+		if len(m.Answer) > 0 {
+			return typeResponse, qname
+		}
+		var ns dns.RR
+		for _, rr := range m.Ns {
+			switch rr.Header().Rrtype {
+			case dns.TypeSOA:
+				return typeNoData, qname
+			case dns.TypeNS:
+				ns = rr
+			}
+		}
+		if ns != nil {
+			return typeReferral, ns.Header().Name
+		}
+		return typeNoData, qname
+	case dns.RcodeNameError:
+		for _, rr := range m.Ns {
+			if rr.Header().Rrtype == dns.TypeSOA {
+				return typeNXDomain, rr.Header().Name
+			}
+		}
+		return typeNXDomain, qname
+	}
+	return typeError, ""
+}
+
+// clientIP returns the address of the client, or nil if it can't be parsed.
+func clientIP(state request.Request) net.IP {
+	addr := state.IP()
+	if i := strings.IndexByte(addr, '%'); i >= 0 {
+		addr = addr[:i]
+	}
+	return net.ParseIP(addr)
+}
+
+// Name implements the plugin.Handler interface.
+func (rl *RateLimit) Name() string { return "ratelimit" }
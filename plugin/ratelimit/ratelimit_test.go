@@ -0,0 +1,169 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/infobloxopen/go-trees/iptree"
+	"github.com/miekg/dns"
+)
+
+func backend(rcode int) plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		switch rcode {
+		case dns.RcodeSuccess:
+			m.Answer = []dns.RR{test.A(r.Question[0].Name + " 300 IN A 192.0.2.1")}
+		case dns.RcodeNameError:
+			m.Ns = []dns.RR{test.SOA("example.org. 300 IN SOA ns.example.org. hostmaster.example.org. 1 7200 3600 1209600 300")}
+		}
+		w.WriteMsg(m)
+		return rcode, nil
+	})
+}
+
+// query sends a query for qname from client to rl and returns the response, nil if it was dropped.
+func query(rl *RateLimit, qname, client string, tcp bool) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(qname, dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: client, TCP: tcp})
+	rl.ServeDNS(context.TODO(), rec, m)
+	return rec.Msg
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Now()
+	rl := New()
+	rl.Zones = []string{"."}
+	rl.now = func() time.Time { return now }
+	rl.rates[typeResponse] = 2
+	rl.slip = 2
+	rl.Next = backend(dns.RcodeSuccess)
+
+	// The first 2 responses are allowed, then every other response slips.
+	for i, expected := range []string{"ok", "ok", "drop", "slip", "drop", "slip"} {
+		m := query(rl, "www.example.org.", "192.0.2.1", false)
+		got := "ok"
+		switch {
+		case m == nil:
+			got = "drop"
+		case m.Truncated && len(m.Answer) == 0:
+			got = "slip"
+		}
+		if got != expected {
+			t.Errorf("Query %d: expected %s, got %s", i, expected, got)
+		}
+	}
+
+	// Same network, so limited as well.
+	if m := query(rl, "www.example.org.", "192.0.2.200", false); m != nil && !m.Truncated {
+		t.Errorf("Expected a client in the same /24 to be limited")
+	}
+	// Other network, other name or TCP aren't limited.
+	if m := query(rl, "www.example.org.", "192.0.3.1", false); m == nil || m.Truncated {
+		t.Errorf("Expected a client in another network to not be limited")
+	}
+	if m := query(rl, "mail.example.org.", "192.0.2.1", false); m == nil || m.Truncated {
+		t.Errorf("Expected another name to not be limited")
+	}
+	if m := query(rl, "www.example.org.", "192.0.2.1", true); m == nil || m.Truncated {
+		t.Errorf("Expected TCP to not be limited")
+	}
+
+	// The debt is repaid over time, after 15 (window) seconds we're allowed again.
+	now = now.Add(time.Second)
+	if m := query(rl, "www.example.org.", "192.0.2.1", false); m != nil && !m.Truncated {
+		t.Errorf("Expected client to still be limited")
+	}
+	now = now.Add(defaultWindow)
+	if m := query(rl, "www.example.org.", "192.0.2.1", false); m == nil || m.Truncated {
+		t.Errorf("Expected client to be allowed again")
+	}
+}
+
+func TestRateLimitNXDomain(t *testing.T) {
+	rl := New()
+	rl.Zones = []string{"."}
+	rl.rates[typeNXDomain] = 1
+	rl.slip = 0
+	rl.Next = backend(dns.RcodeNameError)
+
+	// Random names in the same zone share the bucket.
+	if m := query(rl, "a.example.org.", "2001:db8::1", false); m == nil {
+		t.Fatalf("Expected the first response to be allowed")
+	}
+	if m := query(rl, "b.example.org.", "2001:db8:0:ff::1", false); m != nil {
+		t.Errorf("Expected the response to be dropped")
+	}
+	if m := query(rl, "b.example.org.", "2001:db8:0:100::1", false); m == nil {
+		t.Errorf("Expected a client in another /56 to be allowed")
+	}
+}
+
+func TestRateLimitUnlimited(t *testing.T) {
+	rl := New()
+	rl.Zones = []string{"example.org."}
+	rl.rates[typeResponse] = 0
+	rl.rates[typeError] = 1
+	rl.Next = backend(dns.RcodeSuccess)
+
+	for i := 0; i < 10; i++ {
+		if m := query(rl, "www.example.org.", "192.0.2.1", false); m == nil || m.Truncated {
+			t.Fatalf("Expected responses to be unlimited")
+		}
+		if m := query(rl, "www.example.net.", "192.0.2.1", false); m == nil || m.Truncated {
+			t.Fatalf("Expected queries outside of the zones to be unlimited")
+		}
+	}
+}
+
+func TestRateLimitAllowlist(t *testing.T) {
+	rl := New()
+	rl.Zones = []string{"."}
+	rl.rates[typeResponse] = 1
+	rl.allowlist = iptree.NewTree()
+	n, _ := parseNet("192.0.2.0/24")
+	rl.allowlist.InplaceInsertNet(n, struct{}{})
+	rl.Next = backend(dns.RcodeSuccess)
+
+	for i := 0; i < 10; i++ {
+		if m := query(rl, "www.example.org.", "192.0.2.1", false); m == nil || m.Truncated {
+			t.Fatalf("Expected allowlisted client to be unlimited")
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	soa := test.SOA("example.org. 300 IN SOA ns.example.org. hostmaster.example.org. 1 7200 3600 1209600 300")
+	tests := []struct {
+		rcode  int
+		answer []dns.RR
+		ns     []dns.RR
+		typ    string
+		name   string
+	}{
+		{dns.RcodeSuccess, []dns.RR{test.A("www.example.org. 300 IN A 192.0.2.1")}, nil, typeResponse, "www.example.org."},
+		{dns.RcodeSuccess, nil, []dns.RR{soa}, typeNoData, "www.example.org."},
+		{dns.RcodeSuccess, nil, nil, typeNoData, "www.example.org."},
+		{dns.RcodeSuccess, nil, []dns.RR{test.NS("sub.example.org. 300 IN NS ns.sub.example.org.")}, typeReferral, "sub.example.org."},
+		{dns.RcodeNameError, nil, []dns.RR{soa}, typeNXDomain, "example.org."},
+		{dns.RcodeNameError, nil, nil, typeNXDomain, "www.example.org."},
+		{dns.RcodeServerFailure, nil, nil, typeError, ""},
+		{dns.RcodeRefused, nil, nil, typeError, ""},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("www.example.org.", dns.TypeA)
+		m.Rcode = tc.rcode
+		m.Answer, m.Ns = tc.answer, tc.ns
+		if typ, name := classify(m); typ != tc.typ || name != tc.name {
+			t.Errorf("Test %d: expected %s %q, got %s %q", i, tc.typ, tc.name, typ, name)
+		}
+	}
+}
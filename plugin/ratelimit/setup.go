@@ -0,0 +1,185 @@
+package ratelimit
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+
+	"github.com/infobloxopen/go-trees/iptree"
+)
+
+const (
+	defaultRate       = 10
+	defaultWindow     = 15 * time.Second
+	defaultSlip       = 2
+	defaultIPv4Prefix = 24
+	defaultIPv6Prefix = 56
+	defaultTableSize  = 100000
+	maxSlip           = 10
+)
+
+func init() { plugin.Register("ratelimit", setup) }
+
+func setup(c *caddy.Controller) error {
+	rl, err := rateLimitParse(c)
+	if err != nil {
+		return plugin.Error("ratelimit", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		rl.Next = next
+		return rl
+	})
+
+	return nil
+}
+
+func rateLimitParse(c *caddy.Controller) (*RateLimit, error) {
+	rl := New()
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		rl.Zones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+
+		// Rates that aren't set default to the rate for responses.
+		rates := map[string]float64{}
+		for c.NextBlock() {
+			if typ, ok := rateProperties[c.Val()]; ok {
+				n, err := intArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if n < 0 {
+					return nil, c.Errf("rate can't be negative: %d", n)
+				}
+				rates[typ] = float64(n)
+				continue
+			}
+
+			switch c.Val() {
+			case "window":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if d < time.Second {
+					return nil, c.Errf("window must be at least 1s: %s", d)
+				}
+				rl.window = d
+			case "slip":
+				n, err := intArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if n < 0 || n > maxSlip {
+					return nil, c.Errf("slip must be between 0 and %d: %d", maxSlip, n)
+				}
+				rl.slip = n
+			case "ipv4-prefix-length":
+				n, err := intArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if n < 1 || n > 32 {
+					return nil, c.Errf("invalid IPv4 prefix length: %d", n)
+				}
+				rl.v4 = n
+			case "ipv6-prefix-length":
+				n, err := intArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if n < 1 || n > 128 {
+					return nil, c.Errf("invalid IPv6 prefix length: %d", n)
+				}
+				rl.v6 = n
+			case "allowlist":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				if rl.allowlist == nil {
+					rl.allowlist = iptree.NewTree()
+				}
+				for _, a := range args {
+					n, err := parseNet(a)
+					if err != nil {
+						return nil, c.Errf("invalid address or network %q", a)
+					}
+					rl.allowlist.InplaceInsertNet(n, struct{}{})
+				}
+			case "max-table-size":
+				n, err := intArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if n < 1 {
+					return nil, c.Errf("max-table-size must be positive: %d", n)
+				}
+				rl.buckets = cache.New(n)
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+
+		if r, ok := rates[typeResponse]; ok {
+			for t := range rl.rates {
+				rl.rates[t] = r
+			}
+		}
+		for t, r := range rates {
+			rl.rates[t] = r
+		}
+	}
+	return rl, nil
+}
+
+// rateProperties maps the properties that set a rate to the response type they're for.
+var rateProperties = map[string]string{
+	"responses-per-second": typeResponse,
+	"referrals-per-second": typeReferral,
+	"nodata-per-second":    typeNoData,
+	"nxdomains-per-second": typeNXDomain,
+	"errors-per-second":    typeError,
+}
+
+// intArg returns the single integer argument of the current property.
+func intArg(c *caddy.Controller) (int, error) {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, c.Errf("invalid number %q", args[0])
+	}
+	return n, nil
+}
+
+// parseNet parses a CIDR or a single IP address.
+func parseNet(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		if strings.Contains(s, ":") {
+			s += "/128"
+		} else {
+			s += "/32"
+		}
+	}
+	_, n, err := net.ParseCIDR(s)
+	return n, err
+}
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		rates     map[string]float64
+		window    time.Duration
+		slip      int
+		v4, v6    int
+	}{
+		{`ratelimit`, false, map[string]float64{typeResponse: 10, typeReferral: 10, typeNoData: 10, typeNXDomain: 10, typeError: 10}, 15 * time.Second, 2, 24, 56},
+		{`ratelimit {
+			responses-per-second 5
+			errors-per-second 0
+			nxdomains-per-second 2
+		}`, false, map[string]float64{typeResponse: 5, typeReferral: 5, typeNoData: 5, typeNXDomain: 2, typeError: 0}, 15 * time.Second, 2, 24, 56},
+		{`ratelimit example.org {
+			window 5s
+			slip 0
+			ipv4-prefix-length 32
+			ipv6-prefix-length 64
+			allowlist 192.0.2.0/24 2001:db8::1
+			max-table-size 1000
+		}`, false, map[string]float64{typeResponse: 10, typeReferral: 10, typeNoData: 10, typeNXDomain: 10, typeError: 10}, 5 * time.Second, 0, 32, 64},
+		// fails
+		{`ratelimit {
+			responses-per-second
+		}`, true, nil, 0, 0, 0, 0},
+		{`ratelimit {
+			responses-per-second -1
+		}`, true, nil, 0, 0, 0, 0},
+		{`ratelimit {
+			nodata-per-second a
+		}`, true, nil, 0, 0, 0, 0},
+		{`ratelimit {
+			window 100ms
+		}`, true, nil, 0, 0, 0, 0},
+		{`ratelimit {
+			slip 11
+		}`, true, nil, 0, 0, 0, 0},
+		{`ratelimit {
+			ipv4-prefix-length 33
+		}`, true, nil, 0, 0, 0, 0},
+		{`ratelimit {
+			ipv6-prefix-length 0
+		}`, true, nil, 0, 0, 0, 0},
+		{`ratelimit {
+			allowlist 192.0.2.0/33
+		}`, true, nil, 0, 0, 0, 0},
+		{`ratelimit {
+			allowlist
+		}`, true, nil, 0, 0, 0, 0},
+		{`ratelimit {
+			max-table-size 0
+		}`, true, nil, 0, 0, 0, 0},
+		{`ratelimit {
+			foo
+		}`, true, nil, 0, 0, 0, 0},
+		{`ratelimit
+		ratelimit`, true, nil, 0, 0, 0, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		rl, err := rateLimitParse(c)
+
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+		}
+		if err != nil {
+			if !test.shouldErr {
+				t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			}
+			continue
+		}
+		for typ, rate := range test.rates {
+			if rl.rates[typ] != rate {
+				t.Errorf("Test %d: expected %s rate %f, got %f", i, typ, rate, rl.rates[typ])
+			}
+		}
+		if rl.window != test.window {
+			t.Errorf("Test %d: expected window %s, got %s", i, test.window, rl.window)
+		}
+		if rl.slip != test.slip {
+			t.Errorf("Test %d: expected slip %d, got %d", i, test.slip, rl.slip)
+		}
+		if rl.v4 != test.v4 || rl.v6 != test.v6 {
+			t.Errorf("Test %d: expected prefix lengths %d and %d, got %d and %d", i, test.v4, test.v6, rl.v4, rl.v6)
+		}
+	}
+
+	rl, _ := rateLimitParse(caddy.NewTestController("dns", `ratelimit {
+		allowlist 192.0.2.0/24 2001:db8::1
+	}`))
+	if !rl.allowed(net.ParseIP("192.0.2.10")) || !rl.allowed(net.ParseIP("2001:db8::1")) || rl.allowed(net.ParseIP("2001:db8::2")) {
+		t.Errorf("Unexpected allowlist")
+	}
+}
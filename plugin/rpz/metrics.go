@@ -0,0 +1,16 @@
+package rpz
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// hits is the number of queries that matched a trigger in a policy zone.
+var hits = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "rpz",
+	Name:      "hits_total",
+	Help:      "Counter of queries that matched a trigger in a response policy zone.",
+}, []string{"server", "policy", "trigger", "action", "view"})
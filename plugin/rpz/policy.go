@@ -0,0 +1,272 @@
+package rpz
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/file/tree"
+
+	"github.com/infobloxopen/go-trees/iptree"
+	"github.com/miekg/dns"
+)
+
+// Triggers, these are also used as label values in the metrics.
+const (
+	triggerClientIP = "client-ip"
+	triggerQName    = "qname"
+	triggerIP       = "ip"
+	triggerNSDName  = "nsdname"
+)
+
+// Actions, these are also used as label values in the metrics.
+const (
+	actionNXDomain  = "nxdomain"
+	actionNoData    = "nodata"
+	actionPassthru  = "passthru"
+	actionDrop      = "drop"
+	actionTCPOnly   = "tcp-only"
+	actionCNAME     = "cname"
+	actionLocalData = "local-data"
+)
+
+// policy is a response policy zone. The zone is kept up to date by the file (reload) or secondary
+// (transfer) machinery, the triggers in it are indexed every time the SOA serial changes.
+type policy struct {
+	origin string
+	zone   *file.Zone
+
+	mu  sync.RWMutex
+	idx *index
+}
+
+// rule is the set of records of a trigger, these determine the action.
+type rule struct {
+	owner string // owner name of the trigger in the policy zone.
+	rrs   []dns.RR
+}
+
+// index holds all triggers of a policy zone.
+type index struct {
+	serial   uint32
+	soa      *dns.SOA // SOA of the policy zone, added to synthesized denials.
+	qname    names
+	nsdname  names
+	clientIP *iptree.Tree // values are *rule.
+	ip       *iptree.Tree
+	response bool // true if there are QNAME (for the CNAME targets), IP or NSDNAME triggers, these need the response.
+}
+
+// names holds the rules for name triggers, wildcards are stored without the leading "*." label.
+type names struct {
+	exact    map[string]*rule
+	wildcard map[string]*rule
+}
+
+func newPolicy(origin string, z *file.Zone) *policy { return &policy{origin: origin, zone: z} }
+
+// index returns the triggers of p, it returns nil when the zone hasn't been loaded yet.
+func (p *policy) index() *index {
+	serial := p.zone.SOASerialIfDefined()
+	if serial < 0 {
+		return nil
+	}
+
+	p.mu.RLock()
+	idx := p.idx
+	p.mu.RUnlock()
+	if idx != nil && int64(idx.serial) == serial {
+		return idx
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.idx != nil && int64(p.idx.serial) == serial {
+		return p.idx
+	}
+	p.idx = p.build()
+	return p.idx
+}
+
+// build indexes the records in the policy zone.
+func (p *policy) build() *index {
+	p.zone.RLock()
+	defer p.zone.RUnlock()
+
+	origin := p.origin
+	idx := &index{
+		serial:   p.zone.Apex.SOA.Serial,
+		soa:      dns.Copy(p.zone.Apex.SOA).(*dns.SOA),
+		qname:    names{exact: map[string]*rule{}, wildcard: map[string]*rule{}},
+		nsdname:  names{exact: map[string]*rule{}, wildcard: map[string]*rule{}},
+		clientIP: iptree.NewTree(),
+		ip:       iptree.NewTree(),
+	}
+	skipped := 0
+	p.zone.Tree.Walk(func(e *tree.Elem, _ map[uint16][]dns.RR) error {
+		owner := e.Name()
+		if !dns.IsSubDomain(origin, owner) || dns.CountLabel(owner) == dns.CountLabel(origin) {
+			return nil
+		}
+		r := &rule{owner: owner, rrs: e.All()}
+		rel := strings.ToLower(owner[:len(owner)-len(origin)-1])
+
+		var err error
+		switch {
+		case strings.HasSuffix(rel, ".rpz-client-ip"):
+			err = insertNet(idx.clientIP, strings.TrimSuffix(rel, ".rpz-client-ip"), r)
+		case strings.HasSuffix(rel, ".rpz-ip"):
+			err = insertNet(idx.ip, strings.TrimSuffix(rel, ".rpz-ip"), r)
+			idx.response = idx.response || err == nil
+		case strings.HasSuffix(rel, ".rpz-nsdname"):
+			idx.nsdname.insert(strings.TrimSuffix(rel, ".rpz-nsdname"), r)
+			idx.response = true
+		case strings.HasSuffix(rel, ".rpz-nsip"):
+			err = fmt.Errorf("unsupported trigger")
+		default:
+			idx.qname.insert(rel, r)
+			idx.response = true
+		}
+		if err != nil {
+			skipped++
+			log.Debugf("Skipping %q in policy zone %q: %s", owner, origin, err)
+		}
+		return nil
+	})
+	if skipped > 0 {
+		log.Warningf("Skipped %d unsupported or invalid triggers in policy zone %q", skipped, origin)
+	}
+	return idx
+}
+
+func (n names) insert(rel string, r *rule) {
+	if rel == "*" {
+		n.wildcard["."] = r
+		return
+	}
+	if strings.HasPrefix(rel, "*.") {
+		n.wildcard[rel[2:]+"."] = r
+		return
+	}
+	n.exact[rel+"."] = r
+}
+
+// match returns the rule for name: an exact match, or else the closest wildcard.
+func (n names) match(name string) *rule {
+	name = strings.ToLower(name)
+	if r, ok := n.exact[name]; ok {
+		return r
+	}
+	if len(n.wildcard) == 0 {
+		return nil
+	}
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		if off == 0 {
+			continue // a wildcard doesn't match the name it's defined for.
+		}
+		if r, ok := n.wildcard[name[off:]]; ok {
+			return r
+		}
+	}
+	if r, ok := n.wildcard["."]; ok && name != "." {
+		return r
+	}
+	return nil
+}
+
+// insertNet inserts the rule for the trigger with name rel (without the rpz-ip or rpz-client-ip labels) in t.
+func insertNet(t *iptree.Tree, rel string, r *rule) error {
+	n, err := triggerNet(rel)
+	if err != nil {
+		return err
+	}
+	t.InplaceInsertNet(n, r)
+	return nil
+}
+
+// matchIP returns the rule in t with the longest prefix containing ip.
+func matchIP(t *iptree.Tree, ip net.IP) *rule {
+	if ip == nil {
+		return nil
+	}
+	v, ok := t.GetByIP(ip)
+	if !ok {
+		return nil
+	}
+	return v.(*rule)
+}
+
+// triggerNet parses the network from an IP trigger name: the prefix length followed by the labels of
+// the address in reverse order, i.e. "24.0.2.0.192" for 192.0.2.0/24. In IPv6 addresses "zz" stands
+// for "::", i.e. "48.zz.db8.2001" is 2001:db8::/48.
+func triggerNet(rel string) (*net.IPNet, error) {
+	labels := strings.Split(rel, ".")
+	if len(labels) < 2 {
+		return nil, fmt.Errorf("too few labels")
+	}
+	bits, err := strconv.Atoi(labels[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefix length %q", labels[0])
+	}
+	addr := make([]string, 0, len(labels)-1)
+	for i := len(labels) - 1; i > 0; i-- {
+		addr = append(addr, labels[i])
+	}
+
+	if len(addr) == 4 {
+		if ip := net.ParseIP(strings.Join(addr, ".")).To4(); ip != nil {
+			if bits < 1 || bits > 32 {
+				return nil, fmt.Errorf("invalid IPv4 prefix length %d", bits)
+			}
+			mask := net.CIDRMask(bits, 32)
+			return &net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
+		}
+	}
+
+	for i := range addr {
+		if addr[i] == "zz" {
+			addr[i] = ""
+		}
+	}
+	s := strings.Join(addr, ":")
+	if strings.HasPrefix(s, ":") {
+		s = ":" + s
+	}
+	if strings.HasSuffix(s, ":") {
+		s += ":"
+	}
+	ip := net.ParseIP(s)
+	if ip == nil || ip.To4() != nil {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+	if bits < 1 || bits > 128 {
+		return nil, fmt.Errorf("invalid IPv6 prefix length %d", bits)
+	}
+	mask := net.CIDRMask(bits, 128)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
+}
+
+// action returns the action for the rule.
+func (r *rule) action() string {
+	if len(r.rrs) == 1 {
+		if c, ok := r.rrs[0].(*dns.CNAME); ok {
+			switch strings.ToLower(c.Target) {
+			case ".":
+				return actionNXDomain
+			case "*.":
+				return actionNoData
+			case "rpz-passthru.":
+				return actionPassthru
+			case "rpz-drop.":
+				return actionDrop
+			case "rpz-tcp-only.":
+				return actionTCPOnly
+			}
+			return actionCNAME
+		}
+	}
+	return actionLocalData
+}
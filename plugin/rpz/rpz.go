@@ -0,0 +1,251 @@
+// Package rpz implements a plugin that applies response policy zones (RPZ) to queries and responses.
+//
+// See: https://datatracker.ietf.org/doc/html/draft-vixie-dnsop-dns-rpz
+package rpz
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/nonwriter"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// UpstreamInt wraps the Upstream API for dependency injection during testing.
+type UpstreamInt interface {
+	Lookup(ctx context.Context, state request.Request, name string, typ uint16) (*dns.Msg, error)
+}
+
+// RPZ applies the policies of one or more response policy zones.
+type RPZ struct {
+	Next     plugin.Handler
+	Zones    []string
+	Upstream UpstreamInt // resolves the targets of local-data CNAMEs.
+
+	policies []*policy // in order of precedence.
+}
+
+// hit is a trigger that matched.
+type hit struct {
+	policy  *policy
+	idx     *index
+	trigger string
+	rule    *rule
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (rz *RPZ) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if plugin.Zones(rz.Zones).Matches(state.Name()) == "" {
+		return plugin.NextOrFailure(rz.Name(), rz.Next, ctx, w, r)
+	}
+
+	// Client-IP and QNAME triggers are checked before the query is resolved. As a policy zone earlier
+	// in the list takes precedence, we can only apply such a hit right away if none of the preceding
+	// zones has triggers that need the response.
+	pre, n := rz.queryHit(state)
+	if pre != nil && !rz.responseTriggers(n) {
+		return rz.apply(ctx, state, pre, nil)
+	}
+
+	nw := nonwriter.New(w)
+	rc, err := plugin.NextOrFailure(rz.Name(), rz.Next, ctx, nw, r)
+	if nw.Msg == nil {
+		if pre != nil {
+			return rz.apply(ctx, state, pre, nil)
+		}
+		return rc, err
+	}
+
+	if h := rz.responseHit(nw.Msg, n); h != nil {
+		return rz.apply(ctx, state, h, nw.Msg)
+	}
+	if pre != nil {
+		return rz.apply(ctx, state, pre, nw.Msg)
+	}
+	w.WriteMsg(nw.Msg)
+	return rc, err
+}
+
+// queryHit returns the first client-IP or QNAME trigger that matches the query and the position of
+// its policy zone. If none matches it returns nil and the number of policy zones.
+func (rz *RPZ) queryHit(state request.Request) (*hit, int) {
+	addr := state.IP()
+	if i := strings.IndexByte(addr, '%'); i >= 0 {
+		addr = addr[:i]
+	}
+	ip := net.ParseIP(addr)
+	for i, p := range rz.policies {
+		idx := p.index()
+		if idx == nil {
+			continue
+		}
+		if r := matchIP(idx.clientIP, ip); r != nil {
+			return &hit{policy: p, idx: idx, trigger: triggerClientIP, rule: r}, i
+		}
+		if r := idx.qname.match(state.Name()); r != nil {
+			return &hit{policy: p, idx: idx, trigger: triggerQName, rule: r}, i
+		}
+	}
+	return nil, len(rz.policies)
+}
+
+// responseTriggers returns true if one of the first n policy zones has triggers that are checked against
+// the response.
+func (rz *RPZ) responseTriggers(n int) bool {
+	for _, p := range rz.policies[:n] {
+		if idx := p.index(); idx != nil && idx.response {
+			return true
+		}
+	}
+	return false
+}
+
+// responseHit returns the first trigger of the first n policy zones that matches the response m: a
+// QNAME trigger for a CNAME target in the answer section, an IP trigger for the addresses in the answer
+// section, or an NSDNAME trigger for the name servers in the answer and authority sections.
+func (rz *RPZ) responseHit(m *dns.Msg, n int) *hit {
+	for _, p := range rz.policies[:n] {
+		idx := p.index()
+		if idx == nil || !idx.response {
+			continue
+		}
+		for _, rr := range m.Answer {
+			if c, ok := rr.(*dns.CNAME); ok {
+				if r := idx.qname.match(c.Target); r != nil {
+					return &hit{policy: p, idx: idx, trigger: triggerQName, rule: r}
+				}
+			}
+		}
+		for _, rr := range m.Answer {
+			var ip net.IP
+			switch x := rr.(type) {
+			case *dns.A:
+				ip = x.A
+			case *dns.AAAA:
+				ip = x.AAAA
+			default:
+				continue
+			}
+			if r := matchIP(idx.ip, ip); r != nil {
+				return &hit{policy: p, idx: idx, trigger: triggerIP, rule: r}
+			}
+		}
+		for _, section := range [][]dns.RR{m.Answer, m.Ns} {
+			for _, rr := range section {
+				ns, ok := rr.(*dns.NS)
+				if !ok {
+					continue
+				}
+				if r := idx.nsdname.match(ns.Ns); r != nil {
+					return &hit{policy: p, idx: idx, trigger: triggerNSDName, rule: r}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// apply applies the action of h. If the query has been resolved resp holds the response.
+func (rz *RPZ) apply(ctx context.Context, state request.Request, h *hit, resp *dns.Msg) (int, error) {
+	action := h.rule.action()
+
+	log.Infof("%s %s %s: %s trigger %q in policy zone %q, action %s", state.IP(), state.Type(), state.Name(), h.trigger, h.rule.owner, h.policy.origin, action)
+	hits.WithLabelValues(metrics.WithServer(ctx), h.policy.origin, h.trigger, action, metrics.WithView(ctx)).Inc()
+
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+	m.RecursionAvailable = true
+
+	switch action {
+	case actionDrop:
+		return dns.RcodeSuccess, nil
+	case actionTCPOnly:
+		if state.Proto() == "udp" {
+			m.Truncated = true
+			break
+		}
+		fallthrough
+	case actionPassthru:
+		if resp == nil {
+			return plugin.NextOrFailure(rz.Name(), rz.Next, ctx, state.W, state.Req)
+		}
+		m = resp
+	case actionNXDomain:
+		m.Rcode = dns.RcodeNameError
+		m.Ns = []dns.RR{h.idx.negative()}
+	case actionNoData:
+		m.Ns = []dns.RR{h.idx.negative()}
+	default:
+		rz.localData(ctx, state, h, m)
+	}
+
+	state.W.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// localData sets the answer in m to the records of the rule, with the query name as the owner name.
+// The target of a CNAME is resolved, a wildcard target (*.example.net.) is the query name prepended to
+// the rest of the target. When there are no records of the query type a NODATA response is returned.
+func (rz *RPZ) localData(ctx context.Context, state request.Request, h *hit, m *dns.Msg) {
+	qname, qtype := state.Name(), state.QType()
+	var target string
+	for _, rr := range h.rule.rrs {
+		t := rr.Header().Rrtype
+		if t != qtype && t != dns.TypeCNAME && qtype != dns.TypeANY {
+			continue
+		}
+		rr = dns.Copy(rr)
+		rr.Header().Name = qname
+		if c, ok := rr.(*dns.CNAME); ok && strings.HasPrefix(c.Target, "*.") {
+			c.Target = strings.TrimPrefix(qname+c.Target[2:], ".")
+			if _, ok := dns.IsDomainName(c.Target); !ok {
+				// The expanded name is too long, as with a DNAME (RFC 6672, section 2.2).
+				m.Answer = nil
+				m.Rcode = dns.RcodeYXDomain
+				return
+			}
+		}
+		m.Answer = append(m.Answer, rr)
+		if c, ok := rr.(*dns.CNAME); ok && qtype != dns.TypeCNAME {
+			target = c.Target
+		}
+	}
+	if len(m.Answer) == 0 {
+		m.Ns = []dns.RR{h.idx.negative()}
+		return
+	}
+	if target == "" || rz.Upstream == nil {
+		return
+	}
+
+	loop, _ := ctx.Value(dnsserver.LoopKey{}).(int)
+	if loop > 8 {
+		return
+	}
+	ctx = context.WithValue(ctx, dnsserver.LoopKey{}, loop+1)
+	resp, err := rz.Upstream.Lookup(ctx, state, target, qtype)
+	if err != nil || resp == nil {
+		return
+	}
+	m.Answer = append(m.Answer, resp.Answer...)
+	m.Rcode = resp.Rcode
+}
+
+// negative returns the SOA record to add to synthesized NXDOMAIN and NODATA responses.
+func (idx *index) negative() dns.RR {
+	soa := dns.Copy(idx.soa).(*dns.SOA)
+	if soa.Minttl < soa.Hdr.Ttl {
+		soa.Hdr.Ttl = soa.Minttl
+	}
+	return soa
+}
+
+// Name implements the plugin.Handler interface.
+func (rz *RPZ) Name() string { return "rpz" }
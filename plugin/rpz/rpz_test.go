@@ -0,0 +1,311 @@
+package rpz
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+const dbRPZ = `$TTL 300
+@                           IN SOA  localhost. hostmaster.localhost. 1 3600 600 86400 60
+                            IN NS   localhost.
+
+; QNAME triggers
+nxdomain.example.com        CNAME   .
+nodata.example.com          CNAME   *.
+*.wild.example.com          CNAME   .
+passthru.wild.example.com   CNAME   rpz-passthru.
+drop.example.com            CNAME   rpz-drop.
+tcp.example.com             CNAME   rpz-tcp-only.
+walled.example.com          CNAME   garden.example.net.
+*.rewrite.example.com       CNAME   *.garden.example.net.
+local.example.com           A       192.0.2.53
+                            TXT     "blocked"
+
+; Client-IP triggers
+32.2.0.0.10.rpz-client-ip   CNAME   rpz-drop.
+24.0.0.0.10.rpz-client-ip   CNAME   rpz-passthru.
+
+; IP triggers
+24.0.100.51.198.rpz-ip      CNAME   .
+128.1.zz.db8.2001.rpz-ip    CNAME   *.
+
+; NSDNAME triggers
+ns.evil.example.rpz-nsdname CNAME   .
+`
+
+func newTestRPZ(t *testing.T, dbs ...string) *RPZ {
+	t.Helper()
+	rz := &RPZ{Zones: []string{"."}}
+	for i, db := range dbs {
+		origin := []string{"rpz.local.", "rpz2.local."}[i]
+		z, err := file.Parse(strings.NewReader(db), origin, "stdin", 0)
+		if err != nil {
+			t.Fatalf("Failed to parse policy zone: %s", err)
+		}
+		rz.policies = append(rz.policies, newPolicy(origin, z))
+	}
+	return rz
+}
+
+// backend answers every query with the addresses in answer and the name servers in ns.
+func backend(answer, ns []dns.RR) plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		for _, rr := range answer {
+			rr = dns.Copy(rr)
+			rr.Header().Name = r.Question[0].Name
+			m.Answer = append(m.Answer, rr)
+		}
+		m.Ns = ns
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+type fakeUpstream struct{}
+
+func (fakeUpstream) Lookup(ctx context.Context, state request.Request, name string, typ uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, typ)
+	m.Answer = []dns.RR{test.A(name + " 300 IN A 203.0.113.1")}
+	return m, nil
+}
+
+func TestRPZ(t *testing.T) {
+	tests := []struct {
+		qname    string
+		qtype    uint16
+		client   string
+		tcp      bool
+		answer   []dns.RR // from the backend
+		ns       []dns.RR
+		drop     bool
+		rcode    int
+		expected []dns.RR // expected answer section, nil means we don't check it.
+		soa      bool
+	}{
+		// QNAME triggers.
+		{qname: "nxdomain.example.com.", rcode: dns.RcodeNameError, soa: true},
+		{qname: "NXDOMAIN.example.com.", rcode: dns.RcodeNameError, soa: true},
+		{qname: "nodata.example.com.", rcode: dns.RcodeSuccess, soa: true, expected: []dns.RR{}},
+		{qname: "a.wild.example.com.", rcode: dns.RcodeNameError, soa: true},
+		{qname: "a.b.wild.example.com.", rcode: dns.RcodeNameError, soa: true},
+		{qname: "wild.example.com.", rcode: dns.RcodeSuccess, answer: []dns.RR{test.A("x. 300 IN A 192.0.2.1")}, expected: []dns.RR{test.A("wild.example.com. 300 IN A 192.0.2.1")}},
+		{qname: "passthru.wild.example.com.", rcode: dns.RcodeSuccess, answer: []dns.RR{test.A("x. 300 IN A 192.0.2.1")}, expected: []dns.RR{test.A("passthru.wild.example.com. 300 IN A 192.0.2.1")}},
+		{qname: "drop.example.com.", drop: true},
+		{qname: "tcp.example.com.", rcode: dns.RcodeSuccess, expected: []dns.RR{}},
+		{qname: "tcp.example.com.", tcp: true, rcode: dns.RcodeSuccess, answer: []dns.RR{test.A("x. 300 IN A 192.0.2.1")}, expected: []dns.RR{test.A("tcp.example.com. 300 IN A 192.0.2.1")}},
+		{qname: "walled.example.com.", rcode: dns.RcodeSuccess, expected: []dns.RR{
+			test.CNAME("walled.example.com. 300 IN CNAME garden.example.net."),
+			test.A("garden.example.net. 300 IN A 203.0.113.1"),
+		}},
+		{qname: "www.rewrite.example.com.", rcode: dns.RcodeSuccess, expected: []dns.RR{
+			test.CNAME("www.rewrite.example.com. 300 IN CNAME www.rewrite.example.com.garden.example.net."),
+			test.A("www.rewrite.example.com.garden.example.net. 300 IN A 203.0.113.1"),
+		}},
+		{qname: strings.Repeat("a.", 110) + "rewrite.example.com.", rcode: dns.RcodeYXDomain, expected: []dns.RR{}},
+		{qname: "local.example.com.", rcode: dns.RcodeSuccess, expected: []dns.RR{test.A("local.example.com. 300 IN A 192.0.2.53")}},
+		{qname: "local.example.com.", qtype: dns.TypeTXT, rcode: dns.RcodeSuccess, expected: []dns.RR{test.TXT(`local.example.com. 300 IN TXT "blocked"`)}},
+		{qname: "local.example.com.", qtype: dns.TypeMX, rcode: dns.RcodeSuccess, soa: true, expected: []dns.RR{}},
+		// QNAME triggers also apply to the CNAME targets in the response.
+		{qname: "www.example.org.", rcode: dns.RcodeNameError, answer: []dns.RR{test.CNAME("x. 300 IN CNAME nxdomain.example.com."), test.A("x. 300 IN A 192.0.2.1")}, soa: true},
+		{qname: "www.example.org.", rcode: dns.RcodeSuccess, answer: []dns.RR{test.CNAME("x. 300 IN CNAME www.example.net.")}},
+		{qname: "www.example.org.", rcode: dns.RcodeNameError, answer: []dns.RR{test.CNAME("x. 300 IN CNAME www.example.net."), test.CNAME("x. 300 IN CNAME a.b.wild.example.com.")}, soa: true},
+		// Client-IP triggers take precedence over QNAME triggers.
+		{qname: "nxdomain.example.com.", client: "10.0.0.2", drop: true},
+		{qname: "nxdomain.example.com.", client: "10.0.0.3", rcode: dns.RcodeSuccess, answer: []dns.RR{test.A("x. 300 IN A 192.0.2.1")}, expected: []dns.RR{test.A("nxdomain.example.com. 300 IN A 192.0.2.1")}},
+		// IP triggers.
+		{qname: "www.example.org.", rcode: dns.RcodeNameError, answer: []dns.RR{test.A("x. 300 IN A 198.51.100.10")}, soa: true},
+		{qname: "www.example.org.", rcode: dns.RcodeSuccess, answer: []dns.RR{test.A("x. 300 IN A 198.51.101.10")}, expected: []dns.RR{test.A("www.example.org. 300 IN A 198.51.101.10")}},
+		{qname: "www.example.org.", qtype: dns.TypeAAAA, rcode: dns.RcodeSuccess, answer: []dns.RR{test.AAAA("x. 300 IN AAAA 2001:db8::1")}, soa: true, expected: []dns.RR{}},
+		// NSDNAME triggers.
+		{qname: "www.example.org.", rcode: dns.RcodeNameError, ns: []dns.RR{test.NS("example.org. 300 IN NS ns.evil.example.")}, soa: true},
+		{qname: "www.example.org.", rcode: dns.RcodeSuccess, ns: []dns.RR{test.NS("example.org. 300 IN NS ns.good.example.")}},
+	}
+
+	rz := newTestRPZ(t, dbRPZ)
+	rz.Upstream = fakeUpstream{}
+	for i, tc := range tests {
+		rz.Next = backend(tc.answer, tc.ns)
+		qtype := tc.qtype
+		if qtype == 0 {
+			qtype = dns.TypeA
+		}
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: tc.client, TCP: tc.tcp})
+		if _, err := rz.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if tc.drop {
+			if rec.Msg != nil {
+				t.Errorf("Test %d: expected the query to be dropped, got %s", i, rec.Msg)
+			}
+			continue
+		}
+		if rec.Msg == nil {
+			t.Errorf("Test %d: expected a response", i)
+			continue
+		}
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rec.Msg.Rcode])
+		}
+		if soa := len(rec.Msg.Ns) == 1 && rec.Msg.Ns[0].Header().Rrtype == dns.TypeSOA; soa != tc.soa {
+			t.Errorf("Test %d: expected SOA in authority section to be %t, got %v", i, tc.soa, rec.Msg.Ns)
+		}
+		if tc.expected == nil {
+			continue
+		}
+		if err := test.Section(test.Case{Qname: tc.qname, Qtype: qtype, Answer: tc.expected}, test.Answer, rec.Msg.Answer); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+}
+
+func TestRPZTCPOnly(t *testing.T) {
+	rz := newTestRPZ(t, dbRPZ)
+	rz.Next = backend(nil, nil)
+	m := new(dns.Msg)
+	m.SetQuestion("tcp.example.com.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rz.ServeDNS(context.TODO(), rec, m)
+	if !rec.Msg.Truncated {
+		t.Errorf("Expected a truncated response over UDP")
+	}
+}
+
+func TestRPZPrecedence(t *testing.T) {
+	first := `$TTL 300
+@                       IN SOA  localhost. hostmaster.localhost. 1 3600 600 86400 60
+24.0.100.51.198.rpz-ip  CNAME   rpz-drop.
+evil.example.net        CNAME   rpz-drop.
+`
+	second := `$TTL 300
+@                       IN SOA  localhost. hostmaster.localhost. 1 3600 600 86400 60
+www.example.org         CNAME   .
+other.example.org       CNAME   .
+`
+	rz := newTestRPZ(t, first, second)
+
+	// The IP trigger of the first zone takes precedence over the QNAME trigger of the second.
+	rz.Next = backend([]dns.RR{test.A("x. 300 IN A 198.51.100.10")}, nil)
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rz.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg != nil {
+		t.Errorf("Expected the query to be dropped, got %s", rec.Msg)
+	}
+
+	// So does a QNAME trigger of the first zone for a CNAME target.
+	rz.Next = backend([]dns.RR{test.CNAME("x. 300 IN CNAME evil.example.net.")}, nil)
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	rz.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg != nil {
+		t.Errorf("Expected the query to be dropped, got %s", rec.Msg)
+	}
+
+	// Without an IP hit the QNAME trigger is used.
+	rz.Next = backend([]dns.RR{test.A("x. 300 IN A 192.0.2.1")}, nil)
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	rz.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg == nil || rec.Msg.Rcode != dns.RcodeNameError {
+		t.Errorf("Expected NXDOMAIN, got %v", rec.Msg)
+	}
+}
+
+func TestRPZOutOfZone(t *testing.T) {
+	rz := newTestRPZ(t, dbRPZ)
+	rz.Zones = []string{"example.org."}
+	rz.Next = backend([]dns.RR{test.A("x. 300 IN A 192.0.2.1")}, nil)
+	m := new(dns.Msg)
+	m.SetQuestion("nxdomain.example.com.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rz.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected policy to not be applied outside of the zones")
+	}
+}
+
+func TestTriggerNet(t *testing.T) {
+	tests := []struct {
+		rel      string
+		expected string
+	}{
+		{"32.1.0.0.127", "127.0.0.1/32"},
+		{"24.0.2.0.192", "192.0.2.0/24"},
+		{"8.1.2.3.10", "10.0.0.0/8"},
+		{"128.1.zz.db8.2001", "2001:db8::1/128"},
+		{"48.zz.db8.2001", "2001:db8::/48"},
+		{"128.1.zz", "::1/128"},
+		{"64.zz.fe80", "fe80::/64"},
+		{"128.8.7.6.5.4.3.2.1", "1:2:3:4:5:6:7:8/128"},
+		{"33.1.0.0.127", ""},
+		{"0.1.0.0.127", ""},
+		{"x.1.0.0.127", ""},
+		{"32", ""},
+		{"129.1.zz", ""},
+		{"64.zz.zz.1", ""},
+	}
+	for i, tc := range tests {
+		n, err := triggerNet(tc.rel)
+		if tc.expected == "" {
+			if err == nil {
+				t.Errorf("Test %d: expected error for %q, got %s", i, tc.rel, n)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error for %q, got %s", i, tc.rel, err)
+			continue
+		}
+		_, expected, _ := net.ParseCIDR(tc.expected)
+		if n.String() != expected.String() {
+			t.Errorf("Test %d: expected %s, got %s", i, expected, n)
+		}
+	}
+}
+
+func TestPolicyIndex(t *testing.T) {
+	rz := newTestRPZ(t, dbRPZ+"32.1.0.0.127.rpz-nsip CNAME .\n")
+	p := rz.policies[0]
+	idx := p.index()
+	if idx == nil {
+		t.Fatal("Expected an index")
+	}
+	if p.index() != idx {
+		t.Errorf("Expected the index to be reused when the serial doesn't change")
+	}
+	if !idx.response {
+		t.Errorf("Expected response triggers")
+	}
+	if r := idx.qname.match("nxdomain.example.com."); r == nil || r.action() != actionNXDomain {
+		t.Errorf("Expected nxdomain action for QNAME trigger")
+	}
+	if r := idx.qname.match("wild.example.com."); r != nil {
+		t.Errorf("Expected a wildcard to not match its own name")
+	}
+	if r := idx.qname.match("32.1.0.0.127.rpz-nsip."); r != nil {
+		t.Errorf("Expected unsupported NSIP triggers to be skipped")
+	}
+
+	// A new serial builds a new index.
+	p.zone.Lock()
+	p.zone.Apex.SOA.Serial++
+	p.zone.Unlock()
+	if p.index() == idx {
+		t.Errorf("Expected a new index after a serial change")
+	}
+}
@@ -0,0 +1,168 @@
+package rpz
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/file"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/parse"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+)
+
+var log = clog.NewWithPlugin("rpz")
+
+func init() { plugin.Register("rpz", setup) }
+
+func setup(c *caddy.Controller) error {
+	rz, err := rpzParse(c)
+	if err != nil {
+		return plugin.Error("rpz", err)
+	}
+
+	// Keep the policy zones up to date, either by reloading the file or by transferring the zone.
+	for _, p := range rz.policies {
+		z, origin := p.zone, p.origin
+		if len(z.TransferFrom) == 0 {
+			c.OnShutdown(z.OnShutdown)
+			c.OnStartup(func() error {
+				z.StartupOnce.Do(func() { z.Reload(nil) })
+				return nil
+			})
+			continue
+		}
+		c.OnStartup(func() error {
+			z.StartupOnce.Do(func() {
+				go func() {
+					dur := time.Millisecond * 250
+					step := time.Duration(2)
+					max := time.Second * 10
+					for {
+						err := z.TransferIn()
+						if err == nil {
+							break
+						}
+						log.Warningf("All '%s' masters failed to transfer, retrying in %s: %s", origin, dur.String(), err)
+						time.Sleep(dur)
+						dur = step * dur
+						if dur > max {
+							dur = max
+						}
+					}
+					z.Update()
+				}()
+			})
+			return nil
+		})
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		rz.Next = next
+		return rz
+	})
+
+	return nil
+}
+
+func rpzParse(c *caddy.Controller) (*RPZ, error) {
+	rz := &RPZ{Upstream: upstream.New()}
+	config := dnsserver.GetConfig(c)
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		rz.Zones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+
+		reload := 1 * time.Minute
+		seen := map[string]struct{}{}
+		add := func(origin string, z *file.Zone) error {
+			if _, ok := seen[origin]; ok {
+				return c.Errf("duplicate policy zone %q", origin)
+			}
+			seen[origin] = struct{}{}
+			rz.policies = append(rz.policies, newPolicy(origin, z))
+			return nil
+		}
+		for c.NextBlock() {
+			switch c.Val() {
+			case "file":
+				// file ZONE FILE
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				origin := plugin.Name(args[0]).Normalize()
+				fileName := args[1]
+				if !filepath.IsAbs(fileName) && config.Root != "" {
+					fileName = filepath.Join(config.Root, fileName)
+				}
+				reader, err := os.Open(filepath.Clean(fileName))
+				if err != nil {
+					return nil, err
+				}
+				z, err := file.Parse(reader, origin, fileName, 0)
+				reader.Close()
+				if err != nil {
+					return nil, err
+				}
+				if err := add(origin, z); err != nil {
+					return nil, err
+				}
+
+			case "secondary":
+				// secondary ZONE ADDRESS...
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return nil, c.ArgErr()
+				}
+				origin := plugin.Name(args[0]).Normalize()
+				z := file.NewZone(origin, "stdin")
+				for _, addr := range args[1:] {
+					normalized, err := parse.HostPort(addr, transport.Port)
+					if err != nil {
+						return nil, err
+					}
+					z.TransferFrom = append(z.TransferFrom, normalized)
+				}
+				if err := add(origin, z); err != nil {
+					return nil, err
+				}
+
+			case "reload":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid duration for reload: %q", c.Val())
+				}
+				if d < 0 {
+					return nil, c.Errf("invalid negative duration for reload: %q", c.Val())
+				}
+				reload = d
+
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+
+		if len(rz.policies) == 0 {
+			return nil, c.Err("at least one policy zone is required")
+		}
+		for _, p := range rz.policies {
+			if len(p.zone.TransferFrom) == 0 {
+				p.zone.ReloadInterval = reload
+			}
+		}
+	}
+	return rz, nil
+}
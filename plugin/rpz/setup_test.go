@@ -0,0 +1,88 @@
+package rpz
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coredns/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "db.rpz.local")
+	if err := os.WriteFile(db, []byte(dbRPZ), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input        string
+		shouldErr    bool
+		expectedZone []string
+		policies     int
+	}{
+		{`rpz {
+			file rpz.local ` + db + `
+		}`, false, []string{"."}, 1},
+		{`rpz example.org {
+			file rpz.local ` + db + `
+			secondary feed.rpz 192.0.2.1 [2001:db8::1]:5300
+			reload 10s
+		}`, false, []string{"example.org."}, 2},
+		// fails
+		{`rpz`, true, nil, 0},
+		{`rpz {
+			file rpz.local
+		}`, true, nil, 0},
+		{`rpz {
+			file rpz.local ` + filepath.Join(dir, "missing") + `
+		}`, true, nil, 0},
+		{`rpz {
+			secondary feed.rpz
+		}`, true, nil, 0},
+		{`rpz {
+			file rpz.local ` + db + `
+			secondary rpz.local 192.0.2.1
+		}`, true, nil, 0},
+		{`rpz {
+			file rpz.local ` + db + `
+			reload
+		}`, true, nil, 0},
+		{`rpz {
+			file rpz.local ` + db + `
+			reload -1s
+		}`, true, nil, 0},
+		{`rpz {
+			file rpz.local ` + db + `
+			foo
+		}`, true, nil, 0},
+		{`rpz {
+			file rpz.local ` + db + `
+		}
+		rpz {
+			file rpz.local ` + db + `
+		}`, true, nil, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		c.ServerBlockKeys = []string{"."}
+		rz, err := rpzParse(c)
+
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+		}
+		if err != nil {
+			if !test.shouldErr {
+				t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			}
+			continue
+		}
+		if len(rz.Zones) != len(test.expectedZone) || rz.Zones[0] != test.expectedZone[0] {
+			t.Errorf("Test %d: expected zones %v, got %v", i, test.expectedZone, rz.Zones)
+		}
+		if len(rz.policies) != test.policies {
+			t.Errorf("Test %d: expected %d policy zones, got %d", i, test.policies, len(rz.policies))
+		}
+	}
+}
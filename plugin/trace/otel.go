@@ -0,0 +1,124 @@
+package trace
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/coremain"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/rcode"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const otlpShutdownTimeout = 5 * time.Second
+
+// isOTLP returns true if the spans are exported with OpenTelemetry.
+func (t *trace) isOTLP() bool { return t.EndpointType == "otlp" || t.EndpointType == "otlphttp" }
+
+// setupOTLP creates the OpenTelemetry tracer provider that exports the spans over OTLP.
+func (t *trace) setupOTLP() error {
+	exp, err := t.otlpExporter()
+	if err != nil {
+		return err
+	}
+	res := resource.NewSchemaless(
+		attribute.String("service.name", t.serviceName),
+		attribute.String("service.version", coremain.CoreVersion),
+	)
+	// Sampling is done by every, a span that is started is always exported.
+	t.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+		sdktrace.WithResource(res),
+	)
+	t.otelTracer = t.provider.Tracer(plugin.TracerName)
+	t.tagSet = tagByProvider["default"]
+	return nil
+}
+
+func (t *trace) otlpExporter() (*otlptrace.Exporter, error) {
+	url := strings.Contains(t.Endpoint, "://")
+	if t.EndpointType == "otlphttp" {
+		opts := []otlptracehttp.Option{otlptracehttp.WithHeaders(t.otlpHeaders)}
+		if url {
+			opts = append(opts, otlptracehttp.WithEndpointURL(t.Endpoint))
+		} else {
+			opts = append(opts, otlptracehttp.WithEndpoint(t.Endpoint))
+		}
+		if t.otlpInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithHeaders(t.otlpHeaders)}
+	if url {
+		opts = append(opts, otlptracegrpc.WithEndpointURL(t.Endpoint))
+	} else {
+		opts = append(opts, otlptracegrpc.WithEndpoint(t.Endpoint))
+	}
+	if t.otlpInsecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	return otlptracegrpc.New(context.Background(), opts...)
+}
+
+// OnShutdown exports the spans that are still buffered and stops the OTLP exporter.
+func (t *trace) OnShutdown() error {
+	if t.provider == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), otlpShutdownTimeout)
+	defer cancel()
+	err := t.provider.Shutdown(ctx)
+	t.provider = nil
+	if err != nil {
+		log.Infof("Failed to stop OTLP trace exporter: %s", err)
+	}
+	return err
+}
+
+// serveOTel traces the request with an OpenTelemetry span. The span is a child of the span context
+// received from the client, which the DoH and gRPC servers extract into ctx.
+func (t *trace) serveOTel(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	ctx, span := t.otelTracer.Start(ctx, defaultTopLevelSpanName, oteltrace.WithSpanKind(oteltrace.SpanKindServer))
+	defer span.End()
+
+	traceID := span.SpanContext().TraceID().String()
+	metadata.SetValueFunc(ctx, metaTraceIdKey, func() string { return traceID })
+
+	req := request.Request{W: w, Req: r}
+	rw := dnstest.NewRecorder(w)
+	status, err := plugin.NextOrFailure(t.Name(), t.Next, ctx, rw, r)
+
+	rc := rw.Rcode
+	if !plugin.ClientWrite(status) {
+		rc = status
+	}
+	span.SetAttributes(
+		attribute.String(t.tagSet.Name, req.Name()),
+		attribute.String(t.tagSet.Type, req.Type()),
+		attribute.String(t.tagSet.Proto, req.Proto()),
+		attribute.String(t.tagSet.Remote, req.IP()),
+		attribute.String(t.tagSet.Rcode, rcode.ToString(rc)),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return status, err
+}
@@ -0,0 +1,163 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	ctrace "github.com/coredns/coredns/plugin/pkg/trace"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	grpcmd "google.golang.org/grpc/metadata"
+)
+
+func newOTelTrace(next plugin.Handler, every uint64) (*trace, *tracetest.SpanRecorder) {
+	sr := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	tr := &trace{
+		Next:       next,
+		every:      every,
+		provider:   provider,
+		otelTracer: provider.Tracer(plugin.TracerName),
+		tagSet:     tagByProvider["default"],
+	}
+	return tr, sr
+}
+
+func TestStartupOTLP(t *testing.T) {
+	for _, input := range []string{"trace otlp localhost:4317", "trace otlphttp http://localhost:4318/v1/traces"} {
+		m, err := traceParse(caddy.NewTestController("dns", input))
+		if err != nil {
+			t.Fatalf("Error parsing %q: %s", input, err)
+		}
+		if err := m.OnStartup(); err != nil {
+			t.Fatalf("Error starting %q: %s", input, err)
+		}
+		if m.otelTracer == nil {
+			t.Errorf("Expected an OpenTelemetry tracer for %q", input)
+		}
+		if m.Tracer() != nil {
+			t.Errorf("Expected no OpenTracing tracer for %q", input)
+		}
+		if err := m.OnShutdown(); err != nil {
+			t.Errorf("Error shutting down %q: %s", input, err)
+		}
+	}
+}
+
+func TestTraceOTel(t *testing.T) {
+	nxdomain := test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg).SetRcode(r, dns.RcodeNameError)
+		w.WriteMsg(m)
+		return dns.RcodeNameError, nil
+	})
+	var traceID string
+	next := test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		if fm := metadata.ValueFunc(ctx, metaTraceIdKey); fm != nil {
+			traceID = fm()
+		}
+		return plugin.NextOrFailure("handlerfunc", nxdomain, ctx, w, r)
+	})
+	tr, sr := newOTelTrace(next, 1)
+
+	ctx := metadata.ContextWithMetadata(context.TODO())
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	m := new(dns.Msg).SetQuestion("example.org.", dns.TypeA)
+	if _, err := tr.ServeDNS(ctx, w, m); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	spans := sr.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+	// Spans end from the innermost outwards.
+	root := spans[2]
+	if root.Name() != defaultTopLevelSpanName {
+		t.Errorf("Expected root span %q, got %q", defaultTopLevelSpanName, root.Name())
+	}
+	if root.SpanKind() != oteltrace.SpanKindServer {
+		t.Errorf("Expected server span, got %s", root.SpanKind())
+	}
+	if spans[1].Name() != "handlerfunc" || spans[1].Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Errorf("Expected plugin span as child of the root span")
+	}
+	if spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Errorf("Expected plugin span as child of the previous plugin span")
+	}
+	if traceID != root.SpanContext().TraceID().String() {
+		t.Errorf("Expected metadata trace ID %s, got %s", root.SpanContext().TraceID(), traceID)
+	}
+
+	attrs := map[string]string{}
+	for _, kv := range root.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs[tagByProvider["default"].Name] != "example.org." {
+		t.Errorf("Expected name attribute %q, got %q", "example.org.", attrs[tagByProvider["default"].Name])
+	}
+	if attrs[tagByProvider["default"].Rcode] != "NXDOMAIN" {
+		t.Errorf("Expected rcode attribute %q, got %q", "NXDOMAIN", attrs[tagByProvider["default"].Rcode])
+	}
+}
+
+func TestTraceOTelEvery(t *testing.T) {
+	tr, sr := newOTelTrace(test.NextHandler(dns.RcodeSuccess, nil), 3)
+
+	for range 6 {
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		m := new(dns.Msg).SetQuestion("example.org.", dns.TypeA)
+		tr.ServeDNS(context.TODO(), w, m)
+	}
+
+	roots := 0
+	for _, s := range sr.Ended() {
+		if s.Name() == defaultTopLevelSpanName {
+			roots++
+		}
+	}
+	if roots != 2 {
+		t.Errorf("Expected 2 traced queries, got %d", roots)
+	}
+}
+
+func TestTraceOTelPropagation(t *testing.T) {
+	tr, sr := newOTelTrace(test.NextHandler(dns.RcodeSuccess, nil), 1)
+
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	h := http.Header{}
+	h.Set("traceparent", "00-"+traceID+"-"+spanID+"-01")
+	ctx := ctrace.IncomingHTTPContext(context.TODO(), h)
+
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	m := new(dns.Msg).SetQuestion("example.org.", dns.TypeA)
+	tr.ServeDNS(ctx, w, m)
+
+	spans := sr.Ended()
+	root := spans[len(spans)-1]
+	if root.SpanContext().TraceID().String() != traceID {
+		t.Errorf("Expected trace ID %s, got %s", traceID, root.SpanContext().TraceID())
+	}
+	if root.Parent().SpanID().String() != spanID || !root.Parent().IsRemote() {
+		t.Errorf("Expected remote parent %s, got %s", spanID, root.Parent().SpanID())
+	}
+
+	// And the span context goes out again over gRPC.
+	out := ctrace.OutgoingContext(oteltrace.ContextWithSpanContext(context.TODO(), root.SpanContext()))
+	md, _ := grpcmd.FromOutgoingContext(out)
+	ctx = ctrace.IncomingContext(grpcmd.NewIncomingContext(context.TODO(), md))
+	if got := oteltrace.SpanContextFromContext(ctx); got.TraceID().String() != traceID || got.SpanID() != root.SpanContext().SpanID() {
+		t.Errorf("Expected span context to be propagated over gRPC, got %s/%s", got.TraceID(), got.SpanID())
+	}
+}
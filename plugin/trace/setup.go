@@ -25,13 +25,14 @@ func setup(c *caddy.Controller) error {
 	})
 
 	c.OnStartup(t.OnStartup)
+	c.OnShutdown(t.OnShutdown)
 
 	return nil
 }
 
 func traceParse(c *caddy.Controller) (*trace, error) {
 	var (
-		tr  = &trace{every: 1, serviceName: defServiceName}
+		tr  = &trace{every: 1, serviceName: defServiceName, otlpHeaders: map[string]string{}}
 		err error
 	)
 
@@ -128,6 +129,23 @@ func traceParse(c *caddy.Controller) (*trace, error) {
 				if err != nil {
 					return nil, err
 				}
+			case "otlp_insecure":
+				if len(c.RemainingArgs()) != 0 {
+					return nil, c.ArgErr()
+				}
+				if !tr.isOTLP() {
+					return nil, fmt.Errorf("otlp_insecure requires an otlp or otlphttp endpoint")
+				}
+				tr.otlpInsecure = true
+			case "otlp_header":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				if !tr.isOTLP() {
+					return nil, fmt.Errorf("otlp_header requires an otlp or otlphttp endpoint")
+				}
+				tr.otlpHeaders[args[0]] = args[1]
 			}
 		}
 	}
@@ -153,8 +171,10 @@ func normalizeEndpoint(epType, ep string) (string, string, error) {
 }
 
 var supportedProviders = map[string]string{
-	"zipkin":  "localhost:9411",
-	"datadog": "localhost:8126",
+	"zipkin":   "localhost:9411",
+	"datadog":  "localhost:8126",
+	"otlp":     "localhost:4317",
+	"otlphttp": "localhost:4318",
 }
 
 const (
@@ -33,6 +33,9 @@ func TestTraceParse(t *testing.T) {
 		{"trace {\n client_server false\n}", false, "http://localhost:9411/api/v2/spans", 1, `coredns`, false, 0, 0, 0},
 		{"trace {\n zipkin_max_backlog_size 100\n zipkin_max_batch_size 200\n zipkin_max_batch_interval 10s\n}", false,
 			"http://localhost:9411/api/v2/spans", 1, `coredns`, false, 100, 200, 10 * time.Second},
+		{`trace otlp localhost:4317`, false, "localhost:4317", 1, `coredns`, false, 0, 0, 0},
+		{`trace otlphttp collector:4318`, false, "collector:4318", 1, `coredns`, false, 0, 0, 0},
+		{"trace otlp http://collector:4317 {\n otlp_insecure\n otlp_header authorization token\n every 10\n}", false, "http://collector:4317", 10, `coredns`, false, 0, 0, 0},
 
 		// fails
 		{`trace footype localhost:4321`, true, "", 1, "", false, 0, 0, 0},
@@ -44,6 +47,9 @@ func TestTraceParse(t *testing.T) {
 		{"trace {\n zipkin_max_backlog_size\n}", true, "", 1, `coredns`, false, 0, 0, 0},
 		{"trace {\n zipkin_max_batch_size\n}", true, "", 1, `coredns`, false, 0, 0, 0},
 		{"trace {\n zipkin_max_batch_interval\n}", true, "", 1, `coredns`, false, 0, 0, 0},
+		{"trace {\n otlp_insecure\n}", true, "", 1, `coredns`, false, 0, 0, 0},
+		{"trace otlp {\n otlp_insecure yes\n}", true, "", 1, `coredns`, false, 0, 0, 0},
+		{"trace otlp {\n otlp_header authorization\n}", true, "", 1, `coredns`, false, 0, 0, 0},
 	}
 	for i, test := range tests {
 		c := caddy.NewTestController("dns", test.input)
@@ -1,4 +1,4 @@
-// Package trace implements OpenTracing-based tracing
+// Package trace implements OpenTracing and OpenTelemetry based tracing
 package trace
 
 import (
@@ -26,6 +26,8 @@ import (
 	zipkinot "github.com/openzipkin-contrib/zipkin-go-opentracing"
 	"github.com/openzipkin/zipkin-go"
 	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentracer"
@@ -79,10 +81,15 @@ type trace struct {
 	zipkinMaxBacklogSize   int
 	zipkinMaxBatchSize     int
 	zipkinMaxBatchInterval time.Duration
+	otlpInsecure           bool
+	otlpHeaders            map[string]string
+	provider               *sdktrace.TracerProvider
+	otelTracer             oteltrace.Tracer
 	Once                   sync.Once
 	tagSet                 traceTags
 }
 
+// Tracer returns the OpenTracing tracer, this is nil when the spans are exported with OpenTelemetry.
 func (t *trace) Tracer() ot.Tracer {
 	return t.tracer
 }
@@ -105,6 +112,8 @@ func (t *trace) OnStartup() error {
 			)
 			t.tracer = tracer
 			t.tagSet = tagByProvider["datadog"]
+		case "otlp", "otlphttp":
+			err = t.setupOTLP()
 		default:
 			err = fmt.Errorf("unknown endpoint type: %s", t.EndpointType)
 		}
@@ -156,6 +165,13 @@ func (t *trace) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 			trace = true
 		}
 	}
+	if t.otelTracer != nil {
+		if !trace || oteltrace.SpanFromContext(ctx).IsRecording() {
+			return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
+		}
+		return t.serveOTel(ctx, w, r)
+	}
+
 	span := ot.SpanFromContext(ctx)
 	if !trace || span != nil {
 		return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
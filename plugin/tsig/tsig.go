@@ -24,6 +24,15 @@ type TSIGServer struct {
 
 type qTypes map[uint16]struct{}
 
+type keyNameKey struct{}
+
+// KeyName returns the name of the TSIG key that the request was verified with, or the empty string when
+// the request wasn't signed. The name is lowercased and fully qualified.
+func KeyName(ctx context.Context) string {
+	name, _ := ctx.Value(keyNameKey{}).(string)
+	return name
+}
+
 // Name implements plugin.Handler
 func (t TSIGServer) Name() string { return pluginName }
 
@@ -75,6 +84,7 @@ func (t *TSIGServer) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.
 	}
 
 	if rcode == dns.RcodeSuccess {
+		ctx = context.WithValue(ctx, keyNameKey{}, plugin.Name(tsigRR.Hdr.Name).Normalize())
 		rcode, err = plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
 		if err != nil {
 			log.Errorf("request handler returned an error: %v\n", err)
@@ -224,6 +224,34 @@ func TestServeDNSTsigErrors(t *testing.T) {
 	}
 }
 
+func TestKeyName(t *testing.T) {
+	var keyName string
+	tsig := TSIGServer{
+		Zones: []string{"."},
+		Next: test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			keyName = KeyName(ctx)
+			return testHandler()(ctx, w, r)
+		}),
+	}
+
+	r := new(dns.Msg)
+	r.SetQuestion("test.example.", dns.TypeA)
+	if _, err := tsig.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), r); err != nil {
+		t.Fatal(err)
+	}
+	if keyName != "" {
+		t.Errorf("Expected no key name for an unsigned request, got %q", keyName)
+	}
+
+	r.SetTsig("Test.Key.", dns.HmacSHA256, 300, time.Now().Unix())
+	if _, err := tsig.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), r); err != nil {
+		t.Fatal(err)
+	}
+	if keyName != "test.key." {
+		t.Errorf("Expected key name %q, got %q", "test.key.", keyName)
+	}
+}
+
 func testHandler() test.HandlerFunc {
 	return func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
 		state := request.Request{W: w, Req: r}